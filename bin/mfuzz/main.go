@@ -2,16 +2,46 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"github.com/SeanHeelan/Malamute/config"
+	"github.com/SeanHeelan/Malamute/crashdb"
 	"github.com/SeanHeelan/Malamute/fs"
 	"github.com/SeanHeelan/Malamute/logging"
 	"github.com/SeanHeelan/Malamute/manage"
+	"github.com/SeanHeelan/Malamute/monitor"
+	"github.com/SeanHeelan/Malamute/oracle"
+	"github.com/SeanHeelan/Malamute/resultproc"
 	"github.com/SeanHeelan/Malamute/session"
+	"io/ioutil"
 	"log"
 	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"time"
 )
 
 func main() {
+	// Helpers started by interpreters are not killed along with mfuzz
+	// unless their process groups are
+	monitor.KillProcessGroupsOnSignal()
+	defer monitor.KillProcessGroups()
+
+	if len(os.Args) > 1 && os.Args[1] == "recheck" {
+		recheck(os.Args[2:])
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "bisect" {
+		bisect(os.Args[2:])
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "crashdb" {
+		crashDB(os.Args[2:])
+		return
+	}
+
 	var configFile string
 	flag.StringVar(&configFile, "config", "",
 		"The config file to use")
@@ -24,12 +54,22 @@ func main() {
 			"it left off. If the run mode is any other then this directory "+
 			"should not exist")
 
+	var report bool
+	flag.BoolVar(&report, "report", false,
+		"Merge the profiles collected by an existing session, regenerate "+
+			"its source coverage report and print its summary, then exit")
+
 	flag.Parse()
 
 	if len(sessionDirectory) == 0 {
 		log.Fatal("You must specify a session directory")
 	}
 
+	if report {
+		printReport(sessionDirectory)
+		return
+	}
+
 	var sess *session.Session
 	var seedPaths []string
 	if _, err := os.Stat(sessionDirectory); err == nil {
@@ -79,11 +119,29 @@ func main() {
 	}
 	defer logs.Close()
 
+	// The triggers of explored crashes would all be quarantined
+	exploring := sess.Config.TestProcessing.Mode ==
+		config.RUNMODE_EXPLORE_CRASHES
+	if !sess.Config.Preflight.Skip && !exploring {
+		seedPaths, err = manage.Preflight(sess, logs, seedPaths)
+		if err != nil {
+			log.Fatalf("Error running the seed tests %s", err)
+		}
+
+		if len(seedPaths) == 0 {
+			log.Fatal("Every seed test was quarantined")
+		}
+	}
+
 	termIndicator := make(chan int)
 	if sess.Config.TestProcessing.Mode == config.RUNMODE_COVER_ALL_ONCE {
 		log.Print("Covering all seeds once ...")
 		go manage.CoverAllSeedsOnce(sess, logs, seedPaths, termIndicator)
 	} else {
+		if exploring {
+			log.Printf("Exploring %d crashes ...",
+				len(sess.Config.ExploreCrashes.CrashDir))
+		}
 		if sess.Config.TestProcessing.TestCount == 0 {
 			log.Println("Running tests until manual termination ...")
 		} else {
@@ -97,6 +155,21 @@ func main() {
 }
 
 func loadSeeds(s *session.Session) (seedPaths []string, err error) {
+	if s.Config.TestProcessing.Mode == config.RUNMODE_EXPLORE_CRASHES {
+		log.Printf("Seed tests will be the triggers of the crashes in %s\n",
+			s.Config.ExploreCrashes.CrashDir)
+		parents, err := resultproc.LoadCrashParents(
+			s.Config.ExploreCrashes.CrashDir)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, parent := range parents {
+			seedPaths = append(seedPaths, parent.TriggerPath)
+		}
+		return seedPaths, nil
+	}
+
 	if len(s.Config.SeedTests.Dir) != 0 {
 		dir := s.Config.SeedTests.Dir
 		exts := s.Config.SeedTests.ValidExts
@@ -110,6 +183,23 @@ func loadSeeds(s *session.Session) (seedPaths []string, err error) {
 		seedPaths, err = fs.ReadPathsFromFile(file)
 	}
 
+	// A .phpt file is not a script, so run as a seed it never produces
+	// the output it expects
+	if err == nil && s.Config.Oracle.Enabled {
+		filtered := []string{}
+		for _, seedPath := range seedPaths {
+			if filepath.Ext(seedPath) != oracle.PHPT_EXT {
+				filtered = append(filtered, seedPath)
+			}
+		}
+		if len(filtered) != len(seedPaths) {
+			log.Printf("Ignoring %d .phpt seed tests, as the oracle needs "+
+				"the .php files extracted by run-tests.php",
+				len(seedPaths)-len(filtered))
+		}
+		seedPaths = filtered
+	}
+
 	return seedPaths, err
 }
 
@@ -123,3 +213,252 @@ func filterSeeds(s *session.Session, seeds []string) (filtered []string) {
 
 	return filtered
 }
+
+// printReport brings the source coverage report of the session in sessDir up
+// to date and prints the session summary
+func printReport(sessDir string) {
+	sess, err := session.Resume(sessDir)
+	if err != nil {
+		log.Fatalf("Failed to load session from directory %s. Error: %s",
+			sessDir, err)
+	}
+
+	if !sess.Config.Profile.Enabled {
+		log.Fatalf("Profile collection is not enabled for the session in %s",
+			sessDir)
+	}
+
+	if err := manage.UpdateProfileReport(sess); err != nil {
+		log.Fatalf("Failed to update the profile report. Error: %s", err)
+	}
+
+	if err := sess.Save(); err != nil {
+		log.Fatalf("Failed to save session. Error: %s", err)
+	}
+
+	if err := sess.LogSummary(); err != nil {
+		log.Fatalf("Failed to log session summary. Error: %s", err)
+	}
+
+	summary, err := ioutil.ReadFile(path.Join(sessDir, session.SUMMARY_FILE))
+	if err != nil {
+		log.Fatalf("Failed to read the session summary. Error: %s", err)
+	}
+	os.Stdout.Write(summary)
+}
+
+// recheck re-runs the preserved crashes of each session named in args
+// against an interpreter build, and prints the crash buckets whose status
+// changed
+func recheck(args []string) {
+	flags := flag.NewFlagSet("recheck", flag.ExitOnError)
+	var interpreter string
+	flags.StringVar(&interpreter, "interpreter", "",
+		"The interpreter build to re-run the preserved crashes against")
+
+	var binary string
+	flags.StringVar(&binary, "binary", "",
+		"An unstripped build of the interpreter, used to symbolize stack "+
+			"traces and produce backtraces. Defaults to the interpreter.")
+
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s recheck -interpreter PATH "+
+			"[-binary PATH] SESSION_DIR...\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if len(interpreter) == 0 {
+		log.Fatal("You must specify an interpreter")
+	}
+
+	if flags.NArg() == 0 {
+		log.Fatal("You must specify one or more session directories")
+	}
+
+	for _, sessDir := range flags.Args() {
+		sess, err := session.Resume(sessDir)
+		if err != nil {
+			log.Fatalf("Failed to load session from directory %s. Error: %s",
+				sessDir, err)
+		}
+
+		changes, err := manage.Recheck(sess, interpreter, binary)
+		if err != nil {
+			log.Fatalf("Failed to recheck the crashes of %s. Error: %s",
+				sessDir, err)
+		}
+
+		fmt.Printf("%s: %d buckets changed status (see %s)\n", sessDir,
+			len(changes), session.RECHECK_FILE)
+		for _, change := range changes {
+			previous := change.Previous
+			if len(previous) == 0 {
+				previous = "unchecked"
+			}
+			fmt.Printf("  %s : %s -> %s\n", change.Bucket, previous,
+				change.Check.Status)
+			for _, newBucket := range change.Check.NewBuckets {
+				fmt.Printf("    now %s\n", newBucket)
+			}
+		}
+	}
+}
+
+// bisect searches the builds named in args for the first in which a
+// preserved crash reproduces, and prints the result
+func bisect(args []string) {
+	flags := flag.NewFlagSet("bisect", flag.ExitOnError)
+	var sessionDirectory string
+	flags.StringVar(&sessionDirectory, "dir", "",
+		"The session directory the crash was preserved in")
+
+	var crashDir string
+	flags.StringVar(&crashDir, "crash", "",
+		"The crash directory, either as a path or as a name in the crashes "+
+			"directory of the session")
+
+	var binaryName string
+	flags.StringVar(&binaryName, "binary-name", "",
+		"The name of the interpreter in each build directory. Defaults to "+
+			"the name of the interpreter the session was run with.")
+
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s bisect -dir SESSION_DIR -crash "+
+			"CRASH_DIR [-binary-name NAME] BUILD...\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Builds are ordered from oldest to newest.")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if len(sessionDirectory) == 0 {
+		log.Fatal("You must specify a session directory")
+	}
+
+	if len(crashDir) == 0 {
+		log.Fatal("You must specify a crash directory")
+	}
+
+	if flags.NArg() == 0 {
+		log.Fatal("You must specify one or more builds")
+	}
+
+	sess, err := session.Resume(sessionDirectory)
+	if err != nil {
+		log.Fatalf("Failed to load session from directory %s. Error: %s",
+			sessionDirectory, err)
+	}
+
+	if _, err := os.Stat(crashDir); err != nil {
+		crashDir = path.Join(sess.PreservationDir, crashDir)
+	}
+
+	if len(binaryName) == 0 {
+		binaryName = path.Base(sess.Config.Interpreter.Path)
+	}
+
+	bugDesc, err := manage.Bisect(sess, crashDir, flags.Args(), binaryName)
+	if err != nil {
+		log.Fatalf("Failed to bisect %s. Error: %s", crashDir, err)
+	}
+
+	if len(bugDesc.BisectLastGood) == 0 {
+		fmt.Println("Last good build: none, the crash reproduces in every " +
+			"build")
+	} else {
+		fmt.Printf("Last good build: %s\n", bugDesc.BisectLastGood)
+	}
+	fmt.Printf("First bad build: %s\n", bugDesc.BisectFirstBad)
+}
+
+// crashDB lists, searches and shows the entries of a crash database, as
+// directed by args
+func crashDB(args []string) {
+	flags := flag.NewFlagSet("crashdb", flag.ExitOnError)
+	var dbDir string
+	flags.StringVar(&dbDir, "db", "",
+		"The directory of the crash database, as given by CrashDB.Dir")
+
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s crashdb -db DIR list\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s crashdb -db DIR search REGEXP\n",
+			os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s crashdb -db DIR show BUCKET\n",
+			os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if len(dbDir) == 0 {
+		log.Fatal("You must specify a crash database directory")
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	if _, err := os.Stat(dbDir); err != nil {
+		log.Fatalf("There is no crash database in %s", dbDir)
+	}
+
+	db, err := crashdb.Open(dbDir)
+	if err != nil {
+		log.Fatalf("Failed to open the crash database in %s. Error: %s",
+			dbDir, err)
+	}
+
+	command := flags.Arg(0)
+	switch {
+	case command == "list" && flags.NArg() == 1:
+		buckets, err := db.Buckets()
+		if err != nil {
+			log.Fatalf("Failed to list the crash database. Error: %s", err)
+		}
+
+		for _, summary := range buckets {
+			fmt.Printf("%s : %d crashes in %d sessions, last seen %s "+
+				"[%s: %s]\n", summary.Bucket, summary.Count,
+				summary.Sessions, summary.LastSeen.Format(time.RFC3339),
+				summary.Severity, summary.SeverityReason)
+		}
+	case command == "search" && flags.NArg() == 2:
+		re, err := regexp.Compile(flags.Arg(1))
+		if err != nil {
+			log.Fatalf("Invalid search pattern %s. Error: %s", flags.Arg(1),
+				err)
+		}
+
+		entries, err := db.Search(re)
+		if err != nil {
+			log.Fatalf("Failed to search the crash database. Error: %s", err)
+		}
+
+		for _, entry := range entries {
+			fmt.Printf("%s : %s\n", entry.Bucket, entry.CrashDir)
+		}
+	case command == "show" && flags.NArg() == 2:
+		entries, err := db.Entries(flags.Arg(1))
+		if err != nil {
+			log.Fatalf("Failed to read the crash database. Error: %s", err)
+		}
+
+		if len(entries) == 0 {
+			log.Fatalf("The bucket %s is not in the crash database",
+				flags.Arg(1))
+		}
+
+		for _, entry := range entries {
+			fmt.Printf("%s\n", entry.CrashDir)
+			fmt.Printf("  Added: %s\n", entry.Time.Format(time.RFC3339))
+			fmt.Printf("  Session: %s\n", entry.SessionDir)
+			fmt.Printf("  Interpreter: %s\n", entry.Interpreter)
+			fmt.Printf("  Class: %s\n", entry.BugClass)
+			fmt.Printf("  Severity: %s (%s)\n", entry.Severity,
+				entry.SeverityReason)
+		}
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+}
@@ -5,8 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"github.com/SeanHeelan/Malamute/arggen"
+	"github.com/SeanHeelan/Malamute/normalize"
+	"github.com/SeanHeelan/Malamute/oracle"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 )
 
 const (
@@ -16,11 +20,65 @@ const (
 
 	RUNMODE_COVER_ALL_ONCE  = "cover_all_once"
 	RUNMODE_INFINITE_RANDOM = "infinite_random"
+	RUNMODE_EXPLORE_CRASHES = "explore_crashes"
+
+	// The name under which the differential monitor records the results
+	// of the main interpreter, which no profile may take
+	DIFFERENTIAL_MAIN_PROFILE = "interpreter"
+
+	// Radamsa mutates each explored crash only once
+	EXPLORE_DEFAULT_PATTERNS = "od"
 
 	INTERPRETER_ARGS_FUZZ_FILE_MARKER     = "XXX_FUZZFILE_XXX"
 	INTERPRETER_ARGS_FUZZ_FILE_DIR_MARKER = "XXX_FUZZFILEDIR_XXX"
+	INTERPRETER_ARGS_FUZZ_DATA_MARKER     = "XXX_FUZZDATA_XXX"
+
+	INPUT_MODE_FILE  = "file"
+	INPUT_MODE_STDIN = "stdin"
+	INPUT_MODE_ARG   = "arg"
+
+	MONITOR_EXITCODE   = "exitcode"
+	MONITOR_PERSISTENT = "persistent"
+	MONITOR_DIFF       = "differential"
+
+	PERSISTENT_DEFAULT_DELIMITER = "XXX_MALAMUTE_END_OF_TEST_XXX"
+
+	TIMEOUT_DEFAULT_MULTIPLIER = 10
+	TIMEOUT_DEFAULT_MIN        = 100 * time.Millisecond
+
+	HANGS_DEFAULT_CONFIRM_MULTIPLIER = 3
+
+	RESOURCES_DEFAULT_MIN_CPU       = time.Second
+	RESOURCES_DEFAULT_MIN_MEMORY_MB = 256
+
+	OUTPUT_DEFAULT_LINES = 200
+	OUTPUT_DEFAULT_BYTES = 64 * 1024
+
+	// The built-in result processors
+	RESULTPROC_ORACLE      = "oracle"
+	RESULTPROC_CLASSIFY    = "classify"
+	RESULTPROC_LINEAGE     = "lineage"
+	RESULTPROC_CRASHDB     = "crashdb"
+	RESULTPROC_IGNORE      = "ignore"
+	RESULTPROC_DEDUP       = "dedup"
+	RESULTPROC_MINIMIZE    = "minimize"
+	RESULTPROC_PRESERVE    = "preserve"
+	RESULTPROC_INTERESTING = "interesting"
+	RESULTPROC_NOTIFY      = "notify"
+
+	MINIMIZE_DEFAULT_MAX_RUNS = 200
+	MINIMIZE_DEFAULT_MAX_TIME = 2 * time.Minute
+
+	CLASSIFIER_DEFAULT_TIMEOUT = 10 * time.Second
+	NOTIFY_DEFAULT_TIMEOUT     = time.Minute
 )
 
+// The chain of result processors used if none is configured
+var RESULTPROC_DEFAULT_CHAIN = []string{RESULTPROC_ORACLE,
+	RESULTPROC_CLASSIFY, RESULTPROC_LINEAGE, RESULTPROC_CRASHDB,
+	RESULTPROC_IGNORE, RESULTPROC_DEDUP, RESULTPROC_MINIMIZE,
+	RESULTPROC_PRESERVE, RESULTPROC_INTERESTING, RESULTPROC_NOTIFY}
+
 type TestProcessingConfig struct {
 	// Fuzzer specifies the fuzzer to use to generate tests from the seed
 	// tests. See the FUZZER_* constants for valid values.
@@ -40,12 +98,41 @@ type TestProcessingConfig struct {
 	// Mode specifies the run mode for seed test processing. It can either
 	// be configured to cover each seed test once (generating BatchSize)
 	// tests for each seed, or to run forever, randomly selecting seed
-	// tests as it goes.
+	// tests as it goes. RUNMODE_EXPLORE_CRASHES runs in the same way as
+	// RUNMODE_INFINITE_RANDOM, but the seeds are the triggers of the
+	// crashes listed in the ExploreCrashes section.
 	Mode string
 	// GenerateTestsInPlace indicates if the test cases should be
 	// generated in the same directory as the seed tests, or in a
 	// a clean directory.
 	GenerateTestsInPlace bool
+	// Workers specifies the number of monitors, and so the number of
+	// interpreters run concurrently. If it is not provided then one
+	// monitor is started per CPU in CpuSet, or two per CPU if CpuSet is
+	// not provided either.
+	Workers int
+	// CpuSet, if provided, lists the CPUs that the monitors are pinned to,
+	// e.g. "0-7,16". Monitors are assigned CPUs in turn. If it is not
+	// provided then the monitors are not pinned.
+	CpuSet string
+}
+
+// DifferentialProfile describes an alternative way of running the
+// interpreter, whose output is compared against that of the main
+// interpreter by the differential monitor.
+type DifferentialProfile struct {
+	// Path specifies the interpreter to use. If it is not provided then
+	// the main interpreter is used.
+	Path string
+	// Args is an interpreter arguments string, as described on
+	// Interpreter.Args
+	Args string
+	// ArgGen is the name of an argument generator, as described on
+	// Interpreter.ArgGen
+	ArgGen string
+	// InputMode specifies how each test case is provided to the
+	// interpreter, as described on Interpreter.InputMode
+	InputMode string
 }
 
 type Config struct {
@@ -81,18 +168,35 @@ type Config struct {
 		Mutations string
 	}
 
+	ExploreCrashes struct {
+		// CrashDir lists the preserved crash directories whose triggers
+		// are used as the seeds in RUNMODE_EXPLORE_CRASHES. Each must hold
+		// the bugdesc.json written when the crash was preserved. Bugs
+		// found are bucketed relative to the crash they descend from.
+		CrashDir []string
+		// Patterns is the mutation pattern argument passed to radamsa
+		// when exploring crashes, so that the tests generated stay close
+		// to their parent crash. See the output of the `radamsa -l`
+		// command for details. It defaults to EXPLORE_DEFAULT_PATTERNS.
+		Patterns string
+	}
+
 	Interpreter struct {
 		// Path specifies the path to the interpreter that will be
 		// used to process each test case
 		Path string
 		// Args is a string specifying the arguments to be provided
 		// to the interpreter. The string provided by the constant
-		// INTERPRETER_ARGS_FUZZ_FILE_MARKER should appear at least once. This
-		// marker will be replaced by the path to the test case on each
-		// invocation of the interpreter. The string provided by the constant
+		// INTERPRETER_ARGS_FUZZ_FILE_MARKER should appear at least once if
+		// the input mode is INPUT_MODE_FILE. This marker will be replaced by
+		// the path to the test case on each invocation of the interpreter.
+		// The string provided by the constant
 		// INTERPRETER_ARGS_FUZZ_FILE_DIR_MARKER may appear any number of
 		// times, and will be replaced by the directory in which the test
-		// resides.
+		// resides. If the input mode is INPUT_MODE_ARG then the string
+		// provided by the constant INTERPRETER_ARGS_FUZZ_DATA_MARKER should
+		// appear at least once, and will be replaced by the contents of the
+		// test case.
 		Args string
 		// ArgGen specifies the name of an argument generator function. Such
 		// functions are provided by malamute to dynamically generate the
@@ -106,9 +210,312 @@ type Config struct {
 		// If ArgGen is provided then this may also be provided, and will be
 		// passed to the argument generator, for its use
 		TestCaseRootDir string
-		// Timeout indicates the maximum run time, in seconds, of a single
-		// instantiation of the interpreter
-		Timeout int
+		// Timeout indicates the maximum run time of a single instantiation
+		// of the interpreter. It is given as a number of seconds, or as a
+		// duration such as 500ms. If timeouts are calibrated then this is
+		// the timeout for the pre-flight run, and for tests generated from
+		// seeds that have no calibrated timeout.
+		Timeout Duration
+		// InputMode specifies how each test case is provided to the
+		// interpreter. See the INPUT_MODE_* constants for valid values. If
+		// it is not provided then the path to the test case is passed in
+		// the arguments.
+		InputMode string
+		// Monitor specifies how the interpreter is executed on each test
+		// case. See the MONITOR_* constants for valid values. If it is not
+		// provided then a new interpreter is started for every test case.
+		Monitor string
+	}
+
+	Coverage struct {
+		// Enabled indicates that the interpreter has been built with
+		// -fsanitize-coverage, and that the coverage of each test case
+		// should be collected. Test cases that reach new edges are saved
+		// into the corpus directory of the session.
+		Enabled bool
+		// CorpusSelectionPercent specifies the percentage of seeds that
+		// are drawn from the corpus, rather than from the seed tests, when
+		// running in the infinite random mode. If it is not provided then
+		// half of the seeds are drawn from the corpus.
+		CorpusSelectionPercent int
+	}
+
+	Profile struct {
+		// Enabled indicates that the interpreter has been built with
+		// -fprofile-instr-generate. Each run of the interpreter will write
+		// its profile into the profiles directory of the session, and these
+		// are periodically merged to produce a source coverage report.
+		Enabled bool
+		// MergeInterval specifies the number of test cases processed
+		// between each merge of the profiles. If it is not provided then
+		// the profiles are merged after every batch.
+		MergeInterval int
+		// ProfdataPath specifies the path to llvm-profdata. If it is not
+		// provided then it is expected to be found in $PATH.
+		ProfdataPath string
+		// CovPath specifies the path to llvm-cov. If it is not provided then
+		// it is expected to be found in $PATH.
+		CovPath string
+	}
+
+	// Differential holds the profiles the differential monitor runs each
+	// test case under, in addition to the main interpreter. Each is given
+	// in its own sub-section, e.g. [Differential "ioneager"].
+	Differential map[string]*DifferentialProfile
+
+	Oracle struct {
+		// Enabled indicates that the result of each test should be
+		// compared against the expected result of its seed, where one is
+		// known. Seeds that are run unmutated, e.g. by the nop fuzzer,
+		// and do not produce the expected result are reported as
+		// regressions. See the oracle package for the supported formats.
+		Enabled bool
+	}
+
+	// Normalize describes how interpreter output is normalized before it
+	// is compared, or hashed to bucket crashes
+	Normalize struct {
+		// Preset selects a built-in set of rules for a particular
+		// interpreter. See the normalize.PRESET_* constants for valid
+		// values. It may be left empty. Addresses and process IDs are
+		// normalized whatever the preset.
+		Preset string
+		// Replace provides extra replacement rules, applied after those of
+		// the preset. Each is of the form <regexp> => <replacement>, and
+		// the replacement may refer to sub-matches using $1 etc. This
+		// option may be repeated.
+		Replace []string
+		// DropLine provides regular expressions matching lines that are
+		// removed from the output entirely. This option may be repeated.
+		DropLine []string
+		// FloatPrecision, if not 0, specifies the number of significant
+		// digits that decimal numbers are rounded to
+		FloatPrecision int
+	}
+
+	// Timeout describes how a timeout is calibrated for each seed from the
+	// time it took to run during the pre-flight run
+	Timeout struct {
+		// Calibrate indicates that timeouts should be calibrated. It
+		// requires the pre-flight run.
+		Calibrate bool
+		// Multiplier specifies the multiple of its pre-flight run time
+		// that tests generated from a seed may run for. It defaults to
+		// TIMEOUT_DEFAULT_MULTIPLIER.
+		Multiplier int
+		// Min specifies the minimum calibrated timeout. It defaults to
+		// TIMEOUT_DEFAULT_MIN.
+		Min Duration
+		// Max specifies the maximum calibrated timeout. It defaults to the
+		// interpreter timeout.
+		Max Duration
+	}
+
+	Hangs struct {
+		// Preserve indicates that tests that time out should be re-run
+		// with a longer timeout, and those that time out again stored in
+		// the hangs directory of the session
+		Preserve bool
+		// ConfirmMultiplier specifies the multiple of the original timeout
+		// used when re-running a test. It defaults to
+		// HANGS_DEFAULT_CONFIRM_MULTIPLIER.
+		ConfirmMultiplier int
+	}
+
+	// Resources describes when a test that uses far more resources than its
+	// seed is reported. The resources used by each seed are taken from the
+	// pre-flight run.
+	Resources struct {
+		// CPUMultiplier, if not 0, specifies the multiple of the CPU time
+		// of its seed above which a test is reported as a performance
+		// anomaly
+		CPUMultiplier int
+		// MinCPU specifies the CPU time below which a test is never
+		// reported, however quick its seed. It defaults to
+		// RESOURCES_DEFAULT_MIN_CPU.
+		MinCPU Duration
+		// MemoryMultiplier, if not 0, specifies the multiple of the peak
+		// memory usage of its seed above which a test is reported as a
+		// memory anomaly
+		MemoryMultiplier int
+		// MinMemoryMb specifies the peak memory usage, in megabytes,
+		// below which a test is never reported. It defaults to
+		// RESOURCES_DEFAULT_MIN_MEMORY_MB.
+		MinMemoryMb int
+	}
+
+	Cores struct {
+		// Enabled indicates that interpreters should dump core when they
+		// crash, and that a backtrace should be produced from the core of
+		// each preserved crash. The kernel core pattern must be set to
+		// write cores to the current directory, e.g. 'core'.
+		Enabled bool
+		// GdbPath specifies the path to gdb. If it is not provided then
+		// it is expected to be found in $PATH.
+		GdbPath string
+		// KeepCores indicates that cores should be stored alongside
+		// preserved crashes, rather than deleted once a backtrace has been
+		// produced
+		KeepCores bool
+	}
+
+	Symbolize struct {
+		// Enabled indicates that raw frames in the stack traces of crashes,
+		// of the form (module+0x1234), should be resolved against an
+		// unstripped build of the interpreter. This is for when the
+		// interpreter is stripped, or run without a symbolizer.
+		Enabled bool
+		// Binary specifies the path to the unstripped build of the
+		// interpreter. It is also given to gdb in place of the interpreter
+		// when producing backtraces from cores.
+		Binary string
+		// Module specifies the name of the module whose frames are
+		// resolved. If it is not provided then the base name of the
+		// interpreter path is used.
+		Module string
+		// SymbolizerPath specifies the path to llvm-symbolizer or
+		// addr2line. If it is not provided then llvm-symbolizer, and
+		// failing that addr2line, are expected to be found in $PATH.
+		SymbolizerPath string
+	}
+
+	Preflight struct {
+		// Skip indicates that seeds should not be run once, unmutated,
+		// before fuzzing starts. By default they are, and any that crash
+		// or time out are quarantined and not used as seeds. Bugs that
+		// seeds trigger are preserved as they would be while fuzzing.
+		Skip bool
+		// QuarantineFailures indicates that seeds that exit with a
+		// non-zero exit code, e.g. because they fail to parse, should be
+		// quarantined as well
+		QuarantineFailures bool
+	}
+
+	ResultProcessing struct {
+		// Chain lists the result processors that each test case is
+		// passed through once it has been executed, in order. This option
+		// may be repeated. It defaults to RESULTPROC_DEFAULT_CHAIN. See
+		// the RESULTPROC_* constants for the built-in processors, and
+		// resultproc.Register for adding others.
+		Chain []string
+	}
+
+	Ignore struct {
+		// File, if not empty, is the path to a list of known bugs. Bugs
+		// matching an entry are counted in the stats, but dropped rather
+		// than preserved. Each line of the file holds an entry as fields
+		// separated by tabs: the kind of match, the pattern, a note and,
+		// optionally, a bug URL. The kind is one of
+		//	- bucket : The pattern is a bucket signature
+		//	- assert : The pattern is found in the assertion failure
+		//		message
+		//	- stderr : The pattern is a regular expression matching a
+		//		line of stderr
+		// Blank lines and lines starting with # are skipped.
+		File string
+	}
+
+	Dedup struct {
+		// MaxPerBucket, if not 0, specifies the number of crashes that
+		// are preserved from each bucket. Any others are dropped.
+		MaxPerBucket int
+	}
+
+	Minimize struct {
+		// Enabled indicates that crashing test cases should be reduced, by
+		// removing lines for as long as the crash still reproduces. The
+		// reduced test case is preserved alongside the original.
+		Enabled bool
+		// MaxRuns specifies the maximum number of times the interpreter
+		// is run while reducing a single test case. It defaults to
+		// MINIMIZE_DEFAULT_MAX_RUNS.
+		MaxRuns int
+		// MaxTime specifies how long reducing a single test case may take,
+		// as the chain is held up while it is. It defaults to
+		// MINIMIZE_DEFAULT_MAX_TIME.
+		MaxTime Duration
+	}
+
+	Classifier struct {
+		// Command, if not empty, is run for every candidate bug with the
+		// test case, serialized as JSON, on its STDIN. It must print a
+		// JSON verdict, as described on resultproc.ClassifierVerdict,
+		// which may reject the bug or override its bucket and severity.
+		// It is run by the RESULTPROC_CLASSIFY result processor.
+		Command string
+		// AllTests indicates that the command should be run for every
+		// test case, so that it may also report bugs that were not
+		// otherwise detected
+		AllTests bool
+		// Timeout specifies how long the command may run for. It defaults
+		// to CLASSIFIER_DEFAULT_TIMEOUT.
+		Timeout Duration
+	}
+
+	Interesting struct {
+		// Enabled indicates that test cases which do not trigger a bug,
+		// but behave in a way not seen before, should be kept in the
+		// interesting directory of the session. A test case is novel if
+		// it exits with a new exit code, if the first line it writes to
+		// stderr is new once normalized, or if it covers new edges. Those
+		// already in the corpus are linked to rather than copied.
+		Enabled bool
+		// Mutate indicates that interesting test cases should be mutated
+		// along with the seeds, in the same way as the corpus
+		Mutate bool
+	}
+
+	Notify struct {
+		// Command, if not empty, is run for each preserved bug, with the
+		// directory the bug was preserved to appended to its arguments.
+		// The class, bucket and severity of the bug are provided in the
+		// MALAMUTE_BUG_CLASS, MALAMUTE_BUCKET and MALAMUTE_SEVERITY
+		// environment variables, and its crash database label, if any, in
+		// MALAMUTE_CRASHDB_LABEL.
+		Command string
+		// Timeout specifies how long the command may run for. It defaults
+		// to NOTIFY_DEFAULT_TIMEOUT.
+		Timeout Duration
+	}
+
+	CrashDB struct {
+		// Dir, if not empty, is the directory of a crash database shared
+		// by sessions. Every preserved crash is added to it by bucket, and
+		// crashes are labelled as new or known depending on whether their
+		// bucket is already in it, by the RESULTPROC_CRASHDB result
+		// processor. See the crashdb package.
+		Dir string
+	}
+
+	Output struct {
+		// HeadLines and HeadBytes limit the output kept from the start of
+		// each stream written by the interpreter. They default to
+		// OUTPUT_DEFAULT_LINES and OUTPUT_DEFAULT_BYTES.
+		HeadLines int
+		HeadBytes int
+		// TailLines and TailBytes limit the output kept from the end of
+		// each stream, where sanitizer reports are found. They default to
+		// OUTPUT_DEFAULT_LINES and OUTPUT_DEFAULT_BYTES.
+		TailLines int
+		TailBytes int
+		// NoSpill indicates that output which does not fit within the
+		// limits should be discarded. By default the full output is
+		// written to a file, which is kept alongside preserved bugs.
+		NoSpill bool
+	}
+
+	Persistent struct {
+		// Delimiter is the line used to separate test cases when they are
+		// fed to a persistent interpreter. It is the first line written to
+		// the interpreter on start up. The interpreter, or a harness script
+		// run by it, must read test cases from STDIN until it sees this
+		// line, run the test, and then print the line to both STDOUT and
+		// STDERR.
+		Delimiter string
+		// RecycleCount, if not 0, specifies the number of test cases after
+		// which a persistent interpreter will be restarted, in order to
+		// limit how much state one test can leave behind for the next.
+		RecycleCount int
 	}
 }
 
@@ -136,7 +543,16 @@ func errorCheck(cfg *Config) error {
 	}
 
 	// SeedTests
-	if len(cfg.SeedTests.Dir) == 0 && len(cfg.SeedTests.ListFile) == 0 {
+	exploring := strings.ToLower(cfg.TestProcessing.Mode) ==
+		RUNMODE_EXPLORE_CRASHES
+	if exploring && (len(cfg.SeedTests.Dir) != 0 ||
+		len(cfg.SeedTests.ListFile) != 0) {
+		return errors.New("Seed tests cannot be specified when exploring " +
+			"crashes, as the crash triggers are the seeds")
+	}
+
+	if !exploring && len(cfg.SeedTests.Dir) == 0 &&
+		len(cfg.SeedTests.ListFile) == 0 {
 		return errors.New("Seed tests must be specified via a directory" +
 			" or list file")
 	}
@@ -179,19 +595,87 @@ func errorCheck(cfg *Config) error {
 
 	cfg.TestProcessing.Mode = strings.ToLower(cfg.TestProcessing.Mode)
 	if cfg.TestProcessing.Mode != RUNMODE_COVER_ALL_ONCE &&
-		cfg.TestProcessing.Mode != RUNMODE_INFINITE_RANDOM {
+		cfg.TestProcessing.Mode != RUNMODE_INFINITE_RANDOM &&
+		cfg.TestProcessing.Mode != RUNMODE_EXPLORE_CRASHES {
 		return errors.New(fmt.Sprintf("Invalid mode %s",
 			cfg.TestProcessing.Mode))
 	}
 
+	// ExploreCrashes
+	if exploring {
+		if len(cfg.ExploreCrashes.CrashDir) == 0 {
+			return errors.New("One or more crash directories must be " +
+				"provided to explore crashes")
+		}
+
+		for _, dir := range cfg.ExploreCrashes.CrashDir {
+			if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+				return errors.New(fmt.Sprintf("The crash directory %s "+
+					"does not exist", dir))
+			}
+		}
+
+		if cfg.TestProcessing.GenerateTestsInPlace {
+			return errors.New("Tests cannot be generated in place when " +
+				"exploring crashes, as the crash directories would be " +
+				"filled with them")
+		}
+
+		if len(cfg.ExploreCrashes.Patterns) == 0 {
+			cfg.ExploreCrashes.Patterns = EXPLORE_DEFAULT_PATTERNS
+		}
+	}
+
+	if cfg.TestProcessing.Workers < 0 {
+		return errors.New("The number of workers cannot be negative")
+	}
+
+	if len(cfg.TestProcessing.CpuSet) != 0 {
+		if _, err := ParseCpuSet(cfg.TestProcessing.CpuSet); err != nil {
+			return err
+		}
+	}
+
 	// Interpreter
 	if len(cfg.Interpreter.Path) == 0 {
 		return errors.New("You must specify an interpreter path")
 	}
 
+	cfg.Interpreter.Monitor = strings.ToLower(cfg.Interpreter.Monitor)
+	if len(cfg.Interpreter.Monitor) == 0 {
+		cfg.Interpreter.Monitor = MONITOR_EXITCODE
+	}
+
+	if cfg.Interpreter.Monitor != MONITOR_EXITCODE &&
+		cfg.Interpreter.Monitor != MONITOR_PERSISTENT &&
+		cfg.Interpreter.Monitor != MONITOR_DIFF {
+		return fmt.Errorf("Invalid monitor %s", cfg.Interpreter.Monitor)
+	}
+
+	cfg.Interpreter.InputMode = strings.ToLower(cfg.Interpreter.InputMode)
+	if len(cfg.Interpreter.InputMode) == 0 {
+		cfg.Interpreter.InputMode = INPUT_MODE_FILE
+	}
+
+	if cfg.Interpreter.InputMode != INPUT_MODE_FILE &&
+		cfg.Interpreter.InputMode != INPUT_MODE_STDIN &&
+		cfg.Interpreter.InputMode != INPUT_MODE_ARG {
+		return fmt.Errorf("Invalid input mode %s",
+			cfg.Interpreter.InputMode)
+	}
+
 	usingArgs := len(cfg.Interpreter.Args) != 0
 	usingArgGen := len(cfg.Interpreter.ArgGen) != 0
-	if !usingArgs && !usingArgGen {
+	usingPersistent := cfg.Interpreter.Monitor == MONITOR_PERSISTENT
+	if usingPersistent && usingArgGen {
+		return errors.New("An argument generator cannot be used with a " +
+			"persistent interpreter as it is only started once")
+	}
+
+	// When reading from STDIN the test case may be the only input, in
+	// which case no arguments are needed
+	usingStdin := cfg.Interpreter.InputMode == INPUT_MODE_STDIN
+	if !usingArgs && !usingArgGen && !usingPersistent && !usingStdin {
 		return errors.New("An interpreter arguments string XOR an " +
 			"argument generator must be provided")
 	}
@@ -201,16 +685,32 @@ func errorCheck(cfg *Config) error {
 			"argument generator must be provided")
 	}
 
-	if usingArgs && !strings.Contains(cfg.Interpreter.Args,
-		INTERPRETER_ARGS_FUZZ_FILE_MARKER) {
+	if usingPersistent && cfg.Interpreter.InputMode != INPUT_MODE_FILE {
+		return errors.New("A persistent interpreter is always fed test " +
+			"cases over STDIN, so no input mode should be set")
+	}
+
+	if usingArgGen && cfg.Interpreter.InputMode != INPUT_MODE_FILE {
+		return errors.New("An argument generator can only be used with " +
+			"the file input mode")
+	}
+
+	if usingArgs && !usingPersistent &&
+		cfg.Interpreter.InputMode == INPUT_MODE_FILE && !strings.Contains(
+		cfg.Interpreter.Args, INTERPRETER_ARGS_FUZZ_FILE_MARKER) {
 		return errors.New(fmt.Sprintf("The provided interpreter "+
 			"arguments string (%s) does not contain the correct fuzz file "+
 			"marker", cfg.Interpreter.Args))
 	}
 
-	if usingArgGen && !(cfg.Interpreter.ArgGen == arggen.FF_JSREFTEST ||
-		cfg.Interpreter.ArgGen == arggen.FF_JSREFTEST_IONEAGER ||
-		cfg.Interpreter.ArgGen == arggen.D8_JSREFTEST) {
+	if cfg.Interpreter.InputMode == INPUT_MODE_ARG && !strings.Contains(
+		cfg.Interpreter.Args, INTERPRETER_ARGS_FUZZ_DATA_MARKER) {
+		return errors.New(fmt.Sprintf("The provided interpreter "+
+			"arguments string (%s) does not contain the fuzz data marker",
+			cfg.Interpreter.Args))
+	}
+
+	if usingArgGen && !isValidArgGen(cfg.Interpreter.ArgGen) {
 		return errors.New(fmt.Sprintf("Invalid argument generator: %s",
 			cfg.Interpreter.ArgGen))
 	}
@@ -226,9 +726,374 @@ func errorCheck(cfg *Config) error {
 		}
 	}
 
-	if cfg.Interpreter.Timeout == 0 {
+	if cfg.Interpreter.Timeout.Duration <= 0 {
 		return errors.New("You must specify the interpreter timeout")
 	}
 
+	// Differential
+	if cfg.Interpreter.Monitor == MONITOR_DIFF &&
+		len(cfg.Differential) == 0 {
+		return errors.New("At least one differential profile must be " +
+			"provided when using the differential monitor")
+	}
+
+	for name, profile := range cfg.Differential {
+		if err := checkDifferentialProfile(cfg, name, profile); err != nil {
+			return err
+		}
+	}
+
+	// Oracle
+	if cfg.Oracle.Enabled && usingPersistent {
+		return errors.New("The oracle requires the interpreter to exit " +
+			"after each test case, as the exit code is part of the " +
+			"expected result")
+	}
+	for _, ext := range cfg.SeedTests.ValidExts {
+		if cfg.Oracle.Enabled && ext == oracle.PHPT_EXT {
+			return errors.New("The oracle cannot use .phpt files as seeds, " +
+				"as they are not scripts. Use the .php files extracted by " +
+				"run-tests.php instead.")
+		}
+	}
+
+	// Timeout
+	if cfg.Timeout.Calibrate && cfg.Preflight.Skip {
+		return errors.New("Timeouts can only be calibrated if the " +
+			"pre-flight run is not skipped")
+	}
+
+	if cfg.Timeout.Multiplier < 0 || cfg.Timeout.Min.Duration < 0 ||
+		cfg.Timeout.Max.Duration < 0 {
+		return errors.New("The timeout multiplier, minimum and maximum " +
+			"cannot be negative")
+	}
+
+	if cfg.Timeout.Multiplier == 0 {
+		cfg.Timeout.Multiplier = TIMEOUT_DEFAULT_MULTIPLIER
+	}
+
+	if cfg.Timeout.Min.Duration == 0 {
+		cfg.Timeout.Min.Duration = TIMEOUT_DEFAULT_MIN
+	}
+
+	if cfg.Timeout.Max.Duration == 0 {
+		cfg.Timeout.Max = cfg.Interpreter.Timeout
+	}
+
+	if cfg.Timeout.Min.Duration > cfg.Timeout.Max.Duration {
+		return errors.New("The minimum timeout cannot be greater than the " +
+			"maximum")
+	}
+
+	// Hangs
+	if cfg.Hangs.Preserve && usingPersistent {
+		return errors.New("Hangs can only be preserved when the " +
+			"interpreter exits after each test case")
+	}
+
+	if cfg.Hangs.ConfirmMultiplier < 0 {
+		return errors.New("The hang confirmation multiplier cannot be " +
+			"negative")
+	}
+
+	if cfg.Hangs.ConfirmMultiplier == 0 {
+		cfg.Hangs.ConfirmMultiplier = HANGS_DEFAULT_CONFIRM_MULTIPLIER
+	}
+
+	// Resources
+	resources := &cfg.Resources
+	if resources.CPUMultiplier < 0 || resources.MemoryMultiplier < 0 ||
+		resources.MinCPU.Duration < 0 || resources.MinMemoryMb < 0 {
+		return errors.New("Resource limits cannot be negative")
+	}
+
+	if (resources.CPUMultiplier != 0 || resources.MemoryMultiplier != 0) &&
+		(cfg.Preflight.Skip || usingPersistent) {
+		return errors.New("Resource anomalies can only be detected when " +
+			"the pre-flight run is not skipped, and the interpreter exits " +
+			"after each test case")
+	}
+
+	if resources.MinCPU.Duration == 0 {
+		resources.MinCPU.Duration = RESOURCES_DEFAULT_MIN_CPU
+	}
+
+	if resources.MinMemoryMb == 0 {
+		resources.MinMemoryMb = RESOURCES_DEFAULT_MIN_MEMORY_MB
+	}
+
+	// Cores
+	if cfg.Cores.Enabled && usingPersistent {
+		return errors.New("Cores can only be captured when the " +
+			"interpreter exits after each test case")
+	}
+
+	if len(cfg.Cores.GdbPath) == 0 {
+		cfg.Cores.GdbPath = "gdb"
+	}
+
+	// Symbolize
+	if cfg.Symbolize.Enabled {
+		if len(cfg.Symbolize.Binary) == 0 {
+			return errors.New("You must specify the unstripped binary to " +
+				"symbolize against")
+		}
+
+		if _, err := os.Stat(cfg.Symbolize.Binary); err != nil {
+			return errors.New(fmt.Sprintf("Error reading %s, %s",
+				cfg.Symbolize.Binary, err))
+		}
+
+		if len(cfg.Symbolize.Module) == 0 {
+			cfg.Symbolize.Module = filepath.Base(cfg.Interpreter.Path)
+		}
+	}
+
+	// ResultProcessing
+	if len(cfg.ResultProcessing.Chain) == 0 {
+		cfg.ResultProcessing.Chain = append([]string{},
+			RESULTPROC_DEFAULT_CHAIN...)
+	}
+
+	for i, name := range cfg.ResultProcessing.Chain {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if len(name) == 0 {
+			return errors.New("The result processor chain cannot contain " +
+				"an empty name")
+		}
+		cfg.ResultProcessing.Chain[i] = name
+	}
+
+	if len(cfg.Ignore.File) != 0 {
+		if _, err := os.Stat(cfg.Ignore.File); err != nil {
+			return errors.New(fmt.Sprintf("The ignore list %s does not "+
+				"exist", cfg.Ignore.File))
+		}
+
+		if !inChain(cfg, RESULTPROC_IGNORE) {
+			return errors.New(fmt.Sprintf("The %s result processor must be "+
+				"in the chain to use an ignore list", RESULTPROC_IGNORE))
+		}
+	}
+
+	if len(cfg.Classifier.Command) != 0 &&
+		!inChain(cfg, RESULTPROC_CLASSIFY) {
+		return errors.New(fmt.Sprintf("The %s result processor must be in "+
+			"the chain to use a classifier", RESULTPROC_CLASSIFY))
+	}
+
+	if len(cfg.CrashDB.Dir) != 0 && !inChain(cfg, RESULTPROC_CRASHDB) {
+		return errors.New(fmt.Sprintf("The %s result processor must be in "+
+			"the chain to use a crash database", RESULTPROC_CRASHDB))
+	}
+
+	if exploring && !inChain(cfg, RESULTPROC_LINEAGE) {
+		return errors.New(fmt.Sprintf("The %s result processor must be in "+
+			"the chain to explore crashes", RESULTPROC_LINEAGE))
+	}
+
+	if cfg.Classifier.Timeout.Duration < 0 {
+		return errors.New("The classifier timeout cannot be negative")
+	}
+
+	if cfg.Classifier.Timeout.Duration == 0 {
+		cfg.Classifier.Timeout.Duration = CLASSIFIER_DEFAULT_TIMEOUT
+	}
+
+	if cfg.Notify.Timeout.Duration < 0 {
+		return errors.New("The notify timeout cannot be negative")
+	}
+
+	if cfg.Notify.Timeout.Duration == 0 {
+		cfg.Notify.Timeout.Duration = NOTIFY_DEFAULT_TIMEOUT
+	}
+
+	if cfg.Interesting.Mutate && !cfg.Interesting.Enabled {
+		return errors.New("Interesting test cases can only be mutated " +
+			"if they are kept")
+	}
+
+	if cfg.Dedup.MaxPerBucket < 0 {
+		return errors.New("The number of crashes preserved per bucket " +
+			"cannot be negative")
+	}
+
+	if cfg.Minimize.Enabled && usingPersistent {
+		return errors.New("Test cases can only be minimized when the " +
+			"interpreter exits after each test case")
+	}
+
+	if cfg.Minimize.MaxRuns < 0 {
+		return errors.New("The maximum number of minimization runs " +
+			"cannot be negative")
+	}
+
+	if cfg.Minimize.MaxRuns == 0 {
+		cfg.Minimize.MaxRuns = MINIMIZE_DEFAULT_MAX_RUNS
+	}
+
+	if cfg.Minimize.MaxTime.Duration < 0 {
+		return errors.New("The maximum minimization time cannot be " +
+			"negative")
+	}
+
+	if cfg.Minimize.MaxTime.Duration == 0 {
+		cfg.Minimize.MaxTime.Duration = MINIMIZE_DEFAULT_MAX_TIME
+	}
+
+	// Output
+	output := &cfg.Output
+	if output.HeadLines < 0 || output.HeadBytes < 0 ||
+		output.TailLines < 0 || output.TailBytes < 0 {
+		return errors.New("Output limits cannot be negative")
+	}
+
+	if output.HeadLines == 0 {
+		output.HeadLines = OUTPUT_DEFAULT_LINES
+	}
+
+	if output.HeadBytes == 0 {
+		output.HeadBytes = OUTPUT_DEFAULT_BYTES
+	}
+
+	if output.TailLines == 0 {
+		output.TailLines = OUTPUT_DEFAULT_LINES
+	}
+
+	if output.TailBytes == 0 {
+		output.TailBytes = OUTPUT_DEFAULT_BYTES
+	}
+
+	// Normalize
+	cfg.Normalize.Preset = strings.ToLower(cfg.Normalize.Preset)
+	if _, err := normalize.New(cfg.Normalize.Preset, cfg.Normalize.Replace,
+		cfg.Normalize.DropLine, cfg.Normalize.FloatPrecision); err != nil {
+		return err
+	}
+
+	// Coverage
+	if cfg.Coverage.Enabled && usingPersistent {
+		return errors.New("Coverage can only be collected when the " +
+			"interpreter exits after each test case")
+	}
+
+	if cfg.Coverage.CorpusSelectionPercent < 0 ||
+		cfg.Coverage.CorpusSelectionPercent > 100 {
+		return errors.New("The corpus selection percentage must be " +
+			"between 0 and 100")
+	}
+
+	if (cfg.Coverage.Enabled || cfg.Interesting.Mutate) &&
+		cfg.Coverage.CorpusSelectionPercent == 0 {
+		cfg.Coverage.CorpusSelectionPercent = 50
+	}
+
+	// Profile
+	if cfg.Profile.MergeInterval < 0 {
+		return errors.New("The profile merge interval cannot be negative")
+	}
+
+	if cfg.Profile.MergeInterval == 0 {
+		cfg.Profile.MergeInterval = cfg.TestProcessing.BatchSize
+	}
+
+	if len(cfg.Profile.ProfdataPath) == 0 {
+		cfg.Profile.ProfdataPath = "llvm-profdata"
+	}
+
+	if len(cfg.Profile.CovPath) == 0 {
+		cfg.Profile.CovPath = "llvm-cov"
+	}
+
+	// Persistent
+	if len(cfg.Persistent.Delimiter) == 0 {
+		cfg.Persistent.Delimiter = PERSISTENT_DEFAULT_DELIMITER
+	}
+
+	if cfg.Persistent.RecycleCount < 0 {
+		return errors.New("The persistent recycle count cannot be negative")
+	}
+
+	return nil
+}
+
+func isValidArgGen(argGen string) bool {
+	return argGen == arggen.FF_JSREFTEST ||
+		argGen == arggen.FF_JSREFTEST_IONEAGER ||
+		argGen == arggen.D8_JSREFTEST
+}
+
+// inChain indicates if the result processor called name is in the chain
+func inChain(cfg *Config, name string) bool {
+	for _, stage := range cfg.ResultProcessing.Chain {
+		if stage == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkDifferentialProfile checks a single differential profile for errors,
+// filling in any defaults from the main interpreter
+func checkDifferentialProfile(cfg *Config, name string,
+	profile *DifferentialProfile) error {
+
+	if strings.EqualFold(name, DIFFERENTIAL_MAIN_PROFILE) {
+		return fmt.Errorf("The differential profile name %s is reserved "+
+			"for the main interpreter", name)
+	}
+
+	if len(profile.Path) == 0 {
+		profile.Path = cfg.Interpreter.Path
+	}
+
+	profile.InputMode = strings.ToLower(profile.InputMode)
+	if len(profile.InputMode) == 0 {
+		profile.InputMode = INPUT_MODE_FILE
+	}
+
+	if profile.InputMode != INPUT_MODE_FILE &&
+		profile.InputMode != INPUT_MODE_STDIN &&
+		profile.InputMode != INPUT_MODE_ARG {
+		return fmt.Errorf("Invalid input mode %s for differential "+
+			"profile %s", profile.InputMode, name)
+	}
+
+	usingArgs := len(profile.Args) != 0
+	usingArgGen := len(profile.ArgGen) != 0
+	if (usingArgs && usingArgGen) || (!usingArgs && !usingArgGen &&
+		profile.InputMode != INPUT_MODE_STDIN) {
+		return fmt.Errorf("An arguments string XOR an argument generator "+
+			"must be provided for differential profile %s", name)
+	}
+
+	if usingArgGen && !isValidArgGen(profile.ArgGen) {
+		return fmt.Errorf("Invalid argument generator %s for differential "+
+			"profile %s", profile.ArgGen, name)
+	}
+
+	if usingArgGen && profile.InputMode != INPUT_MODE_FILE {
+		return fmt.Errorf("An argument generator can only be used with "+
+			"the file input mode, in differential profile %s", name)
+	}
+
+	if usingArgGen && len(cfg.Interpreter.TestCaseRootDir) == 0 {
+		return errors.New("You must specify the test case root directory")
+	}
+
+	if profile.InputMode == INPUT_MODE_FILE && usingArgs &&
+		!strings.Contains(profile.Args, INTERPRETER_ARGS_FUZZ_FILE_MARKER) {
+		return fmt.Errorf("The arguments string of differential profile "+
+			"%s does not contain the correct fuzz file marker", name)
+	}
+
+	if profile.InputMode == INPUT_MODE_ARG &&
+		!strings.Contains(profile.Args, INTERPRETER_ARGS_FUZZ_DATA_MARKER) {
+		return fmt.Errorf("The arguments string of differential profile "+
+			"%s does not contain the fuzz data marker", name)
+	}
+
 	return nil
 }
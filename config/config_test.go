@@ -0,0 +1,53 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestCheckDifferentialProfile(t *testing.T) {
+	tests := []struct {
+		name     string
+		profile  DifferentialProfile
+		wantErr  bool
+		wantMode string
+	}{
+		{"jit", DifferentialProfile{Args: INTERPRETER_ARGS_FUZZ_FILE_MARKER},
+			false, INPUT_MODE_FILE},
+		{"stdin", DifferentialProfile{InputMode: "STDIN"}, false,
+			INPUT_MODE_STDIN},
+		{DIFFERENTIAL_MAIN_PROFILE, DifferentialProfile{
+			Args: INTERPRETER_ARGS_FUZZ_FILE_MARKER}, true, ""},
+		{"Interpreter", DifferentialProfile{
+			Args: INTERPRETER_ARGS_FUZZ_FILE_MARKER}, true, ""},
+		{"no args", DifferentialProfile{}, true, ""},
+		{"no marker", DifferentialProfile{Args: "-n"}, true, ""},
+		{"bad mode", DifferentialProfile{InputMode: "pipe",
+			Args: INTERPRETER_ARGS_FUZZ_FILE_MARKER}, true, ""},
+	}
+
+	for _, test := range tests {
+		cfg := &Config{}
+		cfg.Interpreter.Path = "/usr/bin/php"
+		profile := test.profile
+
+		err := checkDifferentialProfile(cfg, test.name, &profile)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: err = %v, want error %v", test.name, err,
+				test.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		// Defaults are taken from the main interpreter
+		if profile.Path != cfg.Interpreter.Path {
+			t.Errorf("%s: Path = %q, want %q", test.name, profile.Path,
+				cfg.Interpreter.Path)
+		}
+		if profile.InputMode != test.wantMode {
+			t.Errorf("%s: InputMode = %q, want %q", test.name,
+				profile.InputMode, test.wantMode)
+		}
+	}
+}
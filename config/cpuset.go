@@ -0,0 +1,53 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// The number of CPUs that can be named in a CPU set
+	MAX_CPUS = 1024
+)
+
+// ParseCpuSet parses a list of CPUs in the format used by taskset and
+// cpusets, e.g. "0-3,8,10-11", and returns the CPUs in the order given
+func ParseCpuSet(spec string) ([]int, error) {
+	cpus := []int{}
+	seen := make(map[int]bool)
+
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		bounds := strings.SplitN(part, "-", 2)
+
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid CPU '%s' in CPU "+
+				"set %s", bounds[0], spec))
+		}
+
+		last := first
+		if len(bounds) == 2 {
+			if last, err = strconv.Atoi(bounds[1]); err != nil {
+				return nil, errors.New(fmt.Sprintf("Invalid CPU '%s' in "+
+					"CPU set %s", bounds[1], spec))
+			}
+		}
+
+		if first < 0 || last >= MAX_CPUS || first > last {
+			return nil, errors.New(fmt.Sprintf("Invalid CPU range '%s' in "+
+				"CPU set %s", part, spec))
+		}
+
+		for cpu := first; cpu <= last; cpu++ {
+			if !seen[cpu] {
+				seen[cpu] = true
+				cpus = append(cpus, cpu)
+			}
+		}
+	}
+
+	return cpus, nil
+}
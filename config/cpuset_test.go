@@ -0,0 +1,43 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCpuSet(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    []int
+		wantErr bool
+	}{
+		{"0", []int{0}, false},
+		{"0-3", []int{0, 1, 2, 3}, false},
+		{"0-3,8,10-11", []int{0, 1, 2, 3, 8, 10, 11}, false},
+		{" 4 , 2 ", []int{4, 2}, false},
+		{"2-2", []int{2}, false},
+		// Each CPU is listed once, where it first appears
+		{"3,1-3,1", []int{3, 1, 2}, false},
+		{"1023", []int{1023}, false},
+		{"", nil, true},
+		{"a", nil, true},
+		{"1-", nil, true},
+		{"-1", nil, true},
+		{"3-1", nil, true},
+		{"0-1024", nil, true},
+		{"1,,2", nil, true},
+	}
+
+	for _, test := range tests {
+		got, err := ParseCpuSet(test.spec)
+		if (err != nil) != test.wantErr {
+			t.Errorf("ParseCpuSet(%q): err = %v, want error %v", test.spec,
+				err, test.wantErr)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseCpuSet(%q) = %v, want %v", test.spec, got,
+				test.want)
+		}
+	}
+}
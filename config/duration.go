@@ -0,0 +1,63 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Duration is a time.Duration that can be read from a config file either as
+// a whole number of seconds, e.g. 2, or as a duration string, e.g. 500ms or
+// 1.5s
+type Duration struct {
+	time.Duration
+}
+
+func parseDuration(text string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(text); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	d, err := time.ParseDuration(text)
+	if err != nil {
+		msg := fmt.Sprintf("Invalid duration '%s'. Use a number of seconds "+
+			"or a value such as 500ms", text)
+		return 0, errors.New(msg)
+	}
+
+	return d, nil
+}
+
+// UnmarshalText is used when reading the config file
+func (d *Duration) UnmarshalText(text []byte) error {
+	var err error
+	d.Duration, err = parseDuration(string(text))
+	return err
+}
+
+// MarshalJSON stores the duration as a string, e.g. "1.5s"
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.Duration.String())
+}
+
+// UnmarshalJSON reads a duration stored by MarshalJSON. Sessions created
+// before durations were supported store a whole number of seconds, which
+// is also accepted.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var seconds int
+	if err := json.Unmarshal(data, &seconds); err == nil {
+		d.Duration = time.Duration(seconds) * time.Second
+		return nil
+	}
+
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return err
+	}
+
+	var err error
+	d.Duration, err = time.ParseDuration(text)
+	return err
+}
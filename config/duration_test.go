@@ -0,0 +1,80 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestDurationUnmarshalText(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"2", 2 * time.Second, false},
+		{"0", 0, false},
+		{"500ms", 500 * time.Millisecond, false},
+		{"1.5s", 1500 * time.Millisecond, false},
+		{"2m", 2 * time.Minute, false},
+		{"", 0, true},
+		{"1.5", 0, true},
+		{"two", 0, true},
+	}
+
+	for _, test := range tests {
+		var d Duration
+		err := d.UnmarshalText([]byte(test.text))
+		if (err != nil) != test.wantErr {
+			t.Errorf("%q: err = %v, want error %v", test.text, err,
+				test.wantErr)
+			continue
+		}
+		if err == nil && d.Duration != test.want {
+			t.Errorf("%q = %s, want %s", test.text, d.Duration, test.want)
+		}
+	}
+}
+
+func TestDurationJSON(t *testing.T) {
+	tests := []struct {
+		data    string
+		want    time.Duration
+		wantErr bool
+	}{
+		// Sessions from before durations were supported
+		{`5`, 5 * time.Second, false},
+		{`"250ms"`, 250 * time.Millisecond, false},
+		{`"1m30s"`, 90 * time.Second, false},
+		{`"soon"`, 0, true},
+		{`true`, 0, true},
+	}
+
+	for _, test := range tests {
+		var d Duration
+		err := json.Unmarshal([]byte(test.data), &d)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: err = %v, want error %v", test.data, err,
+				test.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if d.Duration != test.want {
+			t.Errorf("%s = %s, want %s", test.data, d.Duration, test.want)
+		}
+
+		// What is stored reads back the same
+		stored, err := json.Marshal(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var reread Duration
+		if err := json.Unmarshal(stored, &reread); err != nil ||
+			reread.Duration != d.Duration {
+			t.Errorf("%s stored as %s read back as %s, %v", test.data,
+				stored, reread.Duration, err)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package coverage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	PROFRAW_EXT = ".profraw"
+	// Raw profiles modified more recently than this many seconds ago may
+	// still be being written, so they are left for the next merge
+	PROFRAW_SETTLE_SECONDS = 2
+)
+
+// FileSummary gives the source coverage of a single file
+type FileSummary struct {
+	Filename         string
+	Lines            int
+	LinesCovered     int
+	Functions        int
+	FunctionsCovered int
+	Regions          int
+	RegionsCovered   int
+}
+
+// FunctionSummary gives the number of times a single function was executed
+type FunctionSummary struct {
+	Name  string
+	Count uint64
+}
+
+// MergeProfiles merges the raw profiles found in rawDir into the indexed
+// profile at profdataPath, which is created if it doesn't already exist.
+// Raw profiles are deleted once they have been merged. The number of raw
+// profiles merged is returned.
+func MergeProfiles(profdataTool string, rawDir string,
+	profdataPath string) (int, error) {
+
+	entries, err := ioutil.ReadDir(rawDir)
+	if err != nil {
+		return 0, err
+	}
+
+	settled := time.Now().Add(-PROFRAW_SETTLE_SECONDS * time.Second)
+	rawPaths := []string{}
+	for _, entry := range entries {
+		if filepath.Ext(entry.Name()) != PROFRAW_EXT ||
+			entry.ModTime().After(settled) {
+			continue
+		}
+		rawPaths = append(rawPaths, filepath.Join(rawDir, entry.Name()))
+	}
+
+	if len(rawPaths) == 0 {
+		return 0, nil
+	}
+
+	inputs := rawPaths
+	if _, err := os.Stat(profdataPath); err == nil {
+		inputs = append(inputs, profdataPath)
+	}
+
+	// There may be far too many raw profiles to pass on the command line,
+	// so they are listed in a file instead
+	listPath := profdataPath + ".inputs"
+	listData := strings.Join(inputs, "\n") + "\n"
+	if err := ioutil.WriteFile(listPath, []byte(listData), 0644); err != nil {
+		return 0, err
+	}
+	defer os.Remove(listPath)
+
+	tmpPath := profdataPath + ".tmp"
+	cmd := exec.Command(profdataTool, "merge", "-sparse",
+		"--failure-mode=all", "--input-files="+listPath, "-o", tmpPath)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return 0, errors.New(fmt.Sprintf("Error running %s: %s\n%s",
+			profdataTool, err, output))
+	}
+
+	if err := os.Rename(tmpPath, profdataPath); err != nil {
+		return 0, err
+	}
+
+	for _, rawPath := range rawPaths {
+		os.Remove(rawPath)
+	}
+
+	return len(rawPaths), nil
+}
+
+type llvmCovCount struct {
+	Count   int
+	Covered int
+}
+
+type llvmCovExport struct {
+	Data []struct {
+		Files []struct {
+			Filename string
+			Summary  struct {
+				Lines     llvmCovCount
+				Functions llvmCovCount
+				Regions   llvmCovCount
+			}
+		}
+	}
+}
+
+// FileSummaries uses llvm-cov to produce a coverage summary for each source
+// file of binary, from the indexed profile at profdataPath
+func FileSummaries(covTool string, binary string,
+	profdataPath string) ([]FileSummary, error) {
+
+	cmd := exec.Command(covTool, "export", "-summary-only",
+		"-instr-profile="+profdataPath, binary)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Error running %s: %s\n%s",
+			covTool, err, stderr.String()))
+	}
+
+	var export llvmCovExport
+	if err := json.Unmarshal(output, &export); err != nil {
+		return nil, err
+	}
+
+	summaries := []FileSummary{}
+	for _, d := range export.Data {
+		for _, f := range d.Files {
+			summaries = append(summaries, FileSummary{
+				Filename:         f.Filename,
+				Lines:            f.Summary.Lines.Count,
+				LinesCovered:     f.Summary.Lines.Covered,
+				Functions:        f.Summary.Functions.Count,
+				FunctionsCovered: f.Summary.Functions.Covered,
+				Regions:          f.Summary.Regions.Count,
+				RegionsCovered:   f.Summary.Regions.Covered,
+			})
+		}
+	}
+
+	return summaries, nil
+}
+
+// FunctionCounts uses llvm-profdata to list the execution count of every
+// instrumented function in the indexed profile at profdataPath
+func FunctionCounts(profdataTool string,
+	profdataPath string) ([]FunctionSummary, error) {
+
+	cmd := exec.Command(profdataTool, "show", "--all-functions",
+		profdataPath)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Error running %s: %s\n%s",
+			profdataTool, err, stderr.String()))
+	}
+
+	// Each function is listed as its name, indented by two spaces and
+	// followed by a colon, with its details indented further beneath it
+	functions := []FunctionSummary{}
+	current := -1
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+
+		if strings.HasPrefix(line, "  ") && !strings.HasPrefix(line, "   ") &&
+			strings.HasSuffix(trimmed, ":") {
+			functions = append(functions, FunctionSummary{
+				Name: strings.TrimSuffix(trimmed, ":")})
+			current = len(functions) - 1
+			continue
+		}
+
+		if current != -1 && strings.HasPrefix(trimmed, "Function count:") {
+			countStr := strings.TrimSpace(strings.TrimPrefix(trimmed,
+				"Function count:"))
+			if count, err := strconv.ParseUint(countStr, 10, 64); err == nil {
+				functions[current].Count = count
+			}
+		}
+	}
+
+	return functions, nil
+}
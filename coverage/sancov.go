@@ -0,0 +1,184 @@
+package coverage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	SANCOV_EXT      = ".sancov"
+	SANCOV_MAGIC_64 = 0xC0BFFFFFFFFFFF64
+	SANCOV_MAGIC_32 = 0xC0BFFFFFFFFFFF32
+)
+
+// ReadSancovFile parses a file written by SanitizerCoverage and returns the
+// PCs recorded in it
+func ReadSancovFile(path string) ([]uint64, error) {
+	fileData, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(fileData) < 8 {
+		return nil, errors.New(fmt.Sprintf("%s is too short to be a "+
+			"sancov file", path))
+	}
+
+	var pcSize int
+	switch binary.LittleEndian.Uint64(fileData) {
+	case SANCOV_MAGIC_64:
+		pcSize = 8
+	case SANCOV_MAGIC_32:
+		pcSize = 4
+	default:
+		return nil, errors.New(fmt.Sprintf("%s has an invalid sancov "+
+			"header", path))
+	}
+
+	pcs := []uint64{}
+	for off := 8; off+pcSize <= len(fileData); off += pcSize {
+		if pcSize == 8 {
+			pcs = append(pcs, binary.LittleEndian.Uint64(fileData[off:]))
+		} else {
+			pcs = append(pcs,
+				uint64(binary.LittleEndian.Uint32(fileData[off:])))
+		}
+	}
+
+	return pcs, nil
+}
+
+// sancovModule extracts the module name from the name of a sancov file,
+// which has the form <module>.<pid>.sancov
+func sancovModule(path string) string {
+	name := strings.TrimSuffix(filepath.Base(path), SANCOV_EXT)
+	if idx := strings.LastIndex(name, "."); idx != -1 {
+		name = name[:idx]
+	}
+
+	return name
+}
+
+// ReadSancovDir parses every sancov file in dir and returns the PCs recorded
+// in them, keyed by the module they belong to
+func ReadSancovDir(dir string) (map[string][]uint64, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+SANCOV_EXT))
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]uint64)
+	for _, path := range paths {
+		pcs, err := ReadSancovFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		module := sancovModule(path)
+		result[module] = append(result[module], pcs...)
+	}
+
+	return result, nil
+}
+
+// EdgeSet records every PC that has been covered so far, across all
+// modules. It is not safe for concurrent use.
+type EdgeSet struct {
+	edges map[string]map[uint64]bool
+	Count int
+	// path, if not empty, is the file that new PCs are appended to, one
+	// per line as the module and the PC in hex separated by a tab
+	path string
+}
+
+func NewEdgeSet() *EdgeSet {
+	return &EdgeSet{edges: make(map[string]map[uint64]bool)}
+}
+
+// OpenEdgeSet creates an EdgeSet holding the PCs recorded in the file at
+// path, if it exists, and which records any new PCs there, so that the set
+// survives the session being resumed
+func OpenEdgeSet(path string) (*EdgeSet, error) {
+	e := NewEdgeSet()
+	e.path = path
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return e, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) != 2 {
+			// A line cut short by the session being killed
+			continue
+		}
+
+		pc, err := strconv.ParseUint(fields[1], 16, 64)
+		if err != nil {
+			continue
+		}
+		e.add(fields[0], pc)
+	}
+
+	return e, scanner.Err()
+}
+
+// add records a single PC, and indicates if it had not been seen before
+func (e *EdgeSet) add(module string, pc uint64) bool {
+	seen, ok := e.edges[module]
+	if !ok {
+		seen = make(map[uint64]bool)
+		e.edges[module] = seen
+	}
+
+	if seen[pc] {
+		return false
+	}
+	seen[pc] = true
+	e.Count++
+
+	return true
+}
+
+// Add merges the coverage from a single run into the set and returns the
+// number of PCs that had not been seen before. The PCs are still counted if
+// they cannot be recorded in the file of the set.
+func (e *EdgeSet) Add(cov map[string][]uint64) (int, error) {
+	var record bytes.Buffer
+	newEdges := 0
+	for module, pcs := range cov {
+		for _, pc := range pcs {
+			if e.add(module, pc) {
+				newEdges++
+				fmt.Fprintf(&record, "%s\t%x\n", module, pc)
+			}
+		}
+	}
+
+	if len(e.path) == 0 || newEdges == 0 {
+		return newEdges, nil
+	}
+
+	f, err := os.OpenFile(e.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE,
+		0666)
+	if err != nil {
+		return newEdges, err
+	}
+	defer f.Close()
+
+	_, err = f.Write(record.Bytes())
+	return newEdges, err
+}
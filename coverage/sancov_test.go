@@ -0,0 +1,163 @@
+package coverage
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestEdgeSetAdd(t *testing.T) {
+	tests := []struct {
+		cov  map[string][]uint64
+		want int
+	}{
+		{map[string][]uint64{"php": {1, 2, 3}}, 3},
+		{map[string][]uint64{"php": {2, 3, 4}}, 1},
+		{map[string][]uint64{"php": {4, 4}}, 0},
+		// The same PC in another module is a different edge
+		{map[string][]uint64{"libc": {1}, "php": {1}}, 1},
+		{map[string][]uint64{}, 0},
+	}
+
+	e := NewEdgeSet()
+	total := 0
+	for i, test := range tests {
+		got, err := e.Add(test.cov)
+		if err != nil {
+			t.Fatalf("Add %d: %s", i, err)
+		}
+		if got != test.want {
+			t.Errorf("Add %d = %d new edges, want %d", i, got, test.want)
+		}
+
+		total += test.want
+		if e.Count != total {
+			t.Errorf("Count after add %d = %d, want %d", i, e.Count, total)
+		}
+	}
+}
+
+func TestOpenEdgeSet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "edges")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "edges.txt")
+
+	e, err := OpenEdgeSet(path)
+	if err != nil {
+		t.Fatalf("Opening a missing edge file: %s", err)
+	}
+	if _, err := e.Add(map[string][]uint64{"php": {0x10, 0x20},
+		"libc": {0x10}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A session killed while writing can leave a partial line behind
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("php\n")
+	f.WriteString("php\tzz\n")
+	f.WriteString("ph")
+	f.Close()
+
+	resumed, err := OpenEdgeSet(path)
+	if err != nil {
+		t.Fatalf("Reopening the edge file: %s", err)
+	}
+	if resumed.Count != 3 {
+		t.Errorf("Reopened set has %d edges, want 3", resumed.Count)
+	}
+
+	tests := []struct {
+		cov  map[string][]uint64
+		want int
+	}{
+		{map[string][]uint64{"php": {0x10, 0x20}, "libc": {0x10}}, 0},
+		{map[string][]uint64{"php": {0x30}, "libc": {0x20}}, 2},
+	}
+	for i, test := range tests {
+		got, err := resumed.Add(test.cov)
+		if err != nil {
+			t.Fatalf("Add %d: %s", i, err)
+		}
+		if got != test.want {
+			t.Errorf("Add %d to the reopened set = %d new edges, want %d",
+				i, got, test.want)
+		}
+	}
+}
+
+func TestReadSancovFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sancov")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file64 := make([]byte, 24)
+	binary.LittleEndian.PutUint64(file64, SANCOV_MAGIC_64)
+	binary.LittleEndian.PutUint64(file64[8:], 0x401000)
+	binary.LittleEndian.PutUint64(file64[16:], 0x7fff00001234)
+
+	file32 := make([]byte, 16)
+	binary.LittleEndian.PutUint64(file32, SANCOV_MAGIC_32)
+	binary.LittleEndian.PutUint32(file32[8:], 0x8048000)
+	binary.LittleEndian.PutUint32(file32[12:], 0x8048010)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    []uint64
+		wantErr bool
+	}{
+		{"64 bit", file64, []uint64{0x401000, 0x7fff00001234}, false},
+		{"32 bit", file32, []uint64{0x8048000, 0x8048010}, false},
+		// A PC cut short is dropped
+		{"partial PC", file64[:len(file64)-3], []uint64{0x401000}, false},
+		{"header only", file64[:8], []uint64{}, false},
+		{"short", []byte{1, 2, 3}, nil, true},
+		{"bad magic", make([]byte, 16), nil, true},
+	}
+
+	for _, test := range tests {
+		path := filepath.Join(dir, "php.123"+SANCOV_EXT)
+		if err := ioutil.WriteFile(path, test.data, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := ReadSancovFile(path)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: err = %v, want error %v", test.name, err,
+				test.wantErr)
+			continue
+		}
+		if !test.wantErr && !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: PCs = %x, want %x", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSancovModule(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/tmp/cov/php.1234.sancov", "php"},
+		{"/tmp/cov/libphp7.so.1234.sancov", "libphp7.so"},
+		{"php.sancov", "php"},
+	}
+
+	for _, test := range tests {
+		if got := sancovModule(test.path); got != test.want {
+			t.Errorf("sancovModule(%q) = %q, want %q", test.path, got,
+				test.want)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package crashdb
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/SeanHeelan/Malamute/triage"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+)
+
+const (
+	// The index of the database, holding one JSON encoded Entry per line
+	INDEX_NAME = "index.jsonl"
+
+	// The bucket of the crash had not been seen by any session
+	LABEL_NEW = "new"
+	// The bucket of the crash had already been added to the database
+	LABEL_KNOWN = "known"
+)
+
+// Entry records a crash preserved by a session
+type Entry struct {
+	Bucket         string
+	BugClass       string
+	Severity       string
+	SeverityReason string
+	// CrashDir is the absolute path of the directory the crash was
+	// preserved to
+	CrashDir string
+	// SessionDir is the absolute path of the session that found the crash
+	SessionDir  string
+	Interpreter string
+	Time        time.Time
+}
+
+// BucketSummary describes the crashes recorded in a single bucket
+type BucketSummary struct {
+	Bucket string
+	Count  int
+	// Severity and SeverityReason are those of the most severe crash in
+	// the bucket
+	Severity       string
+	SeverityReason string
+	// FirstSeen and LastSeen give the times the first and latest crashes
+	// of the bucket were added
+	FirstSeen time.Time
+	LastSeen  time.Time
+	// Sessions gives the number of distinct sessions that found the bucket
+	Sessions int
+}
+
+// DB is a crash database stored as a JSONL index in a directory. Any number
+// of sessions may add to the same database. Entries added by others are
+// picked up whenever the database is queried.
+type DB struct {
+	indexPath string
+	// offset is how far into the index has been read
+	offset  int64
+	entries []Entry
+	buckets map[string][]int
+}
+
+// Open loads the crash database in dir, creating the directory if it does
+// not exist
+func Open(dir string) (*DB, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	db := DB{
+		indexPath: filepath.Join(dir, INDEX_NAME),
+		buckets:   make(map[string][]int),
+	}
+	if err := db.refresh(); err != nil {
+		return nil, err
+	}
+
+	return &db, nil
+}
+
+// refresh reads any entries added to the index since it was last read. A
+// line that is incomplete, because it is still being written, is left to be
+// read next time.
+func (db *DB) refresh() error {
+	f, err := os.Open(db.indexPath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(db.offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		db.offset += int64(len(line))
+
+		var entry Entry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			continue
+		}
+
+		db.buckets[entry.Bucket] = append(db.buckets[entry.Bucket],
+			len(db.entries))
+		db.entries = append(db.entries, entry)
+	}
+}
+
+// Add appends entry to the index
+func (db *DB) Add(entry Entry) error {
+	jsonData, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(db.indexPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE,
+		0666)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// A single write keeps the line whole when sessions add at once
+	if _, err := f.Write(append(jsonData, '\n')); err != nil {
+		return err
+	}
+
+	return db.refresh()
+}
+
+// Label returns LABEL_KNOWN if bucket has already been added to the
+// database, or LABEL_NEW otherwise, along with the first entry of the bucket
+// if it is known
+func (db *DB) Label(bucket string) (string, *Entry, error) {
+	if err := db.refresh(); err != nil {
+		return "", nil, err
+	}
+
+	indexes, ok := db.buckets[bucket]
+	if !ok {
+		return LABEL_NEW, nil, nil
+	}
+
+	first := db.entries[indexes[0]]
+	return LABEL_KNOWN, &first, nil
+}
+
+// Entries returns the entries of bucket, in the order they were added
+func (db *DB) Entries(bucket string) ([]Entry, error) {
+	if err := db.refresh(); err != nil {
+		return nil, err
+	}
+
+	entries := []Entry{}
+	for _, idx := range db.buckets[bucket] {
+		entries = append(entries, db.entries[idx])
+	}
+
+	return entries, nil
+}
+
+// Search returns the entries whose bucket, class, severity reason or crash
+// directory match re, in the order they were added
+func (db *DB) Search(re *regexp.Regexp) ([]Entry, error) {
+	if err := db.refresh(); err != nil {
+		return nil, err
+	}
+
+	entries := []Entry{}
+	for _, entry := range db.entries {
+		if re.MatchString(entry.Bucket) || re.MatchString(entry.BugClass) ||
+			re.MatchString(entry.SeverityReason) ||
+			re.MatchString(entry.CrashDir) {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, nil
+}
+
+// Buckets summarises every bucket in the database, ordered by the severity
+// of the worst crash in each, and then by the number of crashes in them
+func (db *DB) Buckets() ([]BucketSummary, error) {
+	if err := db.refresh(); err != nil {
+		return nil, err
+	}
+
+	summaries := []BucketSummary{}
+	for bucket, indexes := range db.buckets {
+		summary := BucketSummary{
+			Bucket: bucket,
+			Count:  len(indexes),
+		}
+
+		sessions := make(map[string]bool)
+		for i, idx := range indexes {
+			entry := db.entries[idx]
+			sessions[entry.SessionDir] = true
+			if i == 0 || triage.Rank(entry.Severity) <
+				triage.Rank(summary.Severity) {
+				summary.Severity = entry.Severity
+				summary.SeverityReason = entry.SeverityReason
+			}
+			if i == 0 || entry.Time.Before(summary.FirstSeen) {
+				summary.FirstSeen = entry.Time
+			}
+			if entry.Time.After(summary.LastSeen) {
+				summary.LastSeen = entry.Time
+			}
+		}
+		summary.Sessions = len(sessions)
+
+		summaries = append(summaries, summary)
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		rankI := triage.Rank(summaries[i].Severity)
+		rankJ := triage.Rank(summaries[j].Severity)
+		if rankI != rankJ {
+			return rankI < rankJ
+		}
+
+		if summaries[i].Count != summaries[j].Count {
+			return summaries[i].Count > summaries[j].Count
+		}
+
+		return summaries[i].Bucket < summaries[j].Bucket
+	})
+
+	return summaries, nil
+}
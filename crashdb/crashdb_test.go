@@ -0,0 +1,232 @@
+package crashdb
+
+import (
+	"github.com/SeanHeelan/Malamute/triage"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestLabel(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crashdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The directory is created if it does not exist
+	dbDir := filepath.Join(dir, "db")
+	db, err := Open(dbDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := Open(dbDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		add       *DB
+		entry     Entry
+		wantLabel string
+		wantFirst string
+	}{
+		{"new bucket", db, Entry{Bucket: "stack:a", CrashDir: "/s1/1"},
+			LABEL_NEW, ""},
+		{"known bucket", db, Entry{Bucket: "stack:a", CrashDir: "/s1/2"},
+			LABEL_KNOWN, "/s1/1"},
+		// Entries added through another handle, as by another session,
+		// are seen
+		{"added elsewhere", other, Entry{Bucket: "stack:b",
+			CrashDir: "/s2/1"}, LABEL_NEW, ""},
+		{"known from elsewhere", db, Entry{Bucket: "stack:b",
+			CrashDir: "/s1/3"}, LABEL_KNOWN, "/s2/1"},
+	}
+
+	for _, test := range tests {
+		for _, d := range []*DB{db, other} {
+			label, first, err := d.Label(test.entry.Bucket)
+			if err != nil {
+				t.Fatal(err)
+			}
+			firstDir := ""
+			if first != nil {
+				firstDir = first.CrashDir
+			}
+			if label != test.wantLabel || firstDir != test.wantFirst {
+				t.Errorf("%s: Label = %q, first in %q, want %q, %q",
+					test.name, label, firstDir, test.wantLabel,
+					test.wantFirst)
+			}
+		}
+
+		if err := test.add.Add(test.entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := other.Entries("stack:a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].CrashDir != "/s1/1" ||
+		entries[1].CrashDir != "/s1/2" {
+		t.Errorf("Entries(stack:a) = %+v", entries)
+	}
+
+	// A reopened database holds everything added to it
+	reopened, err := Open(dbDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reopened.entries) != 4 {
+		t.Errorf("The reopened database has %d entries, want 4",
+			len(reopened.entries))
+	}
+}
+
+func TestRefreshPartialLines(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crashdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexPath := filepath.Join(dir, INDEX_NAME)
+
+	tests := []struct {
+		name        string
+		write       string
+		wantBuckets []string
+	}{
+		{"complete", `{"Bucket": "a"}` + "\n", []string{"a"}},
+		// A line still being written is left until it is complete
+		{"partial", `{"Bucket": `, []string{"a"}},
+		{"completed", `"b"}` + "\n", []string{"a", "b"}},
+		{"corrupt", "not json\n", []string{"a", "b"}},
+		{"after corrupt", `{"Bucket": "c"}` + "\n",
+			[]string{"a", "b", "c"}},
+	}
+
+	for _, test := range tests {
+		f, err := os.OpenFile(indexPath,
+			os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = f.WriteString(test.write)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := db.refresh(); err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		buckets := []string{}
+		for _, entry := range db.entries {
+			buckets = append(buckets, entry.Bucket)
+		}
+		if !reflect.DeepEqual(buckets, test.wantBuckets) {
+			t.Errorf("%s: buckets %q, want %q", test.name, buckets,
+				test.wantBuckets)
+		}
+	}
+}
+
+func TestBuckets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crashdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	day := func(d int) time.Time {
+		return time.Date(2017, 1, d, 0, 0, 0, 0, time.UTC)
+	}
+	entries := []Entry{
+		{Bucket: "a", Severity: triage.SEVERITY_LOW, SessionDir: "/s1",
+			Time: day(3)},
+		{Bucket: "a", Severity: triage.SEVERITY_LOW, SessionDir: "/s1",
+			Time: day(1)},
+		{Bucket: "b", Severity: triage.SEVERITY_LOW, SessionDir: "/s1",
+			Time: day(2)},
+		// A bucket takes the severity of its worst crash
+		{Bucket: "c", Severity: triage.SEVERITY_LOW,
+			SeverityReason: "abort", SessionDir: "/s1", Time: day(1)},
+		{Bucket: "c", Severity: triage.SEVERITY_CRITICAL,
+			SeverityReason: "double free", SessionDir: "/s2",
+			Time: day(4)},
+		{Bucket: "d", Severity: triage.SEVERITY_LOW, SessionDir: "/s2",
+			Time: day(2)},
+	}
+	for _, entry := range entries {
+		if err := db.Add(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	summaries, err := db.Buckets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []BucketSummary{
+		{Bucket: "c", Count: 2, Severity: triage.SEVERITY_CRITICAL,
+			SeverityReason: "double free", FirstSeen: day(1),
+			LastSeen: day(4), Sessions: 2},
+		{Bucket: "a", Count: 2, Severity: triage.SEVERITY_LOW,
+			FirstSeen: day(1), LastSeen: day(3), Sessions: 1},
+		{Bucket: "b", Count: 1, Severity: triage.SEVERITY_LOW,
+			FirstSeen: day(2), LastSeen: day(2), Sessions: 1},
+		{Bucket: "d", Count: 1, Severity: triage.SEVERITY_LOW,
+			FirstSeen: day(2), LastSeen: day(2), Sessions: 1},
+	}
+	if len(summaries) != len(want) {
+		t.Fatalf("Buckets = %+v, want %+v", summaries, want)
+	}
+	for i := range want {
+		got := summaries[i]
+		if got.Bucket != want[i].Bucket || got.Count != want[i].Count ||
+			got.Severity != want[i].Severity ||
+			got.SeverityReason != want[i].SeverityReason ||
+			!got.FirstSeen.Equal(want[i].FirstSeen) ||
+			!got.LastSeen.Equal(want[i].LastSeen) ||
+			got.Sessions != want[i].Sessions {
+			t.Errorf("Bucket %d = %+v, want %+v", i, got, want[i])
+		}
+	}
+
+	tests := []struct {
+		pattern string
+		want    int
+	}{
+		{"^c$", 2},
+		{"double", 1},
+		{"^[ab]$", 3},
+		{"nothing", 0},
+	}
+	for _, test := range tests {
+		found, err := db.Search(regexp.MustCompile(test.pattern))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(found) != test.want {
+			t.Errorf("Search(%q) found %d entries, want %d", test.pattern,
+				len(found), test.want)
+		}
+	}
+}
@@ -1,5 +1,9 @@
 package data
 
+import (
+	"time"
+)
+
 // TestCase instances are created for each file output by a mutator. It
 // represents a single test and it is passed along the pipeline from a
 // mutator, through to a monitor, through to a result processor, and finally
@@ -29,34 +33,211 @@ type TestCase struct {
 	// used during the execution of the test. It will be filled in by the
 	// execution monitor
 	ApplicationEnv []string
+	// ApplicationArgs specifies the arguments the application was run with.
+	// Any occurrence of the path to the fuzz file, or of its contents, is
+	// replaced by the corresponding marker from the config package. It will
+	// be filled in by the execution monitor.
+	ApplicationArgs []string
+	// InputMode specifies how the test was provided to the application.
+	// It will be one of the config.INPUT_MODE_* constants, or
+	// config.MONITOR_PERSISTENT if the test was fed to a persistent
+	// interpreter. It will be filled in by the execution monitor.
+	InputMode string
+	// Timeout gives the time the test was allowed to run for. It will be
+	// filled in by the execution monitor.
+	Timeout time.Duration
 	// TestTimedOut indicates whether the test case killed by the execution
 	// monitor because it was taking too long. This will be filled in by the
 	// execution monitor.
 	TestTimedOut bool
-	// ExeSeconds gives the number of seconds that the test took to execute.
-	// This will be filled in by the execution monitor if TestTimedOut is
-	// false
-	ExeSeconds int
+	// WallTime gives the time that the test took to execute. This will be
+	// filled in by the execution monitor if TestTimedOut is false.
+	WallTime time.Duration
+	// UserTime and SysTime give the CPU time used by the interpreter, and
+	// MaxRSS its peak resident set size in kilobytes. They will be filled
+	// in by the execution monitor if TestTimedOut is false, and the
+	// interpreter exited after the test.
+	UserTime time.Duration
+	SysTime  time.Duration
+	MaxRSS   int64
+	// ResourceAnomaly is one of the monitor.RESOURCE_* constants if the
+	// test used far more CPU time or memory than its seed, and is
+	// otherwise empty. It will be filled in by the execution monitor.
+	ResourceAnomaly string
+	// LeakedProcesses gives the number of processes started by the test
+	// that were still running after the interpreter exited, or when it was
+	// killed for timing out, and so had to be killed. This will be filled
+	// in by the execution monitor.
+	LeakedProcesses int
+	// Unrunnable gives the reason the interpreter could not be run on the
+	// test, e.g. because the fuzz file holds a NUL byte and is passed as
+	// an argument. Such a test has no results. It will be filled in by the
+	// execution monitor.
+	Unrunnable string
 	// ExitCode gives the exit code from the test if TestTimedOut is false.
 	// it will be filled in by the execution monitor.
 	ExitCode int
 	// RunStdout provides the data written to STDOUT during the application
-	// under test. It will be filled in by the execution monitor if
-	// TestTimedOut is false and ExitCode is not zero.
+	// under test. It will be filled in by the execution monitor.
 	RunStdout []string
 	// RunStderr provides the data written to STDERR during the application
-	// under test. It will be filled in by the execution monitor if
-	// TestTimedOut is false and ExitCode is not zero.
+	// under test. It will be filled in by the execution monitor.
 	RunStderr []string
+	// Coverage gives the PCs covered during the execution of the test,
+	// keyed by the module they belong to. It will be filled in by the
+	// execution monitor if coverage collection is enabled and TestTimedOut
+	// is false.
+	Coverage map[string][]uint64
+
+	// DifferentialStdout gives the data written to STDOUT by each of the
+	// interpreter profiles the test was run under, keyed by profile name.
+	// It will be filled in by the differential execution monitor.
+	DifferentialStdout map[string][]string
+	// DifferentialExitCodes gives the exit code of each of the interpreter
+	// profiles the test was run under, keyed by profile name. It will be
+	// filled in by the differential execution monitor.
+	DifferentialExitCodes map[string]int
+	// DifferentialStderr gives the data written to STDERR by each of the
+	// interpreter profiles the test was run under, keyed by profile name.
+	// It will be filled in by the differential execution monitor.
+	DifferentialStderr map[string][]string
+	// DivergentProfiles lists the interpreter profiles whose output
+	// differed from that of the main interpreter. It will be filled in by
+	// the differential execution monitor.
+	DivergentProfiles []string
+	// TimedOutProfiles lists the interpreter profiles that timed out, and
+	// so could not be compared against the main interpreter. It will be
+	// filled in by the differential execution monitor.
+	TimedOutProfiles []string
+	// CrashedProfile names the profile the test crashed under, if it did
+	// not crash under the main interpreter. The crash is bucketed and
+	// triaged from the output of that profile. It will be filled in by the
+	// results processor.
+	CrashedProfile string
+	// ExpectationChecked indicates that the seed of the test has a known
+	// expected result, which the result of the test was compared against.
+	// It will be filled in by the execution monitor if the oracle is
+	// enabled.
+	ExpectationChecked bool
+	// ExpectationMismatch describes how the result of the test differed
+	// from the expected result, and is empty if it matched
+	ExpectationMismatch string
+	// Unmutated indicates that the test is an exact copy of its seed. It
+	// is only filled in if the expectation was checked.
+	Unmutated bool
+	// ExpectationSkipped indicates that the seed of the test has an
+	// expected result, but it could not be checked because the output was
+	// truncated and not spilled
+	ExpectationSkipped bool
+
+	// HangConfirmed indicates that a test that timed out also timed out
+	// when re-run with a longer timeout. It will be filled in by the
+	// execution monitor if hang preservation is enabled.
+	HangConfirmed bool
+	// HangTimeout gives the timeout used when confirming the hang
+	HangTimeout time.Duration
+	// CPUPercent gives the CPU usage of the interpreter over the second
+	// half of the confirmation run, as a percentage of a single CPU
+	CPUPercent int
+	// HangKind is one of the monitor.HANG_* constants, derived from
+	// CPUPercent
+	HangKind string
+	// HangExited indicates that a test that timed out exited with a
+	// non-zero exit code, e.g. by crashing, when re-run to confirm the
+	// hang. The results of the test are then those of that run.
+	HangExited bool
+
+	// CorePath gives the path of the core file written when the test
+	// crashed. It will be filled in by the execution monitor if core dumps
+	// are enabled.
+	CorePath string
+	// Backtrace holds the backtrace produced from the core file by gdb. It
+	// will be filled in by the results processor.
+	Backtrace []string
+
+	// StdoutTruncated and StderrTruncated indicate that output was
+	// discarded from the middle of RunStdout and RunStderr, which hold
+	// only the start and end of what was written
+	StdoutTruncated bool
+	StderrTruncated bool
+	// StdoutSpillPath and StderrSpillPath give the paths of files holding
+	// the full output, if it was truncated and spilling is enabled. They
+	// will be filled in by the execution monitor.
+	StdoutSpillPath string
+	StderrSpillPath string
+	// SymbolizedStderr is a copy of RunStderr in which raw stack frames
+	// have been resolved against the unstripped interpreter. It will be
+	// filled in by the results processor, if any frames were resolved.
+	SymbolizedStderr []string
+
+	// Severity is one of the triage.SEVERITY_* constants, and
+	// SeverityReason explains it. They are filled in by the results
+	// processor for test cases that crash.
+	Severity       string
+	SeverityReason string
+
+	// Bucket groups crashes that appear to be the same bug. It is filled
+	// in by the results processor for test cases that crash.
+	Bucket string
 
 	// BugFound will be filled in by the results processor and indicates
 	// whether it considered this test case to trigger a potential bug or not.
 	BugFound bool
+	// BugClass is one of the resultproc.BUGCLASS_* constants, if BugFound
+	// is set
+	BugClass string
+	// MinimizedPath gives the path of a reduced version of the fuzz file
+	// that triggers the same bug. It will be filled in by the results
+	// processor, if minimization is enabled.
+	MinimizedPath string
+	// ClassifierNotes holds the notes returned by the user-supplied
+	// classifier, if it was run
+	ClassifierNotes string
+	// ClassifierRejected indicates that the user-supplied classifier
+	// decided a candidate bug was not a bug
+	ClassifierRejected bool
+	// InterestingPath specifies the path to which the test was saved if it
+	// was kept for behaving in a way not seen before, and InterestingReason
+	// is the label explaining how. They will be filled in by the results
+	// processor.
+	InterestingPath   string
+	InterestingReason string
+	// ParentCrashDir is the crash directory of the explored crash that this
+	// test descends from, when exploring crashes. ParentBucket is the
+	// bucket of that crash, and ParentRelation, one of the
+	// resultproc.LINEAGE_* constants, relates any bug found to it. Lineage
+	// lists the crash directories the parent itself descends from, oldest
+	// first, followed by the parent. They will be filled in by the results
+	// processor.
+	ParentCrashDir string
+	ParentBucket   string
+	ParentRelation string
+	Lineage        []string
+	// CrashDBLabel is one of the crashdb.LABEL_* constants, indicating
+	// whether the bucket of the crash was already in the crash database.
+	// KnownCrashDir is the first crash of the bucket in the database, if it
+	// was. They will be filled in by the results processor.
+	CrashDBLabel  string
+	KnownCrashDir string
+	// IgnoredAs labels the entry of the ignore list that the bug matched,
+	// if it did. It will be filled in by the results processor.
+	IgnoredAs string
+	// DroppedBy names the result processor that dropped this test case, if
+	// one did. Later processors will not have seen it.
+	DroppedBy string
 	// PreservationDir specifies the directory in which pertinant
 	// information regarding the test will be stored if this test case is
 	// considered to trigger a bug. It will be filled in by the results
 	// processor.
 	PreservationDir string
+	// NewEdges gives the number of PCs covered by this test that had not
+	// been covered by any earlier test. It will be filled in by the results
+	// processor.
+	NewEdges int
+	// CorpusPath specifies the path to which the test was saved if it was
+	// added to the corpus due to covering new edges. It will be filled in
+	// by the results processor.
+	CorpusPath string
 }
 
 func NewTestCase() TestCase {
@@ -0,0 +1,28 @@
+// Harness for running the SpiderMonkey shell as a persistent interpreter.
+// Test cases are read from STDIN, and separated by a delimiter line, which is
+// itself the first line read. After each test the delimiter is printed to
+// both STDOUT and STDERR so the monitor knows the test has completed.
+// See https://developer.mozilla.org/en-US/docs/SpiderMonkey/Shell_global_objects
+
+var delimiter = readline();
+var lines = [];
+var line;
+
+while ((line = readline()) !== null) {
+	if (line !== delimiter) {
+		lines.push(line);
+		continue;
+	}
+
+	try {
+		// Each test gets a fresh global to limit the state shared between
+		// tests
+		newGlobal().eval(lines.join("\n"));
+	} catch (e) {
+		printErr(e);
+	}
+
+	lines = [];
+	print(delimiter);
+	printErr(delimiter);
+}
@@ -0,0 +1,26 @@
+<?php
+// Harness for running PHP as a persistent interpreter. Test cases are read
+// from STDIN, and separated by a delimiter line, which is itself the first
+// line read. After each test the delimiter is printed to both STDOUT and
+// STDERR so the monitor knows the test has completed.
+
+$delimiter = rtrim(fgets(STDIN), "\n");
+$code = "";
+
+while (($line = fgets(STDIN)) !== false) {
+	if (rtrim($line, "\n") !== $delimiter) {
+		$code .= $line;
+		continue;
+	}
+
+	try {
+		// Tests start with an opening tag, so we leave PHP mode first
+		eval("?>" . $code);
+	} catch (Throwable $e) {
+		fwrite(STDERR, $e . "\n");
+	}
+
+	$code = "";
+	echo "\n" . $delimiter . "\n";
+	fwrite(STDERR, "\n" . $delimiter . "\n");
+}
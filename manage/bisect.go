@@ -0,0 +1,213 @@
+package manage
+
+import (
+	"errors"
+	"fmt"
+	"github.com/SeanHeelan/Malamute/config"
+	"github.com/SeanHeelan/Malamute/data"
+	"github.com/SeanHeelan/Malamute/fs"
+	"github.com/SeanHeelan/Malamute/logging"
+	"github.com/SeanHeelan/Malamute/monitor"
+	"github.com/SeanHeelan/Malamute/resultproc"
+	"github.com/SeanHeelan/Malamute/session"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+const (
+	BISECT_PREFIX = "bisect"
+)
+
+// buildInterpreter returns the path of the interpreter in build, which is
+// either the interpreter itself or a directory holding it under binaryName
+func buildInterpreter(build string, binaryName string) (string, error) {
+	info, err := os.Stat(build)
+	if err != nil {
+		return "", err
+	}
+
+	interpreter := build
+	if info.IsDir() {
+		interpreter = filepath.Join(build, binaryName)
+		if _, err := os.Stat(interpreter); err != nil {
+			return "", err
+		}
+	}
+
+	return interpreter, nil
+}
+
+// reproduces runs the trigger of the crash in crashDir against the
+// interpreter in build, through the exit code monitor, and indicates if it
+// crashes with the same signature
+func reproduces(s *session.Session, crashDir string,
+	bugDesc resultproc.BugDescriptor, build string,
+	binaryName string) (bool, error) {
+
+	interpreter, err := buildInterpreter(build, binaryName)
+	if err != nil {
+		return false, err
+	}
+
+	// Only the result of the run is of interest
+	buildSess := *s
+	buildSess.Config = buildConfig(s, interpreter, "")
+	buildSess.Config.Coverage.Enabled = false
+	buildSess.Config.Profile.Enabled = false
+	buildSess.Config.Oracle.Enabled = false
+	buildSess.Config.Output.NoSpill = true
+
+	analysis, err := resultproc.NewAnalysis(&buildSess)
+	if err != nil {
+		return false, err
+	}
+
+	copyName := fmt.Sprintf("%s_%s", BISECT_PREFIX, bugDesc.TriggerFileName)
+	copyPath := filepath.Join(s.TestCasesDir, copyName)
+	if err := fs.CopyFileContents(filepath.Join(crashDir,
+		bugDesc.TriggerFileName), copyPath); err != nil {
+		return false, err
+	}
+	defer os.Remove(copyPath)
+
+	tc := data.NewTestCase()
+	tc.FuzzFilePath = copyPath
+	tc.SeedFilePaths = bugDesc.OriginalSeedPaths
+	// The monitor takes a test case without seeds as the end of the stream
+	if len(tc.SeedFilePaths) == 0 {
+		tc.SeedFilePaths = []string{copyPath}
+	}
+
+	errChan := make(chan error, 1)
+	monitorIn := make(chan data.TestCase, 2)
+	monitorOut := make(chan data.TestCase, 2)
+	m := monitor.ExitCode{&buildSess, &logging.Logs{}}
+	go m.Run(monitorIn, monitorOut, errChan)
+	monitorIn <- tc
+	monitorIn <- data.NewTestCase()
+
+	select {
+	case tc = <-monitorOut:
+	case err := <-errChan:
+		return false, err
+	}
+	defer resultproc.RemoveRunFiles(tc)
+
+	if _, err := analysis.Process(&tc); err != nil {
+		log.Printf("Error analysing %s : %s", crashDir, err)
+	}
+
+	return tc.BugClass == resultproc.BUGCLASS_CRASH &&
+		tc.Bucket == bugDesc.Bucket, nil
+}
+
+// firstBad returns the index of the first of count builds that check
+// reports as bad, given that the last build is bad and that every build
+// after a bad one is also bad
+func firstBad(count int, check func(int) (bool, error)) (int, error) {
+	// Invariant: the build at high is bad, and every build before low is
+	// good
+	low, high := 0, count-1
+	for low < high {
+		mid := low + (high-low)/2
+		bad, err := check(mid)
+		if err != nil {
+			return 0, err
+		}
+
+		if bad {
+			high = mid
+		} else {
+			low = mid + 1
+		}
+	}
+
+	return high, nil
+}
+
+// Bisect searches builds, ordered from oldest to newest, for the first in
+// which the trigger of the crash preserved in crashDir reproduces with the
+// same signature. Builds are assumed to crash from that build onwards. Each
+// build is either an interpreter or a directory holding one under
+// binaryName. The last good and first bad builds are recorded in the bug
+// descriptor of the crash, which is returned.
+func Bisect(s *session.Session, crashDir string, builds []string,
+	binaryName string) (*resultproc.BugDescriptor, error) {
+
+	bugDesc, err := resultproc.ReadBugDescriptor(crashDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if bugDesc.BugClass != resultproc.BUGCLASS_CRASH {
+		msg := fmt.Sprintf("Only crashes can be bisected, but %s holds a "+
+			"bug of class %s", crashDir, bugDesc.BugClass)
+		return nil, errors.New(msg)
+	}
+
+	if bugDesc.InputMode == config.MONITOR_PERSISTENT {
+		return nil, errors.New("Crashes found by the persistent monitor " +
+			"cannot be bisected")
+	}
+
+	if len(builds) == 0 {
+		return nil, errors.New("No builds were provided")
+	}
+
+	if s.Config.Cores.Enabled {
+		if err := monitor.EnableCoreDumps(); err != nil {
+			return nil, err
+		}
+	}
+
+	results := make(map[int]bool)
+	check := func(idx int) (bool, error) {
+		if bad, ok := results[idx]; ok {
+			return bad, nil
+		}
+
+		bad, err := reproduces(s, crashDir, bugDesc, builds[idx],
+			binaryName)
+		if err != nil {
+			msg := fmt.Sprintf("Could not run %s. Error %s", builds[idx],
+				err)
+			return false, errors.New(msg)
+		}
+
+		if bad {
+			log.Printf("%s : reproduces", builds[idx])
+		} else {
+			log.Printf("%s : does not reproduce", builds[idx])
+		}
+		results[idx] = bad
+		return bad, nil
+	}
+
+	bad, err := check(len(builds) - 1)
+	if err != nil {
+		return nil, err
+	}
+	if !bad {
+		msg := fmt.Sprintf("The crash does not reproduce with the same "+
+			"signature in the newest build, %s", builds[len(builds)-1])
+		return nil, errors.New(msg)
+	}
+
+	high, err := firstBad(len(builds), check)
+	if err != nil {
+		return nil, err
+	}
+
+	bugDesc.BisectFirstBad = builds[high]
+	bugDesc.BisectLastGood = ""
+	if high != 0 {
+		bugDesc.BisectLastGood = builds[high-1]
+	}
+
+	if err := resultproc.WriteBugDescriptor(crashDir, bugDesc); err != nil {
+		return nil, err
+	}
+
+	return &bugDesc, nil
+}
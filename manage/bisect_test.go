@@ -0,0 +1,59 @@
+package manage
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFirstBad(t *testing.T) {
+	for count := 1; count <= 33; count++ {
+		// The most builds a binary search needs to check
+		maxChecks := 0
+		for n := 1; n < count; n *= 2 {
+			maxChecks++
+		}
+
+		for want := 0; want < count; want++ {
+			checked := make(map[int]bool)
+			check := func(idx int) (bool, error) {
+				if idx < 0 || idx >= count-1 {
+					t.Errorf("%d builds: build %d checked", count, idx)
+				}
+				if checked[idx] {
+					t.Errorf("%d builds: build %d checked twice", count,
+						idx)
+				}
+				checked[idx] = true
+				return idx >= want, nil
+			}
+
+			got, err := firstBad(count, check)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Errorf("%d builds: first bad %d, want %d", count, got,
+					want)
+			}
+			if len(checked) > maxChecks {
+				t.Errorf("%d builds: %d checked, want at most %d", count,
+					len(checked), maxChecks)
+			}
+		}
+	}
+}
+
+func TestFirstBadError(t *testing.T) {
+	failure := errors.New("build failed")
+	check := func(idx int) (bool, error) {
+		return false, failure
+	}
+
+	if _, err := firstBad(8, check); err != failure {
+		t.Errorf("firstBad returned %v, want %v", err, failure)
+	}
+	// A single build is known to be bad, and is not checked
+	if got, err := firstBad(1, check); got != 0 || err != nil {
+		t.Errorf("firstBad of one build returned %d, %v", got, err)
+	}
+}
@@ -4,15 +4,20 @@ import (
 	"errors"
 	"fmt"
 	"github.com/SeanHeelan/Malamute/config"
+	"github.com/SeanHeelan/Malamute/crashdb"
 	"github.com/SeanHeelan/Malamute/data"
 	"github.com/SeanHeelan/Malamute/logging"
 	"github.com/SeanHeelan/Malamute/monitor"
 	"github.com/SeanHeelan/Malamute/mutate"
 	"github.com/SeanHeelan/Malamute/resultproc"
 	"github.com/SeanHeelan/Malamute/session"
+	"github.com/SeanHeelan/Malamute/triage"
+	"io/ioutil"
 	"log"
 	"math/rand"
+	"path/filepath"
 	"runtime"
+	"sync"
 	"time"
 )
 
@@ -45,6 +50,127 @@ func startMutator(s *session.Session, l *logging.Logs, errChan chan error,
 	return nil
 }
 
+// monitorLayout returns the number of monitors to start, and the CPU that
+// each should be pinned to. If no CPU set is configured then the monitors
+// are not pinned, and nil is returned in place of the CPUs.
+func monitorLayout(cfg *config.Config) (int, []int, error) {
+	if len(cfg.TestProcessing.CpuSet) == 0 {
+		workers := cfg.TestProcessing.Workers
+		if workers == 0 {
+			workers = runtime.NumCPU() * 2
+		}
+		return workers, nil, nil
+	}
+
+	cpuSet, err := config.ParseCpuSet(cfg.TestProcessing.CpuSet)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	allowed, err := monitor.AllowedCPUs()
+	if err != nil {
+		return 0, nil, err
+	}
+	allowedSet := make(map[int]bool)
+	for _, cpu := range allowed {
+		allowedSet[cpu] = true
+	}
+	for _, cpu := range cpuSet {
+		if !allowedSet[cpu] {
+			return 0, nil, fmt.Errorf("CPU %d is not available. Available "+
+				"CPUs are %v", cpu, allowed)
+		}
+	}
+
+	workers := cfg.TestProcessing.Workers
+	if workers == 0 {
+		workers = len(cpuSet)
+	}
+
+	cpus := []int{}
+	for i := 0; i < workers; i++ {
+		cpus = append(cpus, cpuSet[i%len(cpuSet)])
+	}
+
+	return workers, cpus, nil
+}
+
+// runMonitor pins the calling goroutine to cpu, unless cpu is -1, and then
+// runs the monitor work loop run. exited is told once the work loop returns,
+// whether or not it started.
+func runMonitor(run func(chan data.TestCase, chan data.TestCase, chan error),
+	cpu int, monitorIn chan data.TestCase, monitorOut chan data.TestCase,
+	errChan chan error, exited *sync.WaitGroup) {
+
+	defer exited.Done()
+	if cpu != -1 {
+		if err := monitor.PinToCPU(cpu); err != nil {
+			errChan <- err
+			return
+		}
+	}
+
+	run(monitorIn, monitorOut, errChan)
+}
+
+// startMonitors starts the monitors, laid out as described by the config,
+// and records the layout in the session. The returned WaitGroup is done
+// once every monitor has exited.
+func startMonitors(s *session.Session, l *logging.Logs, errChan chan error,
+	monitorIn chan data.TestCase,
+	monitorOut chan data.TestCase) (*sync.WaitGroup, error) {
+
+	count, cpus, err := monitorLayout(s.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Config.Cores.Enabled {
+		if err := monitor.EnableCoreDumps(); err != nil {
+			return nil, err
+		}
+	}
+	s.MonitorCount = count
+	s.MonitorCPUs = cpus
+
+	if cpus == nil {
+		log.Printf("Starting %d monitors\n", count)
+	} else {
+		log.Printf("Starting %d monitors pinned to CPUs %v\n", count, cpus)
+	}
+
+	exited := &sync.WaitGroup{}
+	for i := 0; i < count; i++ {
+		var run func(chan data.TestCase, chan data.TestCase, chan error)
+
+		// Sessions created before the monitor could be selected will not
+		// have one set
+		if s.Config.Interpreter.Monitor == config.MONITOR_EXITCODE ||
+			len(s.Config.Interpreter.Monitor) == 0 {
+			exitCode := monitor.ExitCode{s, l}
+			run = exitCode.Run
+		} else if s.Config.Interpreter.Monitor == config.MONITOR_PERSISTENT {
+			persistent := monitor.Persistent{s, l}
+			run = persistent.Run
+		} else if s.Config.Interpreter.Monitor == config.MONITOR_DIFF {
+			differential := monitor.Differential{s, l}
+			run = differential.Run
+		} else {
+			return nil, fmt.Errorf("Invalid monitor selector %s",
+				s.Config.Interpreter.Monitor)
+		}
+
+		cpu := -1
+		if cpus != nil {
+			cpu = cpus[i]
+		}
+		exited.Add(1)
+		go runMonitor(run, cpu, monitorIn, monitorOut, errChan, exited)
+	}
+
+	return exited, nil
+}
+
 func isMultiFileMutator(mutator string) bool {
 	if mutator == config.FUZZER_RADAMSA_MULTIFILE {
 		return true
@@ -53,28 +179,162 @@ func isMultiFileMutator(mutator string) bool {
 	return false
 }
 
-func getMutationRequest(cfg config.TestProcessingConfig, seeds []string,
+// pickSeed selects a seed at random. If the corpus is not empty then
+// corpusPercent gives the chance, out of 100, that the seed will be taken
+// from it rather than from the original seed tests.
+func pickSeed(seeds []string, corpus []string, corpusPercent int) string {
+	if len(corpus) != 0 && rand.Int()%100 < corpusPercent {
+		return corpus[rand.Int()%len(corpus)]
+	}
+
+	return seeds[rand.Int()%len(seeds)]
+}
+
+func getMutationRequest(cfg *config.Config, seeds []string, corpus []string,
 	batchSize int) mutate.Request {
 
-	if !isMultiFileMutator(cfg.Fuzzer) {
-		idx := rand.Int() % len(seeds)
-		seedFile := seeds[idx]
+	corpusPercent := cfg.Coverage.CorpusSelectionPercent
+	if !isMultiFileMutator(cfg.TestProcessing.Fuzzer) {
+		seedFile := pickSeed(seeds, corpus, corpusPercent)
 		log.Printf("Selecting %s as the next seed file\n", seedFile)
 		return mutate.Request{[]string{seedFile}, batchSize}
 	}
 
 	sources := []string{}
-	gap := cfg.MultiFileFuzzerSeedCountMax - cfg.MultiFileFuzzerSeedCountMin
-	seedsToUse := cfg.MultiFileFuzzerSeedCountMin + (rand.Int() % (gap + 1))
+	gap := cfg.TestProcessing.MultiFileFuzzerSeedCountMax -
+		cfg.TestProcessing.MultiFileFuzzerSeedCountMin
+	seedsToUse := cfg.TestProcessing.MultiFileFuzzerSeedCountMin +
+		(rand.Int() % (gap + 1))
 	for i := 0; i < seedsToUse; i++ {
-		idx := rand.Int() % len(seeds)
-		sources = append(sources, seeds[idx])
+		sources = append(sources, pickSeed(seeds, corpus, corpusPercent))
 	}
 
 	log.Printf("Selecting %v as the next seed files\n", sources)
 	return mutate.Request{sources, batchSize}
 }
 
+// loadCorpus returns the paths of all files already in the corpus
+// directory of the session, and in the interesting directory if interesting
+// test cases are to be mutated
+func loadCorpus(s *session.Session) ([]string, error) {
+	dirs := []string{s.CorpusDir}
+	if s.Config.Interesting.Mutate {
+		dirs = append(dirs, s.InterestingDir)
+	}
+
+	corpusFiles := []string{}
+	for _, dir := range dirs {
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return corpusFiles, err
+		}
+
+		// Links in the interesting directory point into the corpus, and
+		// so are skipped
+		for _, entry := range entries {
+			if entry.Mode().IsRegular() &&
+				entry.Name() != resultproc.INTERESTING_INDEX {
+				corpusFiles = append(corpusFiles,
+					filepath.Join(dir, entry.Name()))
+			}
+		}
+	}
+
+	return corpusFiles, nil
+}
+
+// finalProfileReport merges the profiles left over at the end of a run and
+// saves the resulting coverage to the session
+func finalProfileReport(s *session.Session) {
+	if err := UpdateProfileReport(s); err != nil {
+		log.Printf("Failed to update the profile report. Error: %s", err)
+		return
+	}
+
+	if err := s.Save(); err != nil {
+		log.Printf("Failed to save session. Error: %s", err)
+	}
+
+	if err := s.LogSummary(); err != nil {
+		log.Printf("Failed to log session summary. Error: %s", err)
+	}
+}
+
+// updateStats records the result of a single test in the session stats
+func updateStats(s *session.Session, tc data.TestCase) {
+	if tc.BugFound {
+		if len(tc.PreservationDir) != 0 {
+			log.Printf("Potential bug: details %s\n", tc.PreservationDir)
+		}
+		if tc.HangConfirmed {
+			s.Stats.HangCount++
+		} else {
+			s.Stats.CrashCount++
+		}
+		if len(tc.Bucket) != 0 {
+			s.Stats.AddCrashBucket(tc.Bucket, triage.Verdict{
+				Severity: tc.Severity,
+				Reason:   tc.SeverityReason,
+			})
+		}
+	}
+	if len(tc.InterestingReason) != 0 {
+		s.Stats.AddInteresting(tc.InterestingReason)
+	}
+	if tc.ClassifierRejected {
+		s.Stats.ClassifierRejections++
+	}
+	if tc.BugFound && len(tc.ParentRelation) != 0 {
+		s.Stats.AddLineage(tc.ParentRelation)
+	}
+	if tc.BugFound && tc.CrashDBLabel == crashdb.LABEL_KNOWN {
+		s.Stats.KnownCrashes++
+	}
+	if len(tc.IgnoredAs) != 0 {
+		s.Stats.AddIgnored(tc.IgnoredAs)
+	}
+	s.Stats.TestCasesProcessed++
+
+	for _, f := range tc.SeedFilePaths {
+		s.Stats.AddTestCaseForSeed(f)
+	}
+
+	if tc.TestTimedOut {
+		s.Stats.TimedOutTests++
+	} else if len(tc.Unrunnable) != 0 {
+		s.Stats.UnrunnableTests++
+	} else {
+		s.Stats.AddExitCode(tc.ExitCode)
+		s.Stats.AddResourceUsage(tc.WallTime, tc.UserTime+tc.SysTime,
+			tc.MaxRSS)
+	}
+	s.Stats.LeakedProcesses += tc.LeakedProcesses
+	s.Stats.CoverageEdges += tc.NewEdges
+	if tc.ExpectationSkipped {
+		s.Stats.ExpectationsSkipped++
+	}
+	if tc.ExpectationChecked {
+		s.Stats.ExpectationsChecked++
+		if len(tc.ExpectationMismatch) != 0 {
+			s.Stats.ExpectationMismatches++
+			if tc.Unmutated {
+				s.Stats.Regressions++
+			}
+		}
+	}
+
+	switch tc.ResourceAnomaly {
+	case monitor.RESOURCE_CPU:
+		s.Stats.PerformanceAnomalies++
+	case monitor.RESOURCE_MEMORY:
+		s.Stats.MemoryAnomalies++
+	}
+
+	if len(tc.CorpusPath) != 0 {
+		s.Stats.CorpusSize++
+	}
+}
+
 func Run(s *session.Session, l *logging.Logs, seedFiles []string,
 	termIndicator chan int) {
 
@@ -87,6 +347,13 @@ func Run(s *session.Session, l *logging.Logs, seedFiles []string,
 		s.Config.TestProcessing.BatchSize = s.Config.TestProcessing.TestCount
 	}
 
+	chain, err := resultproc.NewChain(s)
+	if err != nil {
+		log.Printf("Error starting the result processors %s", err)
+		termIndicator <- 1
+		return
+	}
+
 	errChan := make(chan error)
 	mutatorIn := make(chan mutate.Request, 1)
 	mutatorOut := make(chan data.TestCase, batchSize)
@@ -97,8 +364,8 @@ func Run(s *session.Session, l *logging.Logs, seedFiles []string,
 		return
 	}
 
-	// Figure out how many CPUs are available, and thus how many monitors
-	// we may use
+	// Make sure the monitors and the rest of the pipeline may use every
+	// CPU
 	numCpus := runtime.NumCPU()
 	currMaxProcs := runtime.GOMAXPROCS(0)
 	if currMaxProcs < numCpus {
@@ -107,50 +374,56 @@ func Run(s *session.Session, l *logging.Logs, seedFiles []string,
 		runtime.GOMAXPROCS(numCpus)
 	}
 
-	// Start numCpus * 2 monitors
 	monitorOut := make(chan data.TestCase, batchSize)
-	log.Printf("Starting %d monitors as the CPU count is %d\n", numCpus*2,
-		numCpus)
-	for i := 0; i < numCpus*2; i++ {
-		go monitor.ExitCode(s.Config, mutatorOut, monitorOut, errChan)
+	if _, err := startMonitors(s, l, errChan, mutatorOut,
+		monitorOut); err != nil {
+		log.Printf("Error starting monitors %s", err)
+		termIndicator <- 1
+		return
 	}
 
 	resultprocOut := make(chan data.TestCase, batchSize)
-	go resultproc.LogFile(s.PreservationDir, monitorOut, resultprocOut,
-		errChan)
+	go chain.Run(monitorOut, resultprocOut)
 
-	mutatorIn <- getMutationRequest(s.Config.TestProcessing,
-		seedFiles, batchSize)
+	corpusFiles, err := loadCorpus(s)
+	if err != nil {
+		log.Printf("Error loading the corpus %s", err)
+		termIndicator <- 1
+		return
+	}
+
+	mutatorIn <- getMutationRequest(s.Config, seedFiles, corpusFiles,
+		batchSize)
 
 	fuzzFilesRequested := batchSize
 
 	startTime := time.Now()
 
+	merger := newProfileMerger(s)
+
 ManageLoop:
 	for {
 		var tc data.TestCase
 		select {
 		case tc = <-resultprocOut:
-			if tc.BugFound {
-				log.Printf("Potential bug: details %s\n", tc.PreservationDir)
-				s.Stats.CrashCount++
+			updateStats(s, tc)
+			if len(tc.CorpusPath) != 0 {
+				corpusFiles = append(corpusFiles, tc.CorpusPath)
 			}
-			s.Stats.TestCasesProcessed++
-
-			for _, f := range tc.SeedFilePaths {
-				s.Stats.AddTestCaseForSeed(f)
-			}
-
-			if tc.TestTimedOut {
-				s.Stats.TimedOutTests++
-			} else {
-				s.Stats.AddExitCode(tc.ExitCode)
+			// A test case in the corpus is only linked to from the
+			// interesting directory, and must not be mutated twice as often
+			if len(tc.InterestingPath) != 0 && len(tc.CorpusPath) == 0 &&
+				s.Config.Interesting.Mutate {
+				corpusFiles = append(corpusFiles, tc.InterestingPath)
 			}
 
 			if err := s.Save(); err != nil {
 				log.Printf("Failed to save session. Error: %s", err)
 				break ManageLoop
 			}
+		case result := <-merger.done:
+			merger.finish(result)
+			continue
 		case err := <-errChan:
 			log.Printf("%s\n", err)
 			break ManageLoop
@@ -160,6 +433,11 @@ ManageLoop:
 			log.Printf("%d fuzz files processed\n", s.Stats.TestCasesProcessed)
 		}
 
+		if s.Config.Profile.Enabled && s.Config.Profile.MergeInterval != 0 &&
+			s.Stats.TestCasesProcessed%s.Config.Profile.MergeInterval == 0 {
+			merger.start()
+		}
+
 		if s.Stats.TestCasesProcessed%batchSize == 0 {
 			if err := s.LogSummary(); err != nil {
 				log.Printf("Failed to log session summary. Error: %s", err)
@@ -182,14 +460,19 @@ ManageLoop:
 		}
 
 		if fuzzFilesRequested-s.Stats.TestCasesProcessed <= batchSize/REQ_THRESHOLD {
-			mutatorIn <- getMutationRequest(s.Config.TestProcessing,
-				seedFiles, batchSize)
+			mutatorIn <- getMutationRequest(s.Config, seedFiles,
+				corpusFiles, batchSize)
 			fuzzFilesRequested += batchSize
 		}
 	}
 
 	close(mutatorIn)
 
+	if s.Config.Profile.Enabled {
+		merger.wait()
+		finalProfileReport(s)
+	}
+
 	log.Printf("%d fuzz files processed. Exiting...\n", s.Stats.TestCasesProcessed)
 
 	termIndicator <- 1
@@ -201,6 +484,13 @@ func CoverAllSeedsOnce(s *session.Session, l *logging.Logs, seedFiles []string,
 	rand.Seed(int64(s.Config.General.Seed))
 	batchSize := s.Config.TestProcessing.BatchSize
 
+	chain, err := resultproc.NewChain(s)
+	if err != nil {
+		log.Printf("Error starting the result processors %s", err)
+		termIndicator <- 1
+		return
+	}
+
 	errChan := make(chan error)
 	mutatorIn := make(chan mutate.Request, 1)
 	mutatorOut := make(chan data.TestCase, batchSize)
@@ -211,8 +501,8 @@ func CoverAllSeedsOnce(s *session.Session, l *logging.Logs, seedFiles []string,
 		return
 	}
 
-	// Figure out how many CPUs are available, and thus how many monitors
-	// we may use
+	// Make sure the monitors and the rest of the pipeline may use every
+	// CPU
 	numCpus := runtime.NumCPU()
 	currMaxProcs := runtime.GOMAXPROCS(0)
 	if currMaxProcs < numCpus {
@@ -221,17 +511,16 @@ func CoverAllSeedsOnce(s *session.Session, l *logging.Logs, seedFiles []string,
 		runtime.GOMAXPROCS(numCpus)
 	}
 
-	// Start numCpus * 2 monitors
 	monitorOut := make(chan data.TestCase, batchSize)
-	log.Printf("Starting %d monitors as the CPU count is %d\n", numCpus*2,
-		numCpus)
-	for i := 0; i < numCpus*2; i++ {
-		go monitor.ExitCode(s.Config, mutatorOut, monitorOut, errChan)
+	if _, err := startMonitors(s, l, errChan, mutatorOut,
+		monitorOut); err != nil {
+		log.Printf("Error starting monitors %s", err)
+		termIndicator <- 1
+		return
 	}
 
 	resultprocOut := make(chan data.TestCase, batchSize)
-	go resultproc.LogFile(s.PreservationDir, monitorOut, resultprocOut,
-		errChan)
+	go chain.Run(monitorOut, resultprocOut)
 
 	idx := rand.Int() % len(seedFiles)
 	seedFile := seedFiles[idx]
@@ -246,31 +535,22 @@ func CoverAllSeedsOnce(s *session.Session, l *logging.Logs, seedFiles []string,
 	fuzzFilesRequested := batchSize
 
 	startTime := time.Now()
+	merger := newProfileMerger(s)
+
 ManageLoop:
 	for {
 		var tc data.TestCase
 		select {
 		case tc = <-resultprocOut:
-			if tc.BugFound {
-				log.Printf("Potential bug: details %s\n", tc.PreservationDir)
-				s.Stats.CrashCount++
-			}
-			s.Stats.TestCasesProcessed++
-
-			for _, f := range tc.SeedFilePaths {
-				s.Stats.AddTestCaseForSeed(f)
-			}
-
-			if tc.TestTimedOut {
-				s.Stats.TimedOutTests++
-			} else {
-				s.Stats.AddExitCode(tc.ExitCode)
-			}
+			updateStats(s, tc)
 
 			if err := s.Save(); err != nil {
 				log.Printf("Failed to save session. Error: %s", err)
 				break ManageLoop
 			}
+		case result := <-merger.done:
+			merger.finish(result)
+			continue
 		case err := <-errChan:
 			log.Printf("Error: %s\n", err)
 			break ManageLoop
@@ -280,6 +560,11 @@ ManageLoop:
 			log.Printf("%d fuzz files processed\n", s.Stats.TestCasesProcessed)
 		}
 
+		if s.Config.Profile.Enabled && s.Config.Profile.MergeInterval != 0 &&
+			s.Stats.TestCasesProcessed%s.Config.Profile.MergeInterval == 0 {
+			merger.start()
+		}
+
 		if s.Stats.TestCasesProcessed%batchSize == 0 {
 			if err := s.LogSummary(); err != nil {
 				log.Printf("Failed to log session summary. Error: %s", err)
@@ -324,6 +609,11 @@ ManageLoop:
 
 	close(mutatorIn)
 
+	if s.Config.Profile.Enabled {
+		merger.wait()
+		finalProfileReport(s)
+	}
+
 	log.Printf("%d fuzz files processed. Exiting...\n", s.Stats.TestCasesProcessed)
 
 	termIndicator <- 1
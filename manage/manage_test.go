@@ -0,0 +1,70 @@
+package manage
+
+import (
+	"fmt"
+	"github.com/SeanHeelan/Malamute/config"
+	"github.com/SeanHeelan/Malamute/monitor"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestMonitorLayout(t *testing.T) {
+	allowed, err := monitor.AllowedCPUs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cpu := allowed[0]
+	allowedSet := make(map[int]bool)
+	for _, c := range allowed {
+		allowedSet[c] = true
+	}
+	unavailable := config.MAX_CPUS - 1
+	for allowedSet[unavailable] {
+		unavailable--
+	}
+
+	tests := []struct {
+		name        string
+		cpuSet      string
+		workers     int
+		wantWorkers int
+		wantCPUs    []int
+		wantErr     bool
+	}{
+		{name: "default", wantWorkers: runtime.NumCPU() * 2},
+		{name: "workers", workers: 3, wantWorkers: 3},
+		{name: "one per CPU", cpuSet: fmt.Sprint(cpu), wantWorkers: 1,
+			wantCPUs: []int{cpu}},
+		{name: "shared CPU", cpuSet: fmt.Sprint(cpu), workers: 3,
+			wantWorkers: 3, wantCPUs: []int{cpu, cpu, cpu}},
+		{name: "unavailable CPU", cpuSet: fmt.Sprint(unavailable),
+			wantErr: true},
+		{name: "invalid CPU set", cpuSet: "x", wantErr: true},
+	}
+
+	for _, test := range tests {
+		cfg := &config.Config{}
+		cfg.TestProcessing.CpuSet = test.cpuSet
+		cfg.TestProcessing.Workers = test.workers
+
+		workers, cpus, err := monitorLayout(cfg)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: err = %v, want error %v", test.name, err,
+				test.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		if workers != test.wantWorkers {
+			t.Errorf("%s: %d workers, want %d", test.name, workers,
+				test.wantWorkers)
+		}
+		if !reflect.DeepEqual(cpus, test.wantCPUs) {
+			t.Errorf("%s: CPUs = %v, want %v", test.name, cpus,
+				test.wantCPUs)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package manage
+
+import (
+	"fmt"
+	"github.com/SeanHeelan/Malamute/data"
+	"github.com/SeanHeelan/Malamute/fs"
+	"github.com/SeanHeelan/Malamute/logging"
+	"github.com/SeanHeelan/Malamute/resultproc"
+	"github.com/SeanHeelan/Malamute/session"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	PREFLIGHT_PREFIX = "preflight"
+)
+
+// seedResult determines the outcome of running a seed, unmutated, and
+// whether it should be quarantined as a result. A seed that triggers a bug
+// has already been passed through the result processors, and is only
+// quarantined if every test generated from it would crash or hang in the
+// same way.
+func seedResult(s *session.Session, tc data.TestCase) *session.SeedResult {
+	result := session.SeedResult{
+		ExitCode: tc.ExitCode,
+		Duration: tc.WallTime,
+		CPUTime:  tc.UserTime + tc.SysTime,
+		MaxRSS:   tc.MaxRSS,
+	}
+
+	if tc.BugFound {
+		switch tc.BugClass {
+		case resultproc.BUGCLASS_CRASH:
+			result.Outcome = session.SEED_OUTCOME_CRASHED
+			result.Quarantined = true
+		case resultproc.BUGCLASS_HANG:
+			result.Outcome = session.SEED_OUTCOME_TIMEDOUT
+			result.Quarantined = true
+		default:
+			result.Outcome = session.SEED_OUTCOME_FAILED
+		}
+
+		result.Reason = fmt.Sprintf("%s without mutation", tc.BugClass)
+		if len(tc.PreservationDir) != 0 {
+			result.Reason += fmt.Sprintf(", preserved in %s",
+				tc.PreservationDir)
+		} else if len(tc.DroppedBy) != 0 {
+			result.Reason += fmt.Sprintf(", dropped by %s", tc.DroppedBy)
+		}
+
+		if !result.Quarantined {
+			calibrateTimeout(s, &result)
+		}
+		return &result
+	}
+
+	if len(tc.Unrunnable) != 0 {
+		result.Outcome = session.SEED_OUTCOME_FAILED
+		result.Quarantined = true
+		result.Reason = fmt.Sprintf("could not be run, as %s", tc.Unrunnable)
+		return &result
+	}
+
+	if tc.TestTimedOut {
+		result.Outcome = session.SEED_OUTCOME_TIMEDOUT
+		result.Quarantined = true
+		result.Reason = "timed out"
+		return &result
+	}
+
+	if tc.ExitCode != 0 {
+		result.Outcome = session.SEED_OUTCOME_FAILED
+		if s.Config.Preflight.QuarantineFailures {
+			result.Quarantined = true
+			result.Reason = fmt.Sprintf("exited with %d", tc.ExitCode)
+		} else {
+			calibrateTimeout(s, &result)
+		}
+		return &result
+	}
+
+	result.Outcome = session.SEED_OUTCOME_OK
+	calibrateTimeout(s, &result)
+	return &result
+}
+
+// calibrateTimeout sets the timeout for tests generated from a seed to a
+// multiple of its run time, within the configured limits
+func calibrateTimeout(s *session.Session, result *session.SeedResult) {
+	cfg := s.Config.Timeout
+	if !cfg.Calibrate {
+		return
+	}
+
+	timeout := result.Duration * time.Duration(cfg.Multiplier)
+	if timeout < cfg.Min.Duration {
+		timeout = cfg.Min.Duration
+	}
+	if timeout > cfg.Max.Duration {
+		timeout = cfg.Max.Duration
+	}
+
+	result.Timeout = timeout
+}
+
+// preflightCopy copies seed to where the mutators would put tests generated
+// from it, so that the monitor can run it exactly as it would a fuzz file
+func preflightCopy(s *session.Session, seed string, idx int) (string, error) {
+	var workingDir string
+	if s.Config.TestProcessing.GenerateTestsInPlace {
+		workingDir = filepath.Dir(seed)
+	} else {
+		workingDir = s.TestCasesDir
+	}
+
+	copyName := fmt.Sprintf("%s_%d_%s", PREFLIGHT_PREFIX, idx,
+		filepath.Base(seed))
+	copyPath := filepath.Join(workingDir, copyName)
+	if err := fs.CopyFileContents(seed, copyPath); err != nil {
+		return "", err
+	}
+
+	return copyPath, nil
+}
+
+// Preflight runs each seed that has not been run before once, unmutated,
+// through the monitors and records the outcome in the session. Seeds that
+// trigger a bug are passed through the result processors, so that the bug is
+// preserved and reported as it would be while fuzzing. Seeds that crash or
+// time out are quarantined, and a report is written to the session
+// directory. The seeds that remain to be fuzzed are returned.
+func Preflight(s *session.Session, l *logging.Logs,
+	seedFiles []string) ([]string, error) {
+
+	pending := []string{}
+	for _, seed := range seedFiles {
+		if _, ok := s.Seeds[seed]; !ok {
+			pending = append(pending, seed)
+		}
+	}
+
+	if len(pending) != 0 {
+		log.Printf("Running %d seeds before fuzzing ...", len(pending))
+		if err := runPreflight(s, l, pending); err != nil {
+			return nil, err
+		}
+
+		if err := s.Save(); err != nil {
+			return nil, err
+		}
+
+		if err := s.LogPreflight(); err != nil {
+			return nil, err
+		}
+	}
+
+	filtered := []string{}
+	for _, seed := range seedFiles {
+		if !s.IsQuarantined(seed) {
+			filtered = append(filtered, seed)
+		}
+	}
+
+	log.Printf("%d of %d seeds are quarantined. See %s", len(seedFiles)-
+		len(filtered), len(seedFiles), session.PREFLIGHT_FILE)
+	return filtered, nil
+}
+
+func runPreflight(s *session.Session, l *logging.Logs,
+	seeds []string) error {
+
+	count, _, err := monitorLayout(s.Config)
+	if err != nil {
+		return err
+	}
+
+	chain, err := resultproc.NewChain(s)
+	if err != nil {
+		return err
+	}
+
+	errChan := make(chan error)
+	monitorIn := make(chan data.TestCase, len(seeds))
+	// Room is left for the end of stream test case from each monitor
+	monitorOut := make(chan data.TestCase, len(seeds)+count)
+
+	exited, err := startMonitors(s, l, errChan, monitorIn, monitorOut)
+	if err != nil {
+		return err
+	}
+	monitorsDone := make(chan bool)
+	go func() {
+		exited.Wait()
+		close(monitorsDone)
+	}()
+
+	copies := make(map[string]string)
+	for idx, seed := range seeds {
+		copyPath, err := preflightCopy(s, seed, idx)
+		if err != nil {
+			close(monitorIn)
+			return err
+		}
+		copies[seed] = copyPath
+
+		tc := data.NewTestCase()
+		tc.FuzzFilePath = copyPath
+		tc.SeedFilePaths = []string{seed}
+		monitorIn <- tc
+	}
+	// Each monitor passes on a single end of stream test case and exits
+	close(monitorIn)
+
+	// The monitors look up the timeouts of seeds in s.Seeds, so results are
+	// only added to it once they have all exited
+	results := make(map[string]*session.SeedResult)
+	recordResult := func(tc data.TestCase) {
+		if len(tc.SeedFilePaths) == 0 {
+			return
+		}
+
+		if len(resultproc.Classify(tc)) != 0 {
+			chain.Process(&tc)
+			updateStats(s, tc)
+		} else {
+			resultproc.RemoveRunFiles(tc)
+		}
+
+		seed := tc.SeedFilePaths[0]
+		result := seedResult(s, tc)
+		results[seed] = result
+		if result.Quarantined {
+			log.Printf("Quarantining seed %s : %s", seed, result.Reason)
+		}
+	}
+
+	// A monitor may report an error for a single test and carry on, or fail
+	// to start and exit without running anything, so the run is over once
+	// every monitor has exited rather than once every seed is accounted for
+	var firstErr error
+	for running := true; running; {
+		select {
+		case tc := <-monitorOut:
+			recordResult(tc)
+		case err := <-errChan:
+			if firstErr == nil {
+				firstErr = err
+			}
+			log.Printf("Error during the pre-flight run: %s", err)
+		case <-monitorsDone:
+			running = false
+		}
+	}
+
+	// monitorOut has room for every result, so the monitors never block on
+	// it and some may be left unread
+	for drained := false; !drained; {
+		select {
+		case tc := <-monitorOut:
+			recordResult(tc)
+		default:
+			drained = true
+		}
+	}
+
+	for _, copyPath := range copies {
+		if err := os.Remove(copyPath); err != nil && !os.IsNotExist(err) {
+			log.Printf("Failed to remove %s : %s", copyPath, err)
+		}
+	}
+
+	for seed, result := range results {
+		s.Seeds[seed] = result
+	}
+
+	// Nothing was run if every monitor failed to start
+	if len(results) == 0 {
+		return firstErr
+	}
+
+	return nil
+}
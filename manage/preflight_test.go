@@ -0,0 +1,142 @@
+package manage
+
+import (
+	"github.com/SeanHeelan/Malamute/config"
+	"github.com/SeanHeelan/Malamute/data"
+	"github.com/SeanHeelan/Malamute/resultproc"
+	"github.com/SeanHeelan/Malamute/session"
+	"testing"
+	"time"
+)
+
+func TestSeedResult(t *testing.T) {
+	tests := []struct {
+		name               string
+		testCase           data.TestCase
+		quarantineFailures bool
+		wantOutcome        string
+		wantQuarantined    bool
+		wantReason         string
+	}{
+		{
+			name:        "ok",
+			testCase:    data.TestCase{},
+			wantOutcome: session.SEED_OUTCOME_OK,
+		},
+		{
+			name:        "failure kept",
+			testCase:    data.TestCase{ExitCode: 255},
+			wantOutcome: session.SEED_OUTCOME_FAILED,
+		},
+		{
+			name:               "failure quarantined",
+			testCase:           data.TestCase{ExitCode: 255},
+			quarantineFailures: true,
+			wantOutcome:        session.SEED_OUTCOME_FAILED,
+			wantQuarantined:    true,
+			wantReason:         "exited with 255",
+		},
+		{
+			name:            "timed out",
+			testCase:        data.TestCase{TestTimedOut: true},
+			wantOutcome:     session.SEED_OUTCOME_TIMEDOUT,
+			wantQuarantined: true,
+			wantReason:      "timed out",
+		},
+		{
+			name: "unrunnable",
+			testCase: data.TestCase{
+				Unrunnable: "an argument holds a NUL byte"},
+			wantOutcome:     session.SEED_OUTCOME_FAILED,
+			wantQuarantined: true,
+			wantReason: "could not be run, as an argument holds a NUL " +
+				"byte",
+		},
+		{
+			name: "preserved crash",
+			testCase: data.TestCase{ExitCode: resultproc.SIGSEGV,
+				BugFound: true, BugClass: resultproc.BUGCLASS_CRASH,
+				PreservationDir: "/s/crashes/1"},
+			wantOutcome:     session.SEED_OUTCOME_CRASHED,
+			wantQuarantined: true,
+			wantReason:      "crash without mutation, preserved in /s/crashes/1",
+		},
+		{
+			name: "ignored crash",
+			testCase: data.TestCase{ExitCode: resultproc.SIGSEGV,
+				BugFound: true, BugClass: resultproc.BUGCLASS_CRASH,
+				DroppedBy: config.RESULTPROC_IGNORE},
+			wantOutcome:     session.SEED_OUTCOME_CRASHED,
+			wantQuarantined: true,
+			wantReason:      "crash without mutation, dropped by ignore",
+		},
+		{
+			name: "confirmed hang",
+			testCase: data.TestCase{TestTimedOut: true, BugFound: true,
+				BugClass:        resultproc.BUGCLASS_HANG,
+				PreservationDir: "/s/hangs/1"},
+			wantOutcome:     session.SEED_OUTCOME_TIMEDOUT,
+			wantQuarantined: true,
+			wantReason:      "hang without mutation, preserved in /s/hangs/1",
+		},
+		{
+			// Mutated tests will differ anyway, so the seed is still
+			// fuzzed
+			name: "regression",
+			testCase: data.TestCase{BugFound: true,
+				BugClass:        resultproc.BUGCLASS_REGRESSION,
+				PreservationDir: "/s/crashes/2"},
+			wantOutcome: session.SEED_OUTCOME_FAILED,
+			wantReason: "regression without mutation, preserved in " +
+				"/s/crashes/2",
+		},
+	}
+
+	for _, test := range tests {
+		s := &session.Session{Config: &config.Config{}}
+		s.Config.Preflight.QuarantineFailures = test.quarantineFailures
+
+		result := seedResult(s, test.testCase)
+		if result.Outcome != test.wantOutcome {
+			t.Errorf("%s: Outcome = %q, want %q", test.name,
+				result.Outcome, test.wantOutcome)
+		}
+		if result.Quarantined != test.wantQuarantined {
+			t.Errorf("%s: Quarantined = %v, want %v", test.name,
+				result.Quarantined, test.wantQuarantined)
+		}
+		if result.Reason != test.wantReason {
+			t.Errorf("%s: Reason = %q, want %q", test.name, result.Reason,
+				test.wantReason)
+		}
+	}
+}
+
+func TestCalibrateTimeout(t *testing.T) {
+	tests := []struct {
+		name      string
+		calibrate bool
+		duration  time.Duration
+		want      time.Duration
+	}{
+		{"disabled", false, time.Second, 0},
+		{"multiple", true, 300 * time.Millisecond, 1500 * time.Millisecond},
+		{"minimum", true, time.Millisecond, 200 * time.Millisecond},
+		{"maximum", true, 4 * time.Second, 10 * time.Second},
+	}
+
+	for _, test := range tests {
+		s := &session.Session{Config: &config.Config{}}
+		s.Config.Timeout.Calibrate = test.calibrate
+		s.Config.Timeout.Multiplier = 5
+		s.Config.Timeout.Min.Duration = 200 * time.Millisecond
+		s.Config.Timeout.Max.Duration = 10 * time.Second
+
+		result := session.SeedResult{Duration: test.duration}
+		calibrateTimeout(s, &result)
+		if result.Timeout != test.want {
+			t.Errorf("%s: Timeout = %s, want %s", test.name,
+				result.Timeout, test.want)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package manage
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/SeanHeelan/Malamute/coverage"
+	"github.com/SeanHeelan/Malamute/session"
+	"log"
+	"os"
+	"path"
+	"sort"
+)
+
+// profileTotals is the overall source coverage of a merged profile
+type profileTotals struct {
+	Lines            int
+	LinesCovered     int
+	Functions        int
+	FunctionsCovered int
+}
+
+// UpdateProfileReport merges any raw profiles written since the last merge
+// into the profile of the session, and then regenerates the per-file and
+// per-function source coverage reports from it. The session statistics are
+// updated with the overall coverage.
+func UpdateProfileReport(s *session.Session) error {
+	totals, err := mergeProfiles(s)
+	if err != nil {
+		return err
+	}
+
+	recordProfileTotals(s, totals)
+	return nil
+}
+
+// mergeProfiles does the work of UpdateProfileReport, other than updating
+// the statistics, so that it does not touch anything the manager may be
+// using at the same time. The overall coverage is returned, or nil if
+// nothing has been merged yet.
+func mergeProfiles(s *session.Session) (*profileTotals, error) {
+	cfg := s.Config
+	profdataPath := path.Join(s.ProfileDir, session.PROFILE_DATA)
+
+	merged, err := coverage.MergeProfiles(cfg.Profile.ProfdataPath,
+		s.ProfileDir, profdataPath)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Merged %d raw profiles\n", merged)
+
+	if _, err := os.Stat(profdataPath); err != nil {
+		// Nothing has been merged yet
+		return nil, nil
+	}
+
+	files, err := coverage.FileSummaries(cfg.Profile.CovPath,
+		cfg.Interpreter.Path, profdataPath)
+	if err != nil {
+		return nil, err
+	}
+
+	functions, err := coverage.FunctionCounts(cfg.Profile.ProfdataPath,
+		profdataPath)
+	if err != nil {
+		return nil, err
+	}
+
+	totals := profileTotals{}
+	for _, f := range files {
+		totals.Lines += f.Lines
+		totals.LinesCovered += f.LinesCovered
+		totals.Functions += f.Functions
+		totals.FunctionsCovered += f.FunctionsCovered
+	}
+
+	if err := writeFileReport(path.Join(s.SessionDir, session.PROFILE_FILES),
+		files); err != nil {
+		return nil, err
+	}
+
+	if err := writeFunctionReport(path.Join(s.SessionDir,
+		session.PROFILE_FUNCS), functions); err != nil {
+		return nil, err
+	}
+
+	return &totals, nil
+}
+
+// recordProfileTotals updates the statistics of s with totals, if there are
+// any
+func recordProfileTotals(s *session.Session, totals *profileTotals) {
+	if totals == nil {
+		return
+	}
+
+	s.Stats.ProfileLines = totals.Lines
+	s.Stats.ProfileLinesCovered = totals.LinesCovered
+	s.Stats.ProfileFunctions = totals.Functions
+	s.Stats.ProfileFunctionsCovered = totals.FunctionsCovered
+}
+
+// profileMerger runs the periodic profile merges of a fuzzing run in the
+// background, as llvm-profdata and llvm-cov can take a long time on a large
+// interpreter. Only one merge runs at a time.
+type profileMerger struct {
+	s       *session.Session
+	running bool
+	done    chan profileMerge
+}
+
+// profileMerge is the result of a background merge
+type profileMerge struct {
+	totals *profileTotals
+	err    error
+}
+
+func newProfileMerger(s *session.Session) *profileMerger {
+	return &profileMerger{s: s, done: make(chan profileMerge, 1)}
+}
+
+// start begins a merge, unless one is already running
+func (m *profileMerger) start() {
+	if m.running {
+		log.Printf("Skipping a profile merge, as the last is still running")
+		return
+	}
+
+	m.running = true
+	go func() {
+		totals, err := mergeProfiles(m.s)
+		m.done <- profileMerge{totals, err}
+	}()
+}
+
+// finish records the result of a merge received from done
+func (m *profileMerger) finish(result profileMerge) {
+	m.running = false
+	if result.err != nil {
+		log.Printf("Failed to update the profile report. Error: %s",
+			result.err)
+		return
+	}
+
+	recordProfileTotals(m.s, result.totals)
+}
+
+// wait waits for a running merge, if there is one, to finish
+func (m *profileMerger) wait() {
+	if m.running {
+		m.finish(<-m.done)
+	}
+}
+
+func writeFileReport(reportPath string, files []coverage.FileSummary) error {
+	fd, err := os.Create(reportPath)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	w := bufio.NewWriter(fd)
+	defer w.Flush()
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Filename < files[j].Filename
+	})
+
+	for _, f := range files {
+		fmt.Fprintf(w, "%s\n", f.Filename)
+		fmt.Fprintf(w, "\tLines: %d/%d (%s)\n", f.LinesCovered, f.Lines,
+			session.Percent(f.LinesCovered, f.Lines))
+		fmt.Fprintf(w, "\tFunctions: %d/%d (%s)\n", f.FunctionsCovered,
+			f.Functions, session.Percent(f.FunctionsCovered, f.Functions))
+		fmt.Fprintf(w, "\tRegions: %d/%d (%s)\n", f.RegionsCovered,
+			f.Regions, session.Percent(f.RegionsCovered, f.Regions))
+	}
+
+	return nil
+}
+
+// writeFunctionReport lists every function along with its execution count.
+// The least executed functions are listed first, as they are the ones that
+// new seeds should target.
+func writeFunctionReport(reportPath string,
+	functions []coverage.FunctionSummary) error {
+
+	fd, err := os.Create(reportPath)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	w := bufio.NewWriter(fd)
+	defer w.Flush()
+
+	sort.Slice(functions, func(i, j int) bool {
+		if functions[i].Count != functions[j].Count {
+			return functions[i].Count < functions[j].Count
+		}
+		return functions[i].Name < functions[j].Name
+	})
+
+	for _, f := range functions {
+		fmt.Fprintf(w, "%d %s\n", f.Count, f.Name)
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package manage
+
+import (
+	"github.com/SeanHeelan/Malamute/config"
+	"github.com/SeanHeelan/Malamute/coverage"
+	"github.com/SeanHeelan/Malamute/session"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFunctionReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "profile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	reportPath := filepath.Join(dir, "functions.txt")
+
+	functions := []coverage.FunctionSummary{
+		{"zend_eval", 100},
+		{"php_unused", 0},
+		{"array_map", 3},
+		{"array_diff", 3},
+	}
+	if err := writeFunctionReport(reportPath, functions); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ioutil.ReadFile(reportPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "0 php_unused\n3 array_diff\n3 array_map\n100 zend_eval\n"
+	if string(got) != want {
+		t.Errorf("Function report is\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteFileReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "profile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	reportPath := filepath.Join(dir, "files.txt")
+
+	files := []coverage.FileSummary{
+		{Filename: "b.c", Lines: 4, LinesCovered: 1, Functions: 2,
+			FunctionsCovered: 2},
+		{Filename: "a.c", Lines: 10, LinesCovered: 5, Functions: 1,
+			Regions: 8, RegionsCovered: 2},
+	}
+	if err := writeFileReport(reportPath, files); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ioutil.ReadFile(reportPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "a.c\n" +
+		"\tLines: 5/10 (50.00%)\n" +
+		"\tFunctions: 0/1 (0.00%)\n" +
+		"\tRegions: 2/8 (25.00%)\n" +
+		"b.c\n" +
+		"\tLines: 1/4 (25.00%)\n" +
+		"\tFunctions: 2/2 (100.00%)\n" +
+		"\tRegions: 0/0 (-)\n"
+	if string(got) != want {
+		t.Errorf("File report is\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestProfileMerger(t *testing.T) {
+	dir, err := ioutil.TempDir("", "profile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name       string
+		profileDir string
+		totals     *profileTotals
+		wantLines  int
+	}{
+		// Nothing to merge leaves the statistics alone
+		{"no raw profiles", dir, nil, 7},
+		// A failed merge is logged, and does not stop the next merge
+		{"missing profile directory", filepath.Join(dir, "missing"), nil,
+			7},
+		{"recorded totals", dir, &profileTotals{Lines: 20}, 20},
+	}
+
+	for _, test := range tests {
+		s := &session.Session{
+			SessionDir: dir,
+			ProfileDir: test.profileDir,
+			Config:     &config.Config{},
+		}
+		s.Stats.ProfileLines = 7
+		merger := newProfileMerger(s)
+
+		if test.totals != nil {
+			merger.running = true
+			merger.finish(profileMerge{totals: test.totals})
+		} else {
+			// The second merge is skipped, as the first is still running
+			merger.start()
+			merger.start()
+			merger.wait()
+		}
+
+		if merger.running {
+			t.Errorf("%s: the merger is still running", test.name)
+		}
+		if len(merger.done) != 0 {
+			t.Errorf("%s: more than one merge ran", test.name)
+		}
+		if s.Stats.ProfileLines != test.wantLines {
+			t.Errorf("%s: ProfileLines = %d, want %d", test.name,
+				s.Stats.ProfileLines, test.wantLines)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package manage
+
+import (
+	"fmt"
+	"github.com/SeanHeelan/Malamute/config"
+	"github.com/SeanHeelan/Malamute/data"
+	"github.com/SeanHeelan/Malamute/fs"
+	"github.com/SeanHeelan/Malamute/monitor"
+	"github.com/SeanHeelan/Malamute/resultproc"
+	"github.com/SeanHeelan/Malamute/session"
+	"github.com/kballard/go-shellquote"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	RECHECK_PREFIX = "recheck"
+)
+
+// BucketChange describes a crash bucket whose status was changed by a
+// recheck
+type BucketChange struct {
+	Bucket string
+	// Previous is the status of the bucket before the recheck, or empty if
+	// it had not been checked before
+	Previous string
+	Check    session.BucketCheck
+}
+
+// recheckTarget creates a Target that runs interpreter in the way recorded
+// in bugDesc
+func recheckTarget(interpreter string,
+	bugDesc resultproc.BugDescriptor) *monitor.Target {
+
+	return &monitor.Target{
+		Path:      interpreter,
+		Args:      shellquote.Join(bugDesc.ApplicationArgs...),
+		InputMode: bugDesc.InputMode,
+		EnvMods:   bugDesc.ApplicationEnv,
+	}
+}
+
+// buildConfig returns a copy of the config of s in which interpreter
+// replaces the interpreter the session was run with. binary, if not empty,
+// is the unstripped build of interpreter used for symbolization and
+// backtraces.
+func buildConfig(s *session.Session, interpreter string,
+	binary string) *config.Config {
+
+	cfg := *s.Config
+	if cfg.Symbolize.Module == filepath.Base(cfg.Interpreter.Path) {
+		cfg.Symbolize.Module = filepath.Base(interpreter)
+	}
+	cfg.Interpreter.Path = interpreter
+
+	cfg.Symbolize.Binary = interpreter
+	if len(binary) != 0 {
+		cfg.Symbolize.Binary = binary
+	}
+
+	return &cfg
+}
+
+// recheckCrash runs the trigger of the crash described by bugDesc, in
+// crashDir, against interpreter and returns the status of the crash, one of
+// the session.BUCKET_STATUS_* constants, along with the bucket it now
+// crashes in
+func recheckCrash(s *session.Session, analysis resultproc.ResultProcessor,
+	interpreter string, crashDir string, bugDesc resultproc.BugDescriptor,
+	idx int) (string, string, error) {
+
+	copyName := fmt.Sprintf("%s_%d_%s", RECHECK_PREFIX, idx,
+		bugDesc.TriggerFileName)
+	copyPath := filepath.Join(s.TestCasesDir, copyName)
+	if err := fs.CopyFileContents(filepath.Join(crashDir,
+		bugDesc.TriggerFileName), copyPath); err != nil {
+		return "", "", err
+	}
+	defer os.Remove(copyPath)
+
+	opts := monitor.Options(s.Config, "")
+	opts.Coverage = false
+	opts.Output.Spill = false
+	if bugDesc.Timeout != 0 {
+		opts.Timeout = bugDesc.Timeout
+	}
+
+	tc := data.NewTestCase()
+	tc.FuzzFilePath = copyPath
+	tc.SeedFilePaths = bugDesc.OriginalSeedPaths
+	target := recheckTarget(interpreter, bugDesc)
+	if err := target.Execute(&tc, opts); err != nil {
+		return "", "", err
+	}
+	defer resultproc.RemoveRunFiles(tc)
+
+	if tc.TestTimedOut {
+		return session.BUCKET_STATUS_CHANGED, "timeout", nil
+	}
+
+	if _, err := analysis.Process(&tc); err != nil {
+		log.Printf("Error analysing %s : %s", crashDir, err)
+	}
+
+	if tc.BugClass != resultproc.BUGCLASS_CRASH {
+		return session.BUCKET_STATUS_FIXED, "", nil
+	}
+
+	if tc.Bucket == bugDesc.Bucket {
+		return session.BUCKET_STATUS_CRASHING, tc.Bucket, nil
+	}
+
+	return session.BUCKET_STATUS_CHANGED, tc.Bucket, nil
+}
+
+// Recheck re-runs the trigger of every crash preserved by s against
+// interpreter, using the arguments and environment recorded for it, and
+// updates the status of each crash bucket. A bucket is still crashing if
+// any of its crashes still crash in it, has changed signature if they all
+// crash elsewhere, and is fixed otherwise. The history of every bucket is
+// written to the session directory, and the buckets whose status changed
+// are returned. binary is described on buildConfig.
+func Recheck(s *session.Session, interpreter string,
+	binary string) ([]BucketChange, error) {
+
+	// The analysis is given a session that runs the new build, so that
+	// crashes are analysed in the same way they were while fuzzing
+	recheckSess := *s
+	recheckSess.Config = buildConfig(s, interpreter, binary)
+	analysis, err := resultproc.NewAnalysis(&recheckSess)
+	if err != nil {
+		return nil, err
+	}
+
+	if recheckSess.Config.Cores.Enabled {
+		if err := monitor.EnableCoreDumps(); err != nil {
+			return nil, err
+		}
+	}
+
+	descPaths, err := filepath.Glob(filepath.Join(s.PreservationDir, "*",
+		resultproc.BUG_DESC_NAME))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	checks := make(map[string]*session.BucketCheck)
+	for idx, descPath := range descPaths {
+		crashDir := filepath.Dir(descPath)
+		bugDesc, err := resultproc.ReadBugDescriptor(crashDir)
+		if err != nil {
+			return nil, err
+		}
+
+		if bugDesc.BugClass != resultproc.BUGCLASS_CRASH ||
+			len(bugDesc.Bucket) == 0 {
+			continue
+		}
+
+		// A persistent interpreter needs the test framed by delimiters
+		if bugDesc.InputMode == config.MONITOR_PERSISTENT {
+			log.Printf("Skipping %s, as crashes found by the persistent "+
+				"monitor cannot be rechecked", crashDir)
+			continue
+		}
+
+		status, newBucket, err := recheckCrash(&recheckSess, analysis,
+			interpreter, crashDir, bugDesc, idx)
+		if err != nil {
+			log.Printf("Could not recheck %s : %s", crashDir, err)
+			continue
+		}
+		log.Printf("%s : %s", crashDir, status)
+
+		check, ok := checks[bugDesc.Bucket]
+		if !ok {
+			check = &session.BucketCheck{Time: now, Interpreter: interpreter}
+			checks[bugDesc.Bucket] = check
+		}
+
+		switch status {
+		case session.BUCKET_STATUS_CRASHING:
+			check.Crashing++
+		case session.BUCKET_STATUS_FIXED:
+			check.Fixed++
+		case session.BUCKET_STATUS_CHANGED:
+			check.Changed++
+			check.NewBuckets = append(check.NewBuckets, newBucket)
+		}
+	}
+
+	buckets := []string{}
+	for bucket := range checks {
+		buckets = append(buckets, bucket)
+	}
+	sort.Strings(buckets)
+
+	changes := []BucketChange{}
+	for _, bucket := range buckets {
+		check := checks[bucket]
+		if check.Crashing != 0 {
+			check.Status = session.BUCKET_STATUS_CRASHING
+		} else if check.Changed != 0 {
+			check.Status = session.BUCKET_STATUS_CHANGED
+		} else {
+			check.Status = session.BUCKET_STATUS_FIXED
+		}
+
+		previous := s.AddBucketCheck(bucket, *check)
+		if previous != check.Status {
+			changes = append(changes, BucketChange{bucket, previous, *check})
+		}
+	}
+
+	if err := s.Save(); err != nil {
+		return nil, err
+	}
+
+	if err := s.LogRecheck(); err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
@@ -0,0 +1,66 @@
+package manage
+
+import (
+	"github.com/SeanHeelan/Malamute/config"
+	"github.com/SeanHeelan/Malamute/resultproc"
+	"github.com/SeanHeelan/Malamute/session"
+	"reflect"
+	"testing"
+)
+
+func TestBuildConfig(t *testing.T) {
+	tests := []struct {
+		name       string
+		module     string
+		binary     string
+		wantModule string
+		wantBinary string
+	}{
+		{"stripped build", "php", "/new/php-unstripped", "php-new",
+			"/new/php-unstripped"},
+		{"unstripped build", "php", "", "php-new", "/new/php-new"},
+		{"shared library", "libphp.so", "", "libphp.so", "/new/php-new"},
+	}
+
+	for _, test := range tests {
+		cfg := &config.Config{}
+		cfg.Interpreter.Path = "/old/php"
+		cfg.Symbolize.Module = test.module
+		cfg.Symbolize.Binary = "/old/php-unstripped"
+		s := &session.Session{Config: cfg}
+
+		got := buildConfig(s, "/new/php-new", test.binary)
+		if got.Interpreter.Path != "/new/php-new" ||
+			got.Symbolize.Module != test.wantModule ||
+			got.Symbolize.Binary != test.wantBinary {
+			t.Errorf("%s: interpreter %q, module %q, binary %q, want "+
+				"module %q, binary %q", test.name, got.Interpreter.Path,
+				got.Symbolize.Module, got.Symbolize.Binary, test.wantModule,
+				test.wantBinary)
+		}
+
+		// The session keeps running the build it was fuzzed with
+		if cfg.Interpreter.Path != "/old/php" ||
+			cfg.Symbolize.Module != test.module {
+			t.Errorf("%s: the session config was changed", test.name)
+		}
+	}
+}
+
+func TestRecheckTarget(t *testing.T) {
+	bugDesc := resultproc.BugDescriptor{
+		ApplicationArgs: []string{"-d", "memory_limit=1 G",
+			config.INTERPRETER_ARGS_FUZZ_FILE_MARKER},
+		ApplicationEnv: []string{"USE_ZEND_ALLOC=0"},
+		InputMode:      config.MONITOR_PERSISTENT,
+	}
+
+	target := recheckTarget("/new/php", bugDesc)
+	wantArgs := "-d 'memory_limit=1 G' " +
+		config.INTERPRETER_ARGS_FUZZ_FILE_MARKER
+	if target.Path != "/new/php" || target.Args != wantArgs ||
+		target.InputMode != bugDesc.InputMode ||
+		!reflect.DeepEqual(target.EnvMods, bugDesc.ApplicationEnv) {
+		t.Errorf("recheckTarget = %+v, want args %q", target, wantArgs)
+	}
+}
@@ -0,0 +1,52 @@
+package monitor
+
+import (
+	"fmt"
+	"github.com/SeanHeelan/Malamute/config"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	AFFINITY_MASK_WORDS = config.MAX_CPUS / 64
+)
+
+type cpuMask [AFFINITY_MASK_WORDS]uint64
+
+// AllowedCPUs returns the CPUs that mfuzz is allowed to run on
+func AllowedCPUs() ([]int, error) {
+	var mask cpuMask
+	_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_GETAFFINITY, 0,
+		uintptr(len(mask)*8), uintptr(unsafe.Pointer(&mask[0])))
+	if errno != 0 {
+		return nil, fmt.Errorf("Could not read the CPU affinity: %s", errno)
+	}
+
+	cpus := []int{}
+	for cpu := 0; cpu < config.MAX_CPUS; cpu++ {
+		if mask[cpu/64]&(1<<uint(cpu%64)) != 0 {
+			cpus = append(cpus, cpu)
+		}
+	}
+
+	return cpus, nil
+}
+
+// PinToCPU locks the calling goroutine to its OS thread and restricts that
+// thread to running on cpu. Interpreters subsequently started by the
+// goroutine inherit the restriction, so a monitor that calls this before
+// its work loop runs all of its tests on the same CPU.
+func PinToCPU(cpu int) error {
+	runtime.LockOSThread()
+
+	var mask cpuMask
+	mask[cpu/64] |= 1 << uint(cpu%64)
+	_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_SETAFFINITY, 0,
+		uintptr(len(mask)*8), uintptr(unsafe.Pointer(&mask[0])))
+	if errno != 0 {
+		return fmt.Errorf("Could not pin monitor to CPU %d: %s", cpu, errno)
+	}
+
+	return nil
+}
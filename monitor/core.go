@@ -0,0 +1,71 @@
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+const (
+	CORE_PATTERN_PATH = "/proc/sys/kernel/core_pattern"
+	// The extension given to a core file once it has been moved out of the
+	// directory the interpreter was run in
+	CORE_EXT = ".core"
+)
+
+// EnableCoreDumps raises the core file size limit of mfuzz, which every
+// interpreter inherits, as far as it will go. It returns an error if core
+// files can't be written, or won't be written to the directory of the
+// process that crashed.
+func EnableCoreDumps() error {
+	var limit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_CORE, &limit); err != nil {
+		return err
+	}
+
+	limit.Cur = limit.Max
+	if err := syscall.Setrlimit(syscall.RLIMIT_CORE, &limit); err != nil {
+		return err
+	}
+
+	if limit.Cur == 0 {
+		return errors.New("The hard limit on the core file size is 0. " +
+			"Raise it with ulimit -H -c")
+	}
+
+	pattern, err := ioutil.ReadFile(CORE_PATTERN_PATH)
+	if err != nil {
+		return err
+	}
+
+	patternStr := strings.TrimSpace(string(pattern))
+	if strings.HasPrefix(patternStr, "|") || strings.Contains(patternStr, "/") {
+		msg := fmt.Sprintf("The core pattern in %s is '%s', so cores won't "+
+			"be written to the directory of the interpreter. Set it to "+
+			"'core' to capture them.", CORE_PATTERN_PATH, patternStr)
+		return errors.New(msg)
+	}
+
+	return nil
+}
+
+// collectCore moves a core file left in runDir to dest, and returns dest.
+// If no core was left then the empty string is returned. Should the
+// interpreter have started processes which also dumped core, only one is
+// kept.
+func collectCore(runDir string, dest string) (string, error) {
+	cores, err := filepath.Glob(filepath.Join(runDir, "core*"))
+	if err != nil || len(cores) == 0 {
+		return "", err
+	}
+
+	if err := os.Rename(cores[0], dest); err != nil {
+		return "", err
+	}
+
+	return dest, nil
+}
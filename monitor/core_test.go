@@ -0,0 +1,61 @@
+package monitor
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCollectCore(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    []string
+		wantCore bool
+	}{
+		{"no core", []string{"t.php"}, false},
+		{"core", []string{"t.php", "core"}, true},
+		{"core with pid", []string{"core.1234"}, true},
+		{"several cores", []string{"core.1", "core.2"}, true},
+	}
+
+	for _, test := range tests {
+		dir, err := ioutil.TempDir("", "core")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		runDir := filepath.Join(dir, "run")
+		if err := os.Mkdir(runDir, 0777); err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range test.files {
+			if err := ioutil.WriteFile(filepath.Join(runDir, name), nil,
+				0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		dest := runDir + CORE_EXT
+		got, err := collectCore(runDir, dest)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+
+		if !test.wantCore {
+			if len(got) != 0 {
+				t.Errorf("%s: collected %s", test.name, got)
+			}
+			continue
+		}
+
+		if got != dest {
+			t.Errorf("%s: collected %q, want %q", test.name, got, dest)
+		}
+		if _, err := os.Stat(dest); err != nil {
+			t.Errorf("%s: the core was not moved: %s", test.name, err)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package monitor
+
+import (
+	"github.com/SeanHeelan/Malamute/config"
+	"github.com/SeanHeelan/Malamute/data"
+	"github.com/SeanHeelan/Malamute/logging"
+	"github.com/SeanHeelan/Malamute/normalize"
+	"github.com/SeanHeelan/Malamute/session"
+	"sort"
+)
+
+const (
+	// The name under which the output of the main interpreter is recorded
+	DIFF_MAIN_PROFILE = config.DIFFERENTIAL_MAIN_PROFILE
+)
+
+func equalLines(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Differential is a monitor that executes each test case under the main
+// interpreter, and then under each of the configured differential profiles.
+// A profile whose output, once normalized as described by the Normalize
+// section of the config, or exit code differs from that of the main
+// interpreter is recorded as divergent, and one that times out is recorded
+// as such. Coverage and profiles are only collected from the main
+// interpreter.
+type Differential struct {
+	S *session.Session
+	L *logging.Logs
+}
+
+// Run starts a work loop that consumes test cases, executes each under every
+// interpreter profile and passes them on with the results filled in. On
+// error a message will be sent on the errOut channel.
+func (m *Differential) Run(in chan data.TestCase, out chan data.TestCase,
+	errOut chan error) {
+
+	cfg := m.S.Config
+	mainTarget, err := InterpreterTarget(cfg)
+	if err != nil {
+		errOut <- err
+		return
+	}
+	normalizer, err := normalize.New(cfg.Normalize.Preset,
+		cfg.Normalize.Replace, cfg.Normalize.DropLine,
+		cfg.Normalize.FloatPrecision)
+	if err != nil {
+		errOut <- err
+		return
+	}
+	checker, err := newExpectationChecker(cfg)
+	if err != nil {
+		errOut <- err
+		return
+	}
+	opts := Options(cfg, m.S.ProfileDir)
+	// Only the output of the main interpreter is spilled, as the profiles
+	// share its fuzz file
+	profileOpts := RunOptions{Timeout: opts.Timeout, Output: opts.Output}
+	profileOpts.Output.Spill = false
+
+	names := []string{}
+	targets := make(map[string]*Target)
+	for name, profile := range cfg.Differential {
+		target, err := NewTarget(profile.Path, profile.Args, profile.ArgGen,
+			cfg.Interpreter.TestCaseRootDir, profile.InputMode)
+		if err != nil {
+			errOut <- err
+			return
+		}
+
+		names = append(names, name)
+		targets[name] = target
+	}
+	sort.Strings(names)
+
+TestLoop:
+	for {
+		testCase := <-in
+		if len(testCase.SeedFilePaths) == 0 {
+			out <- testCase
+			break
+		}
+
+		runOpts := opts
+		runOpts.Timeout = m.S.SeedTimeout(testCase.SeedFilePaths,
+			opts.Timeout)
+		profileOpts.Timeout = runOpts.Timeout
+		if err := mainTarget.Execute(&testCase, runOpts); err != nil {
+			errOut <- err
+			continue
+		}
+
+		if len(testCase.Unrunnable) != 0 {
+			out <- testCase
+			continue
+		}
+
+		checkResources(m.S, &testCase)
+		if testCase.TestTimedOut && cfg.Hangs.Preserve {
+			if err := confirmHang(mainTarget, &testCase, runOpts.Timeout,
+				cfg.Hangs.ConfirmMultiplier, opts.Output); err != nil {
+				errOut <- err
+				continue
+			}
+		}
+
+		if checker != nil {
+			checker.check(&testCase)
+		}
+
+		if testCase.TestTimedOut {
+			// There is nothing to compare against
+			out <- testCase
+			continue
+		}
+
+		testCase.DifferentialStdout = map[string][]string{
+			DIFF_MAIN_PROFILE: testCase.RunStdout}
+		testCase.DifferentialStderr = map[string][]string{
+			DIFF_MAIN_PROFILE: testCase.RunStderr}
+		testCase.DifferentialExitCodes = map[string]int{
+			DIFF_MAIN_PROFILE: testCase.ExitCode}
+		mainStdout := normalizer.Lines(testCase.RunStdout)
+
+		for _, name := range names {
+			variant := data.NewTestCase()
+			variant.FuzzFilePath = testCase.FuzzFilePath
+			variant.SeedFilePaths = testCase.SeedFilePaths
+
+			if err := targets[name].Execute(&variant, profileOpts); err != nil {
+				errOut <- err
+				continue TestLoop
+			}
+
+			testCase.LeakedProcesses += variant.LeakedProcesses
+			testCase.DifferentialStderr[name] = variant.RunStderr
+			if variant.TestTimedOut {
+				m.L.DEBUGF("Profile %s timed out on %s", name,
+					testCase.FuzzFilePath)
+				testCase.TimedOutProfiles = append(
+					testCase.TimedOutProfiles, name)
+				continue
+			}
+
+			testCase.DifferentialStdout[name] = variant.RunStdout
+			testCase.DifferentialExitCodes[name] = variant.ExitCode
+
+			if variant.ExitCode != testCase.ExitCode ||
+				!equalLines(normalizer.Lines(variant.RunStdout), mainStdout) {
+				testCase.DivergentProfiles = append(
+					testCase.DivergentProfiles, name)
+			}
+		}
+
+		out <- testCase
+	}
+}
@@ -0,0 +1,435 @@
+package monitor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/SeanHeelan/Malamute/arggen"
+	"github.com/SeanHeelan/Malamute/config"
+	"github.com/SeanHeelan/Malamute/coverage"
+	"github.com/SeanHeelan/Malamute/data"
+	"github.com/kballard/go-shellquote"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// The longest single argument the kernel accepts, including its
+	// terminating NUL byte
+	MAX_ARG_STRLEN = 32 * 4096
+)
+
+// Target describes how an interpreter is run on a single test case
+type Target struct {
+	// Path is the path to the interpreter
+	Path string
+	// Args is the argument string, containing the markers described on
+	// config.Interpreter.Args. It is ignored if ArgGen is not nil.
+	Args string
+	// ArgGen, if not nil, is used to generate the arguments for each test
+	ArgGen arggen.GenFunc
+	// TestCaseRootDir is passed to ArgGen
+	TestCaseRootDir string
+	// InputMode is one of the config.INPUT_MODE_* constants
+	InputMode string
+	// EnvMods are the variables added to the environment of the
+	// interpreter
+	EnvMods []string
+}
+
+// RunOptions control how a Target is executed
+type RunOptions struct {
+	// Timeout is the maximum time the interpreter may run for
+	Timeout time.Duration
+	// Coverage indicates that SanitizerCoverage output should be collected
+	Coverage bool
+	// ProfileDir, if not empty, is the directory into which an interpreter
+	// built with -fprofile-instr-generate should write its profile
+	ProfileDir string
+	// Cores indicates that a core file left by the interpreter should be
+	// kept, and its path recorded in the test case
+	Cores bool
+	// SampleCPU indicates that the CPU usage of the interpreter should be
+	// sampled over the second half of the run, should it time out
+	SampleCPU bool
+	// Output controls how much of the output of the interpreter is kept
+	Output OutputLimits
+	// SpillPath, if not empty, replaces the path of the fuzz file as the
+	// base of the paths output is spilled to
+	SpillPath string
+}
+
+// NewTarget creates a Target for the interpreter at path. Either args or
+// argGenName should be provided, as described on config.Interpreter.
+func NewTarget(path string, args string, argGenName string,
+	testCaseRootDir string, inputMode string) (*Target, error) {
+
+	t := Target{
+		Path:            path,
+		Args:            args,
+		TestCaseRootDir: testCaseRootDir,
+		InputMode:       inputMode,
+		EnvMods:         environment(""),
+	}
+
+	if len(argGenName) != 0 {
+		argGen, err := arggen.GetGenerator(argGenName)
+		if err != nil {
+			msg := fmt.Sprintf("Failed to get argument generator: %s", err)
+			return nil, errors.New(msg)
+		}
+		t.ArgGen = argGen
+	}
+
+	return &t, nil
+}
+
+// InterpreterTarget creates a Target from the Interpreter section of cfg
+func InterpreterTarget(cfg *config.Config) (*Target, error) {
+	return NewTarget(cfg.Interpreter.Path, cfg.Interpreter.Args,
+		cfg.Interpreter.ArgGen, cfg.Interpreter.TestCaseRootDir,
+		cfg.Interpreter.InputMode)
+}
+
+// Options returns the RunOptions described by cfg, for tests run under the
+// session with the profile directory profileDir
+func Options(cfg *config.Config, profileDir string) RunOptions {
+	opts := RunOptions{
+		Timeout:  cfg.Interpreter.Timeout.Duration,
+		Coverage: cfg.Coverage.Enabled,
+		Cores:    cfg.Cores.Enabled,
+		Output:   outputLimits(cfg),
+	}
+
+	if cfg.Profile.Enabled {
+		opts.ProfileDir = profileDir
+	}
+
+	return opts
+}
+
+// arguments returns the arguments with which the interpreter should be run
+// on fuzzFile, and a copy of them in which the fuzz file path and contents
+// have been replaced by their markers, suitable for recording
+func (t *Target) arguments(fuzzFile string, fileData []byte) ([]string,
+	[]string, error) {
+
+	var argsStr string
+	if t.ArgGen == nil {
+		argsStr = strings.Replace(t.Args,
+			config.INTERPRETER_ARGS_FUZZ_FILE_MARKER, fuzzFile, -1)
+		argsStr = strings.Replace(argsStr,
+			config.INTERPRETER_ARGS_FUZZ_FILE_DIR_MARKER,
+			filepath.Dir(fuzzFile), -1)
+	} else {
+		var err error
+		argsStr, err = t.ArgGen(t.TestCaseRootDir, fuzzFile)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	argsStrParts, err := shellquote.Split(argsStr)
+	if err != nil {
+		msg := fmt.Sprintf("Failed to parse target arguments : %s",
+			argsStr)
+		return nil, nil, errors.New(msg)
+	}
+
+	// The arguments are recorded with the markers put back in place of
+	// the fuzz file, so that they can be reused with the trigger once it
+	// has been preserved
+	recordedArgs := []string{}
+	for i, part := range argsStrParts {
+		recordedArgs = append(recordedArgs, strings.Replace(part,
+			fuzzFile, config.INTERPRETER_ARGS_FUZZ_FILE_MARKER, -1))
+
+		if t.InputMode == config.INPUT_MODE_ARG {
+			argsStrParts[i] = strings.Replace(part,
+				config.INTERPRETER_ARGS_FUZZ_DATA_MARKER,
+				string(fileData), -1)
+		}
+	}
+
+	return argsStrParts, recordedArgs, nil
+}
+
+// unrunnable returns the reason that args cannot be passed to exec, or an
+// empty string if they can
+func unrunnable(args []string) string {
+	for _, arg := range args {
+		if strings.IndexByte(arg, 0) != -1 {
+			return "an argument holds a NUL byte"
+		}
+
+		if len(arg) >= MAX_ARG_STRLEN {
+			return fmt.Sprintf("an argument of %d bytes exceeds the limit "+
+				"of %d", len(arg), MAX_ARG_STRLEN-1)
+		}
+	}
+
+	return ""
+}
+
+// setOutput records the captured output of a run in testCase
+func setOutput(testCase *data.TestCase, stdout capturedOutput,
+	stderr capturedOutput) {
+
+	testCase.RunStdout = stdout.Lines
+	testCase.StdoutTruncated = stdout.Truncated
+	testCase.StdoutSpillPath = stdout.SpillPath
+	testCase.RunStderr = stderr.Lines
+	testCase.StderrTruncated = stderr.Truncated
+	testCase.StderrSpillPath = stderr.SpillPath
+}
+
+// Execute runs the interpreter on the fuzz file of testCase, and fills in
+// the fields of testCase that are the responsibility of the execution
+// monitor. An error is returned if the interpreter could not be run at all,
+// while a test that cannot be passed to it has Unrunnable set instead.
+func (t *Target) Execute(testCase *data.TestCase, opts RunOptions) error {
+	testCase.ApplicationEnv = append(testCase.ApplicationEnv, t.EnvMods...)
+	testCase.ApplicationPath = t.Path
+	testCase.InputMode = t.InputMode
+	testCase.Timeout = opts.Timeout
+
+	fuzzFile := testCase.FuzzFilePath
+	base := filepath.Base(fuzzFile)
+	dir := filepath.Dir(fuzzFile)
+	now := time.Now().Unix()
+
+	fileData, err := ioutil.ReadFile(fuzzFile)
+	if err != nil {
+		msg := fmt.Sprintf("Could not read the fuzz file %s. Error %s",
+			fuzzFile, err)
+		return errors.New(msg)
+	}
+
+	args, recordedArgs, err := t.arguments(fuzzFile, fileData)
+	if err != nil {
+		return err
+	}
+	testCase.ApplicationArgs = recordedArgs
+
+	// A fuzz file passed as an argument may hold anything, but exec
+	// does not accept every argument
+	if reason := unrunnable(args); len(reason) != 0 {
+		testCase.Unrunnable = reason
+		return nil
+	}
+
+	backupDirName := fmt.Sprintf("%d_%s", now, base)
+	backupDirPath := filepath.Join(dir, backupDirName)
+	if err := os.Mkdir(backupDirPath, 0777); err != nil {
+		msg := fmt.Sprintf("Could not create backup directory %s",
+			backupDirPath)
+		return errors.New(msg)
+	}
+
+	// Create a backup in case the file gets modified during the run
+	backupPath := filepath.Join(backupDirPath, base)
+	err = ioutil.WriteFile(backupPath, fileData, 0777)
+	if err != nil {
+		msg := fmt.Sprintf("Could not write %s to %s. Error %s",
+			fuzzFile, backupPath, err)
+		return errors.New(msg)
+	}
+
+	cmd := exec.Command(t.Path, args...)
+	cmd.Env = append(os.Environ(), t.EnvMods...)
+	if opts.Coverage {
+		// Each run writes its coverage to its own directory
+		cmd.Env = append(cmd.Env, environment(backupDirPath)...)
+	}
+	if len(opts.ProfileDir) != 0 {
+		cmd.Env = append(cmd.Env, profileEnv(opts.ProfileDir, base))
+	}
+	cmd.Dir = backupDirPath
+	cmd.SysProcAttr = newProcAttr()
+	if t.InputMode == config.INPUT_MODE_STDIN {
+		cmd.Stdin = bytes.NewReader(fileData)
+	}
+
+	// The pipes are created here, rather than with StdoutPipe, as Wait
+	// closes those as soon as the interpreter exits and anything still
+	// buffered in them is lost. These are only closed by collectOutput.
+	stdout, stdoutWriter, err := os.Pipe()
+	if err != nil {
+		msg := fmt.Sprintf("Error %s creating the stdout pipe", err)
+		return errors.New(msg)
+	}
+	stderr, stderrWriter, err := os.Pipe()
+	if err != nil {
+		stdout.Close()
+		stdoutWriter.Close()
+		msg := fmt.Sprintf("Error %s creating the stderr pipe", err)
+		return errors.New(msg)
+	}
+	cmd.Stdout = stdoutWriter
+	cmd.Stderr = stderrWriter
+
+	spillPath := fuzzFile
+	if len(opts.SpillPath) != 0 {
+		spillPath = opts.SpillPath
+	}
+
+	// The channels are buffered so that the capture goroutines can exit
+	// even if the output is never collected
+	stdoutChan := make(chan capturedOutput, 1)
+	go captureToChannel(stdout, opts.Output, spillPath+STDOUT_SPILL_EXT,
+		stdoutChan)
+	stderrChan := make(chan capturedOutput, 1)
+	go captureToChannel(stderr, opts.Output, spillPath+STDERR_SPILL_EXT,
+		stderrChan)
+
+	startTime := time.Now()
+	err = cmd.Start()
+	if err == nil {
+		trackProcessGroup(cmd.Process.Pid)
+	}
+	// Only the interpreter writes to the pipes, so the capture goroutines
+	// see the end of them once it, and anything it started, has exited
+	stdoutWriter.Close()
+	stderrWriter.Close()
+	if err != nil {
+		collectOutput(stdoutChan, stderrChan, stdout, stderr)
+		msg := fmt.Sprintf("Error %s running %s on %s", err, t.Path,
+			fuzzFile)
+		return errors.New(msg)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	var midpoint <-chan time.Time
+	var midCPU time.Duration
+	var midTime time.Time
+	if opts.SampleCPU {
+		midpoint = time.After(opts.Timeout / 2)
+	}
+	timeout := time.After(opts.Timeout)
+
+	var waitErr error
+WaitLoop:
+	for {
+		select {
+		case <-midpoint:
+			midCPU = groupCPUTime(cmd.Process.Pid)
+			midTime = time.Now()
+			midpoint = nil
+		case <-timeout:
+			if opts.SampleCPU && !midTime.IsZero() {
+				cpu := groupCPUTime(cmd.Process.Pid) - midCPU
+				wall := time.Now().Sub(midTime)
+				testCase.CPUPercent = int(100 * cpu / wall)
+			}
+
+			// Process is taking too long, kill it along with anything else
+			// it has started
+			testCase.LeakedProcesses = countHelpers(cmd.Process.Pid)
+			if err := killProcessGroup(cmd.Process.Pid); err != nil {
+				log.Printf("Could not kill test process: %s", err)
+			} else {
+				log.Println("Process took too long to finish and was killed")
+			}
+
+			<-done
+			stdoutData, stderrData := collectOutput(stdoutChan,
+				stderrChan, stdout, stderr)
+			setOutput(testCase, stdoutData, stderrData)
+			testCase.TestTimedOut = true
+
+			if err := os.RemoveAll(backupDirPath); err != nil {
+				log.Printf("Could not remove working directory %s : %s",
+					backupDirPath, err)
+			}
+			return nil
+		case waitErr = <-done:
+			break WaitLoop
+		}
+	}
+
+	// The interpreter has exited, but any helpers it started may still
+	// be running. They are killed here so they don't accumulate.
+	testCase.LeakedProcesses = reapProcessGroup(cmd.Process.Pid)
+	if testCase.LeakedProcesses != 0 {
+		log.Printf("Killed %d processes left behind by the test of %s",
+			testCase.LeakedProcesses, fuzzFile)
+	}
+
+	testCase.TestTimedOut = false
+	testCase.WallTime = time.Now().Sub(startTime)
+	if usage, ok := cmd.ProcessState.SysUsage().(*syscall.Rusage); ok {
+		testCase.UserTime = time.Duration(usage.Utime.Nano())
+		testCase.SysTime = time.Duration(usage.Stime.Nano())
+		testCase.MaxRSS = usage.Maxrss
+	}
+	stdoutData, stderrData := collectOutput(stdoutChan, stderrChan, stdout,
+		stderr)
+	setOutput(testCase, stdoutData, stderrData)
+
+	if opts.Coverage {
+		testCase.Coverage, err = coverage.ReadSancovDir(backupDirPath)
+		if err != nil {
+			log.Printf("Could not read the coverage of %s : %s",
+				fuzzFile, err)
+		}
+	}
+
+	if opts.Cores {
+		testCase.CorePath, err = collectCore(backupDirPath,
+			backupDirPath+CORE_EXT)
+		if err != nil {
+			log.Printf("Could not collect the core file of %s : %s",
+				fuzzFile, err)
+		}
+	}
+
+	// In case the fuzz file was modified during the execution of the
+	// test we write its original data back out. Should anything go wrong
+	// before we get to do this, the backup still remains.
+	err = ioutil.WriteFile(fuzzFile, fileData, 0777)
+	if err != nil {
+		msg := fmt.Sprintf("Could not write %s. Error %s", fuzzFile, err)
+		return errors.New(msg)
+	}
+
+	if err := os.RemoveAll(backupDirPath); err != nil {
+		log.Printf("Could not remove working directory %s : %s",
+			backupDirPath, err)
+	}
+
+	if waitErr == nil {
+		// Program returned exit code 0
+		testCase.ExitCode = 0
+		return nil
+	}
+
+	// Program returned exit code != 0
+	exitErr, ok := waitErr.(*exec.ExitError)
+	if !ok {
+		// Failed to cast error code, we should never end up in here
+		msg := fmt.Sprintf("Error %s executing %s on %s", waitErr, t.Path,
+			fuzzFile)
+		return errors.New(msg)
+	}
+
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	if !ok {
+		// Failed to cast error code, we should never end up in here
+		msg := fmt.Sprintf("Could not translate error code resulting "+
+			"from executing file %s", fuzzFile)
+		return errors.New(msg)
+	}
+
+	testCase.ExitCode = statusExitCode(status)
+	return nil
+}
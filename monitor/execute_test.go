@@ -0,0 +1,104 @@
+package monitor
+
+import (
+	"github.com/SeanHeelan/Malamute/config"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUnrunnable(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want bool
+	}{
+		{"no arguments", []string{}, false},
+		{"plain arguments", []string{"-r", "echo 1;"}, false},
+		{"NUL byte", []string{"-r", "echo\x001;"}, true},
+		{"longest argument", []string{strings.Repeat("a",
+			MAX_ARG_STRLEN-1)}, false},
+		{"argument too long", []string{strings.Repeat("a",
+			MAX_ARG_STRLEN)}, true},
+	}
+
+	for _, test := range tests {
+		reason := unrunnable(test.args)
+		if got := len(reason) != 0; got != test.want {
+			t.Errorf("%s: unrunnable = %q, want unrunnable %v", test.name,
+				reason, test.want)
+		}
+	}
+}
+
+func TestArguments(t *testing.T) {
+	const fuzzFile = "/tmp/tests/t.php"
+
+	tests := []struct {
+		name         string
+		args         string
+		inputMode    string
+		data         string
+		wantArgs     []string
+		wantRecorded []string
+	}{
+		{
+			name:         "file",
+			args:         "-n " + config.INTERPRETER_ARGS_FUZZ_FILE_MARKER,
+			inputMode:    config.INPUT_MODE_FILE,
+			wantArgs:     []string{"-n", fuzzFile},
+			wantRecorded: []string{"-n", config.INTERPRETER_ARGS_FUZZ_FILE_MARKER},
+		},
+		{
+			name: "directory",
+			args: "-d " + config.INTERPRETER_ARGS_FUZZ_FILE_DIR_MARKER + " " +
+				config.INTERPRETER_ARGS_FUZZ_FILE_MARKER,
+			inputMode: config.INPUT_MODE_FILE,
+			wantArgs:  []string{"-d", "/tmp/tests", fuzzFile},
+			wantRecorded: []string{"-d", "/tmp/tests",
+				config.INTERPRETER_ARGS_FUZZ_FILE_MARKER},
+		},
+		{
+			name:      "data",
+			args:      "-r " + config.INTERPRETER_ARGS_FUZZ_DATA_MARKER,
+			inputMode: config.INPUT_MODE_ARG,
+			data:      "echo 'a b';",
+			wantArgs:  []string{"-r", "echo 'a b';"},
+			wantRecorded: []string{"-r",
+				config.INTERPRETER_ARGS_FUZZ_DATA_MARKER},
+		},
+		{
+			name:         "data marker outside arg mode",
+			args:         "-r " + config.INTERPRETER_ARGS_FUZZ_DATA_MARKER,
+			inputMode:    config.INPUT_MODE_STDIN,
+			data:         "echo 1;",
+			wantArgs:     []string{"-r", config.INTERPRETER_ARGS_FUZZ_DATA_MARKER},
+			wantRecorded: []string{"-r", config.INTERPRETER_ARGS_FUZZ_DATA_MARKER},
+		},
+	}
+
+	for _, test := range tests {
+		target := Target{Args: test.args, InputMode: test.inputMode}
+		args, recorded, err := target.arguments(fuzzFile, []byte(test.data))
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(args, test.wantArgs) {
+			t.Errorf("%s: args = %q, want %q", test.name, args,
+				test.wantArgs)
+		}
+		if !reflect.DeepEqual(recorded, test.wantRecorded) {
+			t.Errorf("%s: recorded args = %q, want %q", test.name,
+				recorded, test.wantRecorded)
+		}
+	}
+}
+
+func TestArgumentsUnbalancedQuote(t *testing.T) {
+	target := Target{Args: "-r 'echo", InputMode: config.INPUT_MODE_FILE}
+	if _, _, err := target.arguments("/tmp/t.php", nil); err == nil {
+		t.Errorf("Arguments with an unbalanced quote were accepted")
+	}
+}
@@ -0,0 +1,104 @@
+package monitor
+
+import (
+	"github.com/SeanHeelan/Malamute/data"
+	"log"
+	"os"
+	"time"
+)
+
+const (
+	// The interpreter kept a CPU busy, e.g. an infinite loop
+	HANG_BUSY = "busy"
+	// The interpreter was mostly waiting, e.g. a deadlock
+	HANG_BLOCKED = "blocked"
+	// A hang using at least this percentage of a CPU is considered busy
+	HANG_BUSY_PERCENT = 50
+
+	// Appended to the path of the fuzz file to give the base of the paths
+	// the output of the confirmation run is spilled to, so that it doesn't
+	// overwrite the output of the run that timed out
+	HANG_CONFIRM_EXT = ".confirm"
+)
+
+// removeSpills removes the files holding the full output of testCase
+func removeSpills(testCase data.TestCase) {
+	for _, path := range []string{testCase.StdoutSpillPath,
+		testCase.StderrSpillPath} {
+		if len(path) == 0 {
+			continue
+		}
+
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Printf("Failed to remove %s : %s", path, err)
+		}
+	}
+}
+
+// adoptRun replaces the results of testCase with those of run, a re-run of
+// it
+func adoptRun(testCase *data.TestCase, run data.TestCase) {
+	removeSpills(*testCase)
+
+	testCase.TestTimedOut = run.TestTimedOut
+	testCase.ExitCode = run.ExitCode
+	testCase.WallTime = run.WallTime
+	testCase.UserTime = run.UserTime
+	testCase.SysTime = run.SysTime
+	testCase.MaxRSS = run.MaxRSS
+	testCase.RunStdout = run.RunStdout
+	testCase.StdoutTruncated = run.StdoutTruncated
+	testCase.StdoutSpillPath = run.StdoutSpillPath
+	testCase.RunStderr = run.RunStderr
+	testCase.StderrTruncated = run.StderrTruncated
+	testCase.StderrSpillPath = run.StderrSpillPath
+}
+
+// confirmHang re-runs a test case that timed out, with its timeout
+// multiplied by multiplier, to find out if it really hangs or was just
+// slow. While doing so the CPU usage of the interpreter is sampled so that
+// a busy loop can be told apart from a blocked wait. Should the re-run exit
+// with a non-zero exit code, e.g. by crashing, then its results become
+// those of testCase so that the failure is reported.
+func confirmHang(target *Target, testCase *data.TestCase,
+	timeout time.Duration, multiplier int, output OutputLimits) error {
+
+	confirm := data.NewTestCase()
+	confirm.FuzzFilePath = testCase.FuzzFilePath
+	confirm.SeedFilePaths = testCase.SeedFilePaths
+
+	opts := RunOptions{
+		Timeout:   timeout * time.Duration(multiplier),
+		SampleCPU: true,
+		Output:    output,
+		SpillPath: testCase.FuzzFilePath + HANG_CONFIRM_EXT,
+	}
+	if err := target.Execute(&confirm, opts); err != nil {
+		return err
+	}
+
+	testCase.HangTimeout = opts.Timeout
+	testCase.LeakedProcesses += confirm.LeakedProcesses
+	if !confirm.TestTimedOut {
+		if confirm.ExitCode == 0 {
+			// The test was just slow
+			removeSpills(confirm)
+			return nil
+		}
+
+		testCase.HangExited = true
+		adoptRun(testCase, confirm)
+		return nil
+	}
+
+	testCase.HangConfirmed = true
+	testCase.CPUPercent = confirm.CPUPercent
+	adoptRun(testCase, confirm)
+	if confirm.CPUPercent >= HANG_BUSY_PERCENT {
+		testCase.HangKind = HANG_BUSY
+	} else {
+		testCase.HangKind = HANG_BLOCKED
+	}
+
+	return nil
+}
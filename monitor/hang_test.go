@@ -0,0 +1,160 @@
+package monitor
+
+import (
+	"github.com/SeanHeelan/Malamute/config"
+	"github.com/SeanHeelan/Malamute/data"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// shellTarget returns a Target that runs each fuzz file as a shell script
+func shellTarget() *Target {
+	return &Target{
+		Path:      "/bin/sh",
+		Args:      config.INTERPRETER_ARGS_FUZZ_FILE_MARKER,
+		InputMode: config.INPUT_MODE_FILE,
+	}
+}
+
+func TestConfirmHang(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hang")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Times out when first run, which leaves the marker behind, and
+	// crashes when run again
+	marker := filepath.Join(dir, "marker")
+	if err := ioutil.WriteFile(marker, []byte{}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	crashing := "if [ -e " + marker + " ]; then kill -SEGV $$; fi\n" +
+		"exec sleep 5\n"
+
+	tests := []struct {
+		name          string
+		script        string
+		wantConfirmed bool
+		wantKind      string
+		wantExited    bool
+	}{
+		{"slow", "sleep 0.15\n", false, "", false},
+		{"busy", "while :; do :; done\n", true, HANG_BUSY, false},
+		{"blocked", "exec sleep 5\n", true, HANG_BLOCKED, false},
+		{"crashing", crashing, false, "", true},
+	}
+
+	timeout := 100 * time.Millisecond
+	for _, test := range tests {
+		path := filepath.Join(dir, test.name+".sh")
+		if err := ioutil.WriteFile(path, []byte(test.script),
+			0644); err != nil {
+			t.Fatal(err)
+		}
+
+		testCase := data.NewTestCase()
+		testCase.FuzzFilePath = path
+		testCase.TestTimedOut = true
+		if err := confirmHang(shellTarget(), &testCase, timeout, 4,
+			unlimitedOutput); err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+
+		if testCase.HangTimeout != 4*timeout {
+			t.Errorf("%s: HangTimeout = %s, want %s", test.name,
+				testCase.HangTimeout, 4*timeout)
+		}
+		if testCase.HangConfirmed != test.wantConfirmed {
+			t.Errorf("%s: HangConfirmed = %v, want %v", test.name,
+				testCase.HangConfirmed, test.wantConfirmed)
+		}
+		if testCase.HangKind != test.wantKind {
+			t.Errorf("%s: HangKind = %q (%d%% CPU), want %q", test.name,
+				testCase.HangKind, testCase.CPUPercent, test.wantKind)
+		}
+		if testCase.HangExited != test.wantExited {
+			t.Errorf("%s: HangExited = %v, want %v", test.name,
+				testCase.HangExited, test.wantExited)
+		}
+		if test.wantExited && (testCase.TestTimedOut ||
+			testCase.ExitCode != 128+int(syscall.SIGSEGV)) {
+			t.Errorf("%s: timed out %v, exit code %d", test.name,
+				testCase.TestTimedOut, testCase.ExitCode)
+		}
+	}
+}
+
+// The confirmation run must not overwrite the spilled output of the run
+// that timed out, which is kept if the hang isn't confirmed
+func TestConfirmHangSpill(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hang")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Prints which run it is, and then more than is kept
+	count := filepath.Join(dir, "count")
+	script := "echo >> " + count + "\nwc -l < " + count + "\n" +
+		"seq 1 100\nsleep $1\n"
+	limits := OutputLimits{HeadLines: 5, HeadBytes: 1000, TailLines: 5,
+		TailBytes: 1000, Spill: true}
+
+	tests := []struct {
+		name          string
+		sleep         string
+		wantConfirmed bool
+		wantRun       string
+	}{
+		{"slow", "0.15", false, "1"},
+		{"hang", "5", true, "2"},
+	}
+
+	timeout := 100 * time.Millisecond
+	for _, test := range tests {
+		os.Remove(count)
+		path := filepath.Join(dir, test.name+".sh")
+		if err := ioutil.WriteFile(path, []byte(script), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		target := shellTarget()
+		target.Args += " " + test.sleep
+		testCase := data.NewTestCase()
+		testCase.FuzzFilePath = path
+		if err := target.Execute(&testCase, RunOptions{Timeout: timeout,
+			Output: limits}); err != nil {
+			t.Fatal(err)
+		}
+		if !testCase.TestTimedOut {
+			t.Fatalf("%s: the first run did not time out", test.name)
+		}
+		if err := confirmHang(target, &testCase, timeout, 4,
+			limits); err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+
+		if testCase.HangConfirmed != test.wantConfirmed {
+			t.Errorf("%s: HangConfirmed = %v, want %v", test.name,
+				testCase.HangConfirmed, test.wantConfirmed)
+		}
+		spilled, err := ioutil.ReadFile(testCase.StdoutSpillPath)
+		if err != nil || !strings.HasPrefix(string(spilled),
+			test.wantRun+"\n1\n") {
+			t.Errorf("%s: spilled %.10q from %s, want run %s", test.name,
+				spilled, testCase.StdoutSpillPath, test.wantRun)
+		}
+
+		// Only the spill files of the kept run are left
+		spills, _ := filepath.Glob(path + "*" + STDOUT_SPILL_EXT)
+		if len(spills) != 1 {
+			t.Errorf("%s: spill files %q", test.name, spills)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package monitor
+
+import (
+	"bytes"
+	"github.com/SeanHeelan/Malamute/config"
+	"github.com/SeanHeelan/Malamute/data"
+	"github.com/SeanHeelan/Malamute/normalize"
+	"github.com/SeanHeelan/Malamute/oracle"
+	"io/ioutil"
+	"log"
+	"os"
+)
+
+// expectationChecker compares the results of tests against the expected
+// results of their seeds. It is not safe for concurrent use, so each
+// monitor has its own.
+type expectationChecker struct {
+	normalizer *normalize.Normalizer
+	// expectations caches the expectation of each seed. Seeds without one
+	// are recorded as nil.
+	expectations map[string]*oracle.Expectation
+}
+
+// newExpectationChecker returns a checker if the oracle is enabled in cfg,
+// and nil otherwise
+func newExpectationChecker(cfg *config.Config) (*expectationChecker,
+	error) {
+
+	if !cfg.Oracle.Enabled {
+		return nil, nil
+	}
+
+	normalizer, err := normalize.New(cfg.Normalize.Preset,
+		cfg.Normalize.Replace, cfg.Normalize.DropLine,
+		cfg.Normalize.FloatPrecision)
+	if err != nil {
+		return nil, err
+	}
+
+	return &expectationChecker{normalizer,
+		make(map[string]*oracle.Expectation)}, nil
+}
+
+func (c *expectationChecker) expectation(seedPath string) *oracle.Expectation {
+	if e, ok := c.expectations[seedPath]; ok {
+		return e
+	}
+
+	e, err := oracle.Load(seedPath)
+	if err != nil {
+		log.Printf("Could not read the expected result of %s : %s",
+			seedPath, err)
+	}
+	c.expectations[seedPath] = e
+
+	return e
+}
+
+// isUnmutated indicates if the fuzz file of testCase is an exact copy of
+// its single seed
+func isUnmutated(testCase *data.TestCase) bool {
+	if len(testCase.SeedFilePaths) != 1 {
+		return false
+	}
+
+	seedData, err := ioutil.ReadFile(testCase.SeedFilePaths[0])
+	if err != nil {
+		return false
+	}
+	fuzzData, err := ioutil.ReadFile(testCase.FuzzFilePath)
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(seedData, fuzzData)
+}
+
+// fullOutput returns the whole of a stream, reading it back from the spill
+// file if it was truncated. false is returned if it cannot be recovered.
+func fullOutput(lines []string, truncated bool,
+	spillPath string) ([]string, bool) {
+
+	if !truncated {
+		return lines, true
+	}
+
+	if len(spillPath) == 0 {
+		return nil, false
+	}
+
+	f, err := os.Open(spillPath)
+	if err != nil {
+		log.Printf("Could not read %s : %s", spillPath, err)
+		return nil, false
+	}
+	defer f.Close()
+
+	full := []string{}
+	readLines(f, func(line string) {
+		full = append(full, line)
+	})
+
+	return full, true
+}
+
+// check fills in the expectation fields of testCase. Tests generated from
+// several seeds, and tests that timed out, are not checked. Nor are tests
+// whose output was truncated and not spilled, as they would never match.
+func (c *expectationChecker) check(testCase *data.TestCase) {
+	if testCase.TestTimedOut || len(testCase.SeedFilePaths) != 1 {
+		return
+	}
+
+	e := c.expectation(testCase.SeedFilePaths[0])
+	if e == nil {
+		return
+	}
+
+	stdout, ok := fullOutput(testCase.RunStdout, testCase.StdoutTruncated,
+		testCase.StdoutSpillPath)
+	if !ok {
+		testCase.ExpectationSkipped = true
+		return
+	}
+
+	stderr, ok := fullOutput(testCase.RunStderr, testCase.StderrTruncated,
+		testCase.StderrSpillPath)
+	if !ok {
+		testCase.ExpectationSkipped = true
+		return
+	}
+
+	testCase.ExpectationChecked = true
+	testCase.ExpectationMismatch = e.Check(stdout, stderr,
+		testCase.ExitCode, c.normalizer)
+	testCase.Unmutated = isUnmutated(testCase)
+}
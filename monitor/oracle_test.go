@@ -0,0 +1,102 @@
+package monitor
+
+import (
+	"github.com/SeanHeelan/Malamute/config"
+	"github.com/SeanHeelan/Malamute/data"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExpectationCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oracle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name string, content string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	seed := write("t.php", "<?php echo 1;\n")
+	write("t.phpt", "--FILE--\n<?php echo 1;\n--EXPECT--\n1\n2\n3\n")
+	mutated := write("mutated.php", "<?php echo 2;\n")
+	spill := write("spill.stdout", "1\n2\n3\n")
+
+	tests := []struct {
+		name          string
+		testCase      data.TestCase
+		wantChecked   bool
+		wantSkipped   bool
+		wantMismatch  bool
+		wantUnmutated bool
+	}{
+		{
+			name: "matching unmutated",
+			testCase: data.TestCase{FuzzFilePath: seed,
+				RunStdout: []string{"1", "2", "3"}},
+			wantChecked:   true,
+			wantUnmutated: true,
+		},
+		{
+			name: "mismatching mutated",
+			testCase: data.TestCase{FuzzFilePath: mutated,
+				RunStdout: []string{"2"}},
+			wantChecked:  true,
+			wantMismatch: true,
+		},
+		{
+			name: "truncated and spilled",
+			testCase: data.TestCase{FuzzFilePath: seed,
+				RunStdout: []string{"1", "3"}, StdoutTruncated: true,
+				StdoutSpillPath: spill},
+			wantChecked:   true,
+			wantUnmutated: true,
+		},
+		{
+			name: "truncated and not spilled",
+			testCase: data.TestCase{FuzzFilePath: seed,
+				RunStdout: []string{"1", "3"}, StdoutTruncated: true},
+			wantSkipped: true,
+		},
+		{
+			name: "timed out",
+			testCase: data.TestCase{FuzzFilePath: seed,
+				TestTimedOut: true},
+		},
+	}
+
+	cfg := &config.Config{}
+	cfg.Oracle.Enabled = true
+	checker, err := newExpectationChecker(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range tests {
+		testCase := test.testCase
+		testCase.SeedFilePaths = []string{seed}
+		checker.check(&testCase)
+
+		if testCase.ExpectationChecked != test.wantChecked ||
+			testCase.ExpectationSkipped != test.wantSkipped {
+			t.Errorf("%s: checked %v, skipped %v, want %v, %v", test.name,
+				testCase.ExpectationChecked, testCase.ExpectationSkipped,
+				test.wantChecked, test.wantSkipped)
+		}
+		if got := len(testCase.ExpectationMismatch) != 0; got !=
+			test.wantMismatch {
+			t.Errorf("%s: mismatch %q, want mismatch %v", test.name,
+				testCase.ExpectationMismatch, test.wantMismatch)
+		}
+		if testCase.Unmutated != test.wantUnmutated {
+			t.Errorf("%s: Unmutated = %v, want %v", test.name,
+				testCase.Unmutated, test.wantUnmutated)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package monitor
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/SeanHeelan/Malamute/config"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+const (
+	// Lines longer than this are split, so that a stream without newlines
+	// cannot exhaust memory
+	OUTPUT_MAX_LINE_BYTES = 1024 * 1024
+	// Full output is spilled to the fuzz file path plus these
+	STDOUT_SPILL_EXT = ".stdout"
+	STDERR_SPILL_EXT = ".stderr"
+	// The number of seconds that output is collected for after the
+	// interpreter has exited, in case a process that escaped its process
+	// group holds the pipes open
+	OUTPUT_DRAIN_TIMEOUT = 5
+)
+
+// OutputLimits control how much of each stream written by the interpreter
+// is kept
+type OutputLimits struct {
+	HeadLines int
+	HeadBytes int
+	TailLines int
+	TailBytes int
+	// Spill indicates that output which does not fit within the limits
+	// should be written to a file, rather than discarded
+	Spill bool
+}
+
+// outputLimits returns the OutputLimits described by the Output section of
+// cfg
+func outputLimits(cfg *config.Config) OutputLimits {
+	limits := OutputLimits{
+		HeadLines: cfg.Output.HeadLines,
+		HeadBytes: cfg.Output.HeadBytes,
+		TailLines: cfg.Output.TailLines,
+		TailBytes: cfg.Output.TailBytes,
+		Spill:     !cfg.Output.NoSpill,
+	}
+
+	// Sessions created by older versions have no Output section
+	if limits.HeadLines == 0 && limits.TailLines == 0 {
+		limits.HeadLines = config.OUTPUT_DEFAULT_LINES
+		limits.HeadBytes = config.OUTPUT_DEFAULT_BYTES
+		limits.TailLines = config.OUTPUT_DEFAULT_LINES
+		limits.TailBytes = config.OUTPUT_DEFAULT_BYTES
+	}
+
+	return limits
+}
+
+// capturedOutput is what was kept of a single stream
+type capturedOutput struct {
+	Lines     []string
+	Truncated bool
+	// SpillPath is the file holding the full output, or empty if nothing
+	// was spilled
+	SpillPath string
+}
+
+// outputBuffer keeps the head and tail of a stream of lines. Once the
+// stream no longer fits, and spilling is enabled, everything is also
+// written to a file so that nothing is lost.
+type outputBuffer struct {
+	limits    OutputLimits
+	spillPath string
+	spill     *bufio.Writer
+	spillFile *os.File
+
+	head      []string
+	headBytes int
+	headDone  bool
+	tail      []string
+	tailBytes int
+	truncated bool
+}
+
+// newOutputBuffer creates an outputBuffer that spills to spillPath. If
+// spillPath is empty, or spilling is disabled by limits, then output that
+// does not fit is discarded.
+func newOutputBuffer(limits OutputLimits, spillPath string) *outputBuffer {
+	b := outputBuffer{limits: limits}
+	if limits.Spill {
+		b.spillPath = spillPath
+	}
+
+	return &b
+}
+
+// startSpill creates the spill file and writes out everything kept so far
+func (b *outputBuffer) startSpill() {
+	if len(b.spillPath) == 0 || b.spill != nil {
+		return
+	}
+
+	f, err := os.Create(b.spillPath)
+	if err != nil {
+		log.Printf("Could not create %s : %s", b.spillPath, err)
+		b.spillPath = ""
+		return
+	}
+
+	b.spillFile = f
+	b.spill = bufio.NewWriter(f)
+	for _, line := range b.head {
+		fmt.Fprintln(b.spill, line)
+	}
+	for _, line := range b.tail {
+		fmt.Fprintln(b.spill, line)
+	}
+}
+
+// add appends a line to the buffer
+func (b *outputBuffer) add(line string) {
+	if !b.headDone && len(b.head) < b.limits.HeadLines &&
+		b.headBytes+len(line) <= b.limits.HeadBytes {
+		b.head = append(b.head, line)
+		b.headBytes += len(line)
+		return
+	}
+	b.headDone = true
+
+	if b.spill != nil {
+		fmt.Fprintln(b.spill, line)
+	}
+
+	b.tail = append(b.tail, line)
+	b.tailBytes += len(line)
+
+	// The last line is always kept, however long it is
+	for len(b.tail) > b.limits.TailLines ||
+		(len(b.tail) > 1 && b.tailBytes > b.limits.TailBytes) {
+		b.startSpill()
+		b.truncated = true
+		b.tailBytes -= len(b.tail[0])
+		b.tail = b.tail[1:]
+	}
+}
+
+// finish closes the spill file and returns what was kept
+func (b *outputBuffer) finish() capturedOutput {
+	output := capturedOutput{
+		Lines:     append(b.head, b.tail...),
+		Truncated: b.truncated,
+	}
+
+	if b.spill != nil {
+		if err := b.spill.Flush(); err != nil {
+			log.Printf("Could not write %s : %s", b.spillPath, err)
+		}
+		b.spillFile.Close()
+		output.SpillPath = b.spillPath
+	}
+
+	if output.Lines == nil {
+		output.Lines = []string{}
+	}
+
+	return output
+}
+
+// readLines calls fn with each line read from reader until it is exhausted.
+// Lines longer than OUTPUT_MAX_LINE_BYTES are split.
+func readLines(reader io.Reader, fn func(string)) {
+	r := bufio.NewReader(reader)
+	line := []byte{}
+	split := false
+	for {
+		fragment, isPrefix, err := r.ReadLine()
+		line = append(line, fragment...)
+		if err != nil {
+			if len(line) != 0 {
+				fn(string(line))
+			}
+			return
+		}
+
+		if isPrefix && len(line) >= OUTPUT_MAX_LINE_BYTES {
+			fn(string(line))
+			line = line[:0]
+			split = true
+		} else if !isPrefix {
+			// A split that fell on the end of the line leaves nothing
+			if len(line) != 0 || !split {
+				fn(string(line))
+			}
+			line = line[:0]
+			split = false
+		}
+	}
+}
+
+// captureToChannel reads reader until it is exhausted, keeping what is
+// allowed by limits, and sends the result on out
+func captureToChannel(reader io.Reader, limits OutputLimits,
+	spillPath string, out chan capturedOutput) {
+
+	b := newOutputBuffer(limits, spillPath)
+	readLines(reader, b.add)
+	out <- b.finish()
+}
+
+// collectOutput waits for the output captured from the pipes stdoutPipe and
+// stderrPipe, which are then closed. Should that take more than
+// OUTPUT_DRAIN_TIMEOUT seconds the pipes are closed early, and only what was
+// read up to then is returned.
+func collectOutput(stdoutChan chan capturedOutput,
+	stderrChan chan capturedOutput, stdoutPipe *os.File,
+	stderrPipe *os.File) (capturedOutput, capturedOutput) {
+
+	var stdout, stderr capturedOutput
+	deadline := time.After(OUTPUT_DRAIN_TIMEOUT * time.Second)
+	for stdoutChan != nil || stderrChan != nil {
+		select {
+		case stdout = <-stdoutChan:
+			stdoutChan = nil
+		case stderr = <-stderrChan:
+			stderrChan = nil
+		case <-deadline:
+			log.Printf("Output was still being written %d seconds after "+
+				"the interpreter exited", OUTPUT_DRAIN_TIMEOUT)
+			stdoutPipe.Close()
+			stderrPipe.Close()
+			deadline = nil
+		}
+	}
+
+	stdoutPipe.Close()
+	stderrPipe.Close()
+	return stdout, stderr
+}
@@ -0,0 +1,216 @@
+package monitor
+
+import (
+	"fmt"
+	"github.com/SeanHeelan/Malamute/data"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// numbered returns the lines "1" to "n"
+func numbered(n int) []string {
+	lines := []string{}
+	for i := 1; i <= n; i++ {
+		lines = append(lines, fmt.Sprint(i))
+	}
+
+	return lines
+}
+
+func TestOutputBuffer(t *testing.T) {
+	limits := OutputLimits{HeadLines: 2, HeadBytes: 100, TailLines: 3,
+		TailBytes: 100}
+	byteLimits := OutputLimits{HeadLines: 100, HeadBytes: 4,
+		TailLines: 100, TailBytes: 4}
+
+	tests := []struct {
+		name          string
+		limits        OutputLimits
+		spill         bool
+		lines         []string
+		want          []string
+		wantTruncated bool
+	}{
+		{
+			name:   "empty",
+			limits: limits,
+			lines:  []string{},
+			want:   []string{},
+		},
+		{
+			name:   "fits",
+			limits: limits,
+			lines:  numbered(5),
+			want:   numbered(5),
+		},
+		{
+			name:          "head and tail",
+			limits:        limits,
+			lines:         numbered(8),
+			want:          []string{"1", "2", "6", "7", "8"},
+			wantTruncated: true,
+		},
+		{
+			name:          "spilled",
+			limits:        limits,
+			spill:         true,
+			lines:         numbered(8),
+			want:          []string{"1", "2", "6", "7", "8"},
+			wantTruncated: true,
+		},
+		{
+			name:          "byte limits",
+			limits:        byteLimits,
+			lines:         []string{"aa", "bb", "cc", "dd", "ee"},
+			want:          []string{"aa", "bb", "dd", "ee"},
+			wantTruncated: true,
+		},
+		{
+			// The last line is kept however long it is, but once the
+			// head is full nothing more is added to it
+			name:          "long lines",
+			limits:        byteLimits,
+			lines:         []string{"a", "bbbbbb", "c", "dddddd"},
+			want:          []string{"a", "dddddd"},
+			wantTruncated: true,
+		},
+	}
+
+	for _, test := range tests {
+		dir, err := ioutil.TempDir("", "output")
+		if err != nil {
+			t.Fatal(err)
+		}
+		spillPath := filepath.Join(dir, "t.php"+STDOUT_SPILL_EXT)
+
+		limits := test.limits
+		limits.Spill = test.spill
+		b := newOutputBuffer(limits, spillPath)
+		for _, line := range test.lines {
+			b.add(line)
+		}
+		output := b.finish()
+
+		if !reflect.DeepEqual(output.Lines, test.want) {
+			t.Errorf("%s: Lines = %q, want %q", test.name, output.Lines,
+				test.want)
+		}
+		if output.Truncated != test.wantTruncated {
+			t.Errorf("%s: Truncated = %v, want %v", test.name,
+				output.Truncated, test.wantTruncated)
+		}
+
+		// Only output that was truncated is spilled, and then in full
+		wantSpill := test.spill && test.wantTruncated
+		if got := len(output.SpillPath) != 0; got != wantSpill {
+			t.Errorf("%s: SpillPath = %q, want spilled %v", test.name,
+				output.SpillPath, wantSpill)
+		}
+		if wantSpill {
+			full, ok := fullOutput(output.Lines, output.Truncated,
+				output.SpillPath)
+			if !ok || !reflect.DeepEqual(full, test.lines) {
+				t.Errorf("%s: spilled %q, want %q", test.name, full,
+					test.lines)
+			}
+		}
+
+		os.RemoveAll(dir)
+	}
+}
+
+func TestReadLines(t *testing.T) {
+	long := strings.Repeat("x", OUTPUT_MAX_LINE_BYTES)
+
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"empty", "", []string{}},
+		{"lines", "a\nb\n", []string{"a", "b"}},
+		{"no final newline", "a\nb", []string{"a", "b"}},
+		{"blank lines", "\n\na\n", []string{"", "", "a"}},
+		{"carriage returns", "a\r\nb\r\n", []string{"a", "b"}},
+		{"longest line", long + "\n", []string{long}},
+		{"long line", long + "yz\nb\n", []string{long, "yz", "b"}},
+		{"long line without newline", long + long,
+			[]string{long, long}},
+	}
+
+	for _, test := range tests {
+		got := []string{}
+		readLines(strings.NewReader(test.input), func(line string) {
+			got = append(got, line)
+		})
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: %d lines of lengths %v, want %d of %v",
+				test.name, len(got), lengths(got), len(test.want),
+				lengths(test.want))
+		}
+	}
+}
+
+func lengths(lines []string) []int {
+	l := []int{}
+	for _, line := range lines {
+		l = append(l, len(line))
+	}
+
+	return l
+}
+
+// An interpreter writing far more than is kept must not be blocked, and
+// what it writes last must be kept
+func TestExecuteLargeOutput(t *testing.T) {
+	dir, err := ioutil.TempDir("", "output")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "t.sh")
+	script := "seq 1 200000\nseq 1 200000 >&2\necho last\nexit 3\n"
+	if err := ioutil.WriteFile(path, []byte(script), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	testCase := data.NewTestCase()
+	testCase.FuzzFilePath = path
+	opts := RunOptions{
+		Timeout: 20 * time.Second,
+		Output: OutputLimits{HeadLines: 10, HeadBytes: 1000,
+			TailLines: 10, TailBytes: 1000, Spill: true},
+	}
+	if err := shellTarget().Execute(&testCase, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	if testCase.TestTimedOut || testCase.ExitCode != 3 {
+		t.Fatalf("Timed out %v, exit code %d", testCase.TestTimedOut,
+			testCase.ExitCode)
+	}
+	if len(testCase.RunStdout) != 20 ||
+		testCase.RunStdout[19] != "last" || !testCase.StdoutTruncated {
+		t.Errorf("Kept %d lines of stdout, ending %q, truncated %v",
+			len(testCase.RunStdout),
+			testCase.RunStdout[len(testCase.RunStdout)-1],
+			testCase.StdoutTruncated)
+	}
+	if len(testCase.RunStderr) != 20 || !testCase.StderrTruncated {
+		t.Errorf("Kept %d lines of stderr, truncated %v",
+			len(testCase.RunStderr), testCase.StderrTruncated)
+	}
+
+	full, ok := fullOutput(testCase.RunStdout, testCase.StdoutTruncated,
+		testCase.StdoutSpillPath)
+	if !ok || len(full) != 200001 {
+		t.Errorf("Spilled %d lines of stdout, want 200001", len(full))
+	}
+}
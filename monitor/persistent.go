@@ -0,0 +1,355 @@
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"github.com/SeanHeelan/Malamute/config"
+	"github.com/SeanHeelan/Malamute/data"
+	"github.com/SeanHeelan/Malamute/logging"
+	"github.com/SeanHeelan/Malamute/session"
+	"github.com/kballard/go-shellquote"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+const (
+	// The number of lines that may be buffered from the interpreter before
+	// it is blocked from writing any more
+	PERSISTENT_LINE_BUFFER = 1024
+	// The number of seconds a recycled interpreter is given to exit once
+	// its STDIN has been closed
+	PERSISTENT_EXIT_GRACE = 2
+)
+
+// persistentProcess is a single running instance of a persistent
+// interpreter
+type persistentProcess struct {
+	cmd      *exec.Cmd
+	stdin    io.WriteCloser
+	stdout   chan string
+	stderr   chan string
+	done     chan error
+	testsRun int
+}
+
+func lineReader(reader io.Reader, out chan string) {
+	readLines(reader, func(line string) {
+		out <- line
+	})
+
+	close(out)
+}
+
+// Persistent is a monitor that starts the interpreter once and then feeds it
+// test cases over STDIN, using the delimiter protocol described on
+// config.Persistent.Delimiter. If the interpreter dies, or has to be killed,
+// then this is attributed to the last test case fed to it and a new
+// interpreter is started for the next test case.
+type Persistent struct {
+	S *session.Session
+	L *logging.Logs
+}
+
+func (m *Persistent) start(args []string, environ []string) (
+	*persistentProcess, error) {
+
+	cfg := m.S.Config
+	cmd := exec.Command(cfg.Interpreter.Path, args...)
+	cmd.Env = environ
+	if cfg.Profile.Enabled {
+		cmd.Env = append(environ[:len(environ):len(environ)],
+			profileEnv(m.S.ProfileDir, "persistent"))
+	}
+	cmd.Dir = m.S.TestCasesDir
+	cmd.SysProcAttr = newProcAttr()
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("Error %s accessing stdin of command", err)
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("Error %s accessing stdout of command", err)
+	}
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("Error %s accessing stderr of command", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("Error %s starting %s", err,
+			cfg.Interpreter.Path)
+	}
+	trackProcessGroup(cmd.Process.Pid)
+
+	p := persistentProcess{
+		cmd:    cmd,
+		stdin:  stdin,
+		stdout: make(chan string, PERSISTENT_LINE_BUFFER),
+		stderr: make(chan string, PERSISTENT_LINE_BUFFER),
+		done:   make(chan error, 1),
+	}
+	go lineReader(stdout, p.stdout)
+	go lineReader(stderr, p.stderr)
+
+	// The harness learns the delimiter from the first line it reads
+	if _, err := io.WriteString(stdin, cfg.Persistent.Delimiter+"\n"); err != nil {
+		m.kill(&p)
+		return nil, fmt.Errorf("Error %s writing the delimiter to %s", err,
+			cfg.Interpreter.Path)
+	}
+
+	m.L.DEBUGF("Started persistent interpreter %s with PID %d",
+		cfg.Interpreter.Path, cmd.Process.Pid)
+
+	return &p, nil
+}
+
+// kill terminates the interpreter along with anything it has started and
+// returns the error from waiting on it
+func (m *Persistent) kill(p *persistentProcess) error {
+	if err := killProcessGroup(p.cmd.Process.Pid); err != nil &&
+		err != syscall.ESRCH {
+		log.Printf("Could not kill persistent interpreter: %s", err)
+	}
+
+	return m.wait(p)
+}
+
+// wait drains whatever output is left from the interpreter and then waits
+// for it to exit
+func (m *Persistent) wait(p *persistentProcess) error {
+	for range p.stdout {
+	}
+	for range p.stderr {
+	}
+
+	return p.cmd.Wait()
+}
+
+// stop shuts down an interpreter that is between test cases. It is first
+// given the chance to exit cleanly by closing its STDIN.
+func (m *Persistent) stop(p *persistentProcess) {
+	p.stdin.Close()
+
+	go func() {
+		p.done <- m.wait(p)
+	}()
+
+	select {
+	case <-p.done:
+	case <-time.After(PERSISTENT_EXIT_GRACE * time.Second):
+		if err := killProcessGroup(p.cmd.Process.Pid); err != nil &&
+			err != syscall.ESRCH {
+			log.Printf("Could not kill persistent interpreter: %s", err)
+		}
+		<-p.done
+	}
+
+	reapProcessGroup(p.cmd.Process.Pid)
+}
+
+// readUntilDelimiters collects lines from stdout and stderr until the
+// delimiter has been seen on both. They are read together so that an
+// interpreter writing a lot to one is never blocked while the other is
+// waited on. The first return value indicates whether both delimiters were
+// seen, and the second whether the deadline passed before they were.
+func readUntilDelimiters(stdout chan string, stderr chan string,
+	delimiter string, deadline <-chan time.Time, stdoutData *outputBuffer,
+	stderrData *outputBuffer) (bool, bool) {
+
+	// A channel is set to nil, and so never selected, once its delimiter
+	// has been seen
+	for stdout != nil || stderr != nil {
+		select {
+		case line, ok := <-stdout:
+			if !ok {
+				return false, false
+			}
+
+			if line == delimiter {
+				stdout = nil
+			} else {
+				stdoutData.add(line)
+			}
+		case line, ok := <-stderr:
+			if !ok {
+				return false, false
+			}
+
+			if line == delimiter {
+				stderr = nil
+			} else {
+				stderrData.add(line)
+			}
+		case <-deadline:
+			return false, true
+		}
+	}
+
+	return true, false
+}
+
+// drainLines collects lines from lines until the stream ends. It returns
+// true if the deadline passed before that happened.
+func drainLines(lines chan string, deadline <-chan time.Time,
+	collected *outputBuffer) bool {
+
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				return false
+			}
+
+			collected.add(line)
+		case <-deadline:
+			return true
+		}
+	}
+}
+
+// Run starts a work loop that consumes test cases, feeds each to a persistent
+// interpreter and passes them on with the results filled in. On error a
+// message will be sent on the errOut channel.
+func (m *Persistent) Run(in chan data.TestCase, out chan data.TestCase,
+	errOut chan error) {
+
+	cfg := m.S.Config
+	args, err := shellquote.Split(cfg.Interpreter.Args)
+	if err != nil {
+		msg := fmt.Sprintf("Failed to parse target arguments : %s",
+			cfg.Interpreter.Args)
+		errOut <- errors.New(msg)
+		return
+	}
+
+	envMods := environment("")
+	environ := append(os.Environ(), envMods...)
+	delimiter := cfg.Persistent.Delimiter
+	limits := outputLimits(cfg)
+
+	var p *persistentProcess
+	for {
+		testCase := <-in
+		if len(testCase.SeedFilePaths) == 0 {
+			if p != nil {
+				m.stop(p)
+			}
+			out <- testCase
+			break
+		}
+
+		testCase.ApplicationEnv = append(testCase.ApplicationEnv, envMods...)
+		testCase.ApplicationPath = cfg.Interpreter.Path
+		testCase.ApplicationArgs = args
+		testCase.InputMode = config.MONITOR_PERSISTENT
+
+		fuzzFile := testCase.FuzzFilePath
+		fileData, err := ioutil.ReadFile(fuzzFile)
+		if err != nil {
+			msg := fmt.Sprintf("Could not read the fuzz file %s. Error %s",
+				fuzzFile, err)
+			errOut <- errors.New(msg)
+			continue
+		}
+
+		if p == nil {
+			if p, err = m.start(args, environ); err != nil {
+				errOut <- err
+				continue
+			}
+		}
+
+		m.L.DEBUGF("Feeding %s to interpreter %d as test %d", fuzzFile,
+			p.cmd.Process.Pid, p.testsRun+1)
+
+		// The write is done asynchronously as an interpreter that is stuck
+		// will never read it. If the write fails then the interpreter has
+		// died, which we will see when reading its output.
+		go func(stdin io.Writer) {
+			stdin.Write(fileData)
+			io.WriteString(stdin, "\n"+delimiter+"\n")
+		}(p.stdin)
+
+		startTime := time.Now()
+		testCase.Timeout = m.S.SeedTimeout(testCase.SeedFilePaths,
+			cfg.Interpreter.Timeout.Duration)
+		deadline := time.After(testCase.Timeout)
+		stdoutData := newOutputBuffer(limits, fuzzFile+STDOUT_SPILL_EXT)
+		stderrData := newOutputBuffer(limits, fuzzFile+STDERR_SPILL_EXT)
+		finished, timedOut := readUntilDelimiters(p.stdout, p.stderr,
+			delimiter, deadline, stdoutData, stderrData)
+		p.testsRun++
+
+		if finished {
+			testCase.TestTimedOut = false
+			testCase.WallTime = time.Now().Sub(startTime)
+			setOutput(&testCase, stdoutData.finish(), stderrData.finish())
+			testCase.ExitCode = 0
+
+			if cfg.Persistent.RecycleCount != 0 &&
+				p.testsRun >= cfg.Persistent.RecycleCount {
+				m.L.DEBUGF("Recycling interpreter %d after %d tests",
+					p.cmd.Process.Pid, p.testsRun)
+				m.stop(p)
+				p = nil
+			}
+
+			out <- testCase
+			continue
+		}
+
+		if timedOut {
+			log.Printf("Test %s took too long to finish and the persistent "+
+				"interpreter was killed", fuzzFile)
+			testCase.LeakedProcesses = countHelpers(p.cmd.Process.Pid)
+			m.kill(p)
+			p = nil
+
+			testCase.TestTimedOut = true
+			setOutput(&testCase, stdoutData.finish(), stderrData.finish())
+			out <- testCase
+			continue
+		}
+
+		// The interpreter died while running the test. Whatever is left
+		// on STDERR, such as a sanitizer report, belongs to this test.
+		log.Printf("Persistent interpreter %d died after %d tests",
+			p.cmd.Process.Pid, p.testsRun)
+		if drainLines(p.stderr, deadline, stderrData) {
+			// Something is still holding STDERR open
+			if err := killProcessGroup(p.cmd.Process.Pid); err != nil &&
+				err != syscall.ESRCH {
+				log.Printf("Could not kill persistent interpreter: %s", err)
+			}
+		}
+		waitErr := m.wait(p)
+		testCase.LeakedProcesses = reapProcessGroup(p.cmd.Process.Pid)
+		p = nil
+
+		testCase.TestTimedOut = false
+		testCase.WallTime = time.Now().Sub(startTime)
+		setOutput(&testCase, stdoutData.finish(), stderrData.finish())
+		testCase.ExitCode = 0
+		if exitErr, ok := waitErr.(*exec.ExitError); ok {
+			if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+				testCase.ExitCode = statusExitCode(status)
+			}
+		} else if waitErr != nil {
+			msg := fmt.Sprintf("Error %s executing %s on %s", waitErr,
+				cfg.Interpreter.Path, fuzzFile)
+			errOut <- errors.New(msg)
+			continue
+		}
+
+		out <- testCase
+	}
+}
@@ -0,0 +1,229 @@
+package monitor
+
+import (
+	"github.com/SeanHeelan/Malamute/config"
+	"github.com/SeanHeelan/Malamute/data"
+	"github.com/SeanHeelan/Malamute/logging"
+	"github.com/SeanHeelan/Malamute/session"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+)
+
+const TEST_DELIMITER = "--DELIM--"
+
+var unlimitedOutput = OutputLimits{
+	HeadLines: 1 << 20,
+	HeadBytes: 1 << 30,
+	TailLines: 1 << 20,
+	TailBytes: 1 << 30,
+}
+
+// feed returns a channel that yields lines, and is then closed if closed is
+// set
+func feed(lines []string, closed bool) chan string {
+	ch := make(chan string, len(lines))
+	for _, line := range lines {
+		ch <- line
+	}
+	if closed {
+		close(ch)
+	}
+
+	return ch
+}
+
+func TestReadUntilDelimiters(t *testing.T) {
+	tests := []struct {
+		name         string
+		stdout       []string
+		stdoutClosed bool
+		stderr       []string
+		stderrClosed bool
+		finished     bool
+		timedOut     bool
+		wantStdout   []string
+		wantStderr   []string
+	}{
+		{
+			name:       "both delimited",
+			stdout:     []string{"a", "b", TEST_DELIMITER},
+			stderr:     []string{"e", TEST_DELIMITER},
+			finished:   true,
+			wantStdout: []string{"a", "b"},
+			wantStderr: []string{"e"},
+		},
+		{
+			name:       "empty output",
+			stdout:     []string{TEST_DELIMITER},
+			stderr:     []string{TEST_DELIMITER},
+			finished:   true,
+			wantStdout: []string{},
+			wantStderr: []string{},
+		},
+		{
+			name:       "lines after the delimiter are left",
+			stdout:     []string{"a", TEST_DELIMITER, "next"},
+			stderr:     []string{TEST_DELIMITER, "next"},
+			finished:   true,
+			wantStdout: []string{"a"},
+			wantStderr: []string{},
+		},
+		{
+			name:         "stdout closed",
+			stdout:       []string{"a"},
+			stdoutClosed: true,
+			stderr:       []string{TEST_DELIMITER},
+		},
+		{
+			name:         "stderr closed",
+			stdout:       []string{TEST_DELIMITER},
+			stderr:       []string{"e"},
+			stderrClosed: true,
+		},
+		{
+			name:     "stderr never delimited",
+			stdout:   []string{"a", TEST_DELIMITER},
+			stderr:   []string{"e"},
+			timedOut: true,
+		},
+	}
+
+	for _, test := range tests {
+		stdout := feed(test.stdout, test.stdoutClosed)
+		stderr := feed(test.stderr, test.stderrClosed)
+		stdoutData := newOutputBuffer(unlimitedOutput, "")
+		stderrData := newOutputBuffer(unlimitedOutput, "")
+
+		finished, timedOut := readUntilDelimiters(stdout, stderr,
+			TEST_DELIMITER, time.After(100*time.Millisecond), stdoutData,
+			stderrData)
+		if finished != test.finished || timedOut != test.timedOut {
+			t.Errorf("%s: got finished %v, timed out %v, want %v, %v",
+				test.name, finished, timedOut, test.finished,
+				test.timedOut)
+			continue
+		}
+		if !finished {
+			continue
+		}
+
+		if got := stdoutData.finish().Lines; !reflect.DeepEqual(got,
+			test.wantStdout) {
+			t.Errorf("%s: stdout = %q, want %q", test.name, got,
+				test.wantStdout)
+		}
+		if got := stderrData.finish().Lines; !reflect.DeepEqual(got,
+			test.wantStderr) {
+			t.Errorf("%s: stderr = %q, want %q", test.name, got,
+				test.wantStderr)
+		}
+	}
+}
+
+// An interpreter that writes more to stderr than can be buffered before
+// writing its stdout delimiter must not be blocked
+func TestReadUntilDelimitersFullStderr(t *testing.T) {
+	stdout := make(chan string)
+	stderr := make(chan string)
+	go func() {
+		for i := 0; i < 4*PERSISTENT_LINE_BUFFER; i++ {
+			stderr <- "e"
+		}
+		stderr <- TEST_DELIMITER
+		stdout <- "a"
+		stdout <- TEST_DELIMITER
+	}()
+
+	stdoutData := newOutputBuffer(unlimitedOutput, "")
+	stderrData := newOutputBuffer(unlimitedOutput, "")
+	finished, _ := readUntilDelimiters(stdout, stderr, TEST_DELIMITER,
+		time.After(5*time.Second), stdoutData, stderrData)
+	if !finished {
+		t.Fatalf("The delimiters were not both read")
+	}
+
+	if got := len(stderrData.finish().Lines); got != 4*PERSISTENT_LINE_BUFFER {
+		t.Errorf("Read %d lines of stderr, want %d", got,
+			4*PERSISTENT_LINE_BUFFER)
+	}
+	if got := stdoutData.finish().Lines; !reflect.DeepEqual(got,
+		[]string{"a"}) {
+		t.Errorf("stdout = %q, want [\"a\"]", got)
+	}
+}
+
+// A harness that runs the delimiter protocol, and kills itself with SIGSEGV
+// when fed a test case reading "crash"
+const TEST_HARNESS = `read delim
+while read line; do
+	if [ "$line" = "$delim" ]; then
+		echo "$delim"
+		echo "$delim" >&2
+	elif [ "$line" = crash ]; then
+		kill -SEGV $$
+	fi
+done
+`
+
+// An interpreter killed by a signal reports the exit code a shell would
+func TestPersistentSignal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "persistent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	harness := filepath.Join(dir, "harness.sh")
+	if err := ioutil.WriteFile(harness, []byte(TEST_HARNESS),
+		0755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(name string, content string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	crash := write("crash.txt", "crash\n")
+	clean := write("clean.txt", "clean\n")
+
+	cfg := &config.Config{}
+	cfg.Interpreter.Path = "/bin/sh"
+	cfg.Interpreter.Args = harness
+	cfg.Interpreter.Timeout.Duration = 10 * time.Second
+	cfg.Persistent.Delimiter = TEST_DELIMITER
+	cfg.Output.NoSpill = true
+	m := &Persistent{S: &session.Session{Config: cfg, TestCasesDir: dir},
+		L: &logging.Logs{}}
+
+	in := make(chan data.TestCase, 3)
+	out := make(chan data.TestCase, 3)
+	errOut := make(chan error, 3)
+	for _, path := range []string{crash, clean} {
+		testCase := data.NewTestCase()
+		testCase.FuzzFilePath = path
+		testCase.SeedFilePaths = []string{path}
+		in <- testCase
+	}
+	in <- data.TestCase{}
+	m.Run(in, out, errOut)
+
+	if len(errOut) != 0 {
+		t.Fatal(<-errOut)
+	}
+	// The interpreter is restarted after the crash
+	want := []int{128 + int(syscall.SIGSEGV), 0}
+	for i, wantCode := range want {
+		testCase := <-out
+		if testCase.TestTimedOut || testCase.ExitCode != wantCode {
+			t.Errorf("Test %d: timed out %v, exit code %d, want %d", i,
+				testCase.TestTimedOut, testCase.ExitCode, wantCode)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package monitor
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// Indices of fields of /proc/<pid>/stat, counting from the field after
+	// the command name
+	STAT_FIELD_PGRP  = 2
+	STAT_FIELD_UTIME = 11
+	STAT_FIELD_STIME = 12
+
+	// The units of the CPU times in /proc/<pid>/stat. This is USER_HZ, which
+	// is 100 on every Linux platform we run on.
+	CLOCK_TICKS = 100
+)
+
+// The process groups of interpreters that have been started and not yet
+// killed or reaped
+var liveGroups = struct {
+	sync.Mutex
+	pgids map[int]bool
+}{pgids: make(map[int]bool)}
+
+// newProcAttr returns the process attributes used for every interpreter
+// that is run. The interpreter is placed in its own process group, so that
+// it and any helper processes it spawns can be killed as a unit. Pdeathsig
+// ensures the interpreter is killed should mfuzz itself die, but it does
+// not reach the helpers, and so the group must also be passed to
+// trackProcessGroup once the interpreter is started.
+func newProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{
+		Setpgid:   true,
+		Pdeathsig: syscall.SIGKILL,
+	}
+}
+
+// trackProcessGroup records that the process group pgid is alive, so that
+// KillProcessGroups kills it should mfuzz exit before it is killed or
+// reaped
+func trackProcessGroup(pgid int) {
+	liveGroups.Lock()
+	defer liveGroups.Unlock()
+
+	liveGroups.pgids[pgid] = true
+}
+
+// untrackProcessGroup records that the process group pgid is gone
+func untrackProcessGroup(pgid int) {
+	liveGroups.Lock()
+	defer liveGroups.Unlock()
+
+	delete(liveGroups.pgids, pgid)
+}
+
+// KillProcessGroups kills every process group started for an interpreter
+// that has not yet been killed or reaped. It must be called before mfuzz
+// exits, so that no helper processes outlive it.
+func KillProcessGroups() {
+	liveGroups.Lock()
+	defer liveGroups.Unlock()
+
+	for pgid := range liveGroups.pgids {
+		if err := syscall.Kill(-pgid, syscall.SIGKILL); err != nil &&
+			err != syscall.ESRCH {
+			log.Printf("Could not kill process group %d: %s", pgid, err)
+		}
+		delete(liveGroups.pgids, pgid)
+	}
+}
+
+// KillProcessGroupsOnSignal makes mfuzz call KillProcessGroups and then
+// exit when it receives SIGINT or SIGTERM
+func KillProcessGroupsOnSignal() {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		sig := <-sigs
+		log.Printf("Received %s, killing the running interpreters", sig)
+		KillProcessGroups()
+		os.Exit(128 + int(sig.(syscall.Signal)))
+	}()
+}
+
+// killProcessGroup sends SIGKILL to every process in the process group
+// pgid
+func killProcessGroup(pgid int) error {
+	untrackProcessGroup(pgid)
+	return syscall.Kill(-pgid, syscall.SIGKILL)
+}
+
+// forEachInGroup calls fn with the fields of /proc/<pid>/stat that follow
+// the command name, for every process currently alive in the process group
+// pgid. It works by scanning /proc, and so processes that have moved
+// themselves into a new process group or session will not be seen.
+func forEachInGroup(pgid int, fn func(fields [][]byte)) {
+	entries, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		if _, err := strconv.Atoi(entry.Name()); err != nil {
+			continue
+		}
+
+		statData, err := ioutil.ReadFile(filepath.Join("/proc", entry.Name(),
+			"stat"))
+		if err != nil {
+			continue
+		}
+
+		// The command name is wrapped in brackets and may itself contain
+		// spaces, so we only look at what comes after it. The fields
+		// following it are the state, the parent PID, and then the process
+		// group ID.
+		idx := bytes.LastIndexByte(statData, ')')
+		if idx == -1 {
+			continue
+		}
+
+		fields := bytes.Fields(statData[idx+1:])
+		if len(fields) <= STAT_FIELD_STIME {
+			continue
+		}
+
+		pgrp, err := strconv.Atoi(string(fields[STAT_FIELD_PGRP]))
+		if err == nil && pgrp == pgid {
+			fn(fields)
+		}
+	}
+}
+
+// countProcessGroup returns the number of processes currently alive in the
+// process group pgid. /proc is only scanned if the group still exists, which
+// for most tests it does not.
+func countProcessGroup(pgid int) int {
+	if err := syscall.Kill(-pgid, 0); err == syscall.ESRCH {
+		return 0
+	}
+
+	count := 0
+	forEachInGroup(pgid, func(fields [][]byte) {
+		count++
+	})
+
+	return count
+}
+
+// countHelpers returns the number of processes other than the interpreter
+// alive in the process group pgid, of which the interpreter is the leader
+// and is still running
+func countHelpers(pgid int) int {
+	if count := countProcessGroup(pgid); count > 1 {
+		return count - 1
+	}
+
+	return 0
+}
+
+// groupCPUTime returns the CPU time, user and system, consumed so far by
+// the processes currently alive in the process group pgid
+func groupCPUTime(pgid int) time.Duration {
+	var ticks uint64
+	forEachInGroup(pgid, func(fields [][]byte) {
+		for _, idx := range []int{STAT_FIELD_UTIME, STAT_FIELD_STIME} {
+			if t, err := strconv.ParseUint(string(fields[idx]), 10,
+				64); err == nil {
+				ticks += t
+			}
+		}
+	})
+
+	return time.Duration(ticks) * time.Second / CLOCK_TICKS
+}
+
+// reapProcessGroup kills any processes left behind in the process group
+// pgid once the group leader has exited. It returns the number of processes
+// that had to be killed.
+func reapProcessGroup(pgid int) int {
+	untrackProcessGroup(pgid)
+	leaked := countProcessGroup(pgid)
+	if leaked == 0 {
+		return 0
+	}
+
+	if err := killProcessGroup(pgid); err != nil && err != syscall.ESRCH {
+		log.Printf("Could not kill process group %d: %s", pgid, err)
+	}
+
+	return leaked
+}
@@ -0,0 +1,173 @@
+package monitor
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/SeanHeelan/Malamute/data"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// The environment variable that makes TestKillProcessGroupsOnSignal act as
+// the parent that is killed. It holds the path the PID of the helper is
+// written to.
+const TEST_PARENT_ENV = "MALAMUTE_TEST_PARENT"
+
+// startGroup starts a shell in its own process group that runs helpers
+// background sleeps, and waits until they are all alive
+func startGroup(t *testing.T, helpers int) *exec.Cmd {
+	script := ""
+	for i := 0; i < helpers; i++ {
+		script += "sleep 5 & "
+	}
+	script += "sleep 5"
+
+	cmd := exec.Command("sh", "-c", script)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Could not start the process group: %s", err)
+	}
+
+	pgid := cmd.Process.Pid
+	deadline := time.Now().Add(2 * time.Second)
+	for countProcessGroup(pgid) < helpers+2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return cmd
+}
+
+func TestCountProcessGroupMissing(t *testing.T) {
+	cmd := exec.Command("true")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Could not run true: %s", err)
+	}
+
+	if count := countProcessGroup(cmd.Process.Pid); count != 0 {
+		t.Errorf("countProcessGroup of an exited group = %d, want 0", count)
+	}
+	if count := countHelpers(cmd.Process.Pid); count != 0 {
+		t.Errorf("countHelpers of an exited group = %d, want 0", count)
+	}
+	if count := reapProcessGroup(cmd.Process.Pid); count != 0 {
+		t.Errorf("reapProcessGroup of an exited group = %d, want 0", count)
+	}
+}
+
+func TestCountHelpers(t *testing.T) {
+	tests := []struct {
+		helpers int
+		// want counts the background sleeps and the foreground sleep,
+		// but not the shell that leads the group
+		want int
+	}{
+		{0, 1},
+		{1, 2},
+		{3, 4},
+	}
+
+	for _, test := range tests {
+		cmd := startGroup(t, test.helpers)
+		pgid := cmd.Process.Pid
+
+		if got := countHelpers(pgid); got != test.want {
+			t.Errorf("countHelpers with %d helpers = %d, want %d",
+				test.helpers, got, test.want)
+		}
+		if got := reapProcessGroup(pgid); got != test.want+1 {
+			t.Errorf("reapProcessGroup with %d helpers = %d, want %d",
+				test.helpers, got, test.want+1)
+		}
+		cmd.Wait()
+	}
+}
+
+// processAlive returns whether the process pid exists and has not exited
+func processAlive(pid int) bool {
+	statData, err := ioutil.ReadFile(filepath.Join("/proc",
+		strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return false
+	}
+
+	idx := bytes.LastIndexByte(statData, ')')
+	fields := bytes.Fields(statData[idx+1:])
+	return len(fields) != 0 && string(fields[0]) != "Z"
+}
+
+// runTestParent runs an interpreter that starts a helper, whose PID is
+// written to pidPath, and waits to be killed
+func runTestParent(pidPath string) {
+	KillProcessGroupsOnSignal()
+
+	scriptPath := pidPath + ".sh"
+	script := fmt.Sprintf("sleep 60 &\necho $! > %s.tmp\n"+
+		"mv %s.tmp %s\nwait\n", pidPath, pidPath, pidPath)
+	if err := ioutil.WriteFile(scriptPath, []byte(script), 0644); err != nil {
+		os.Exit(1)
+	}
+
+	testCase := data.NewTestCase()
+	testCase.FuzzFilePath = scriptPath
+	shellTarget().Execute(&testCase, RunOptions{Timeout: time.Minute})
+	os.Exit(1)
+}
+
+// Killing mfuzz must also kill the helpers its interpreters started, which
+// Pdeathsig does not reach
+func TestKillProcessGroupsOnSignal(t *testing.T) {
+	if pidPath := os.Getenv(TEST_PARENT_ENV); len(pidPath) != 0 {
+		runTestParent(pidPath)
+		return
+	}
+
+	dir, err := ioutil.TempDir("", "procgroup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	pidPath := filepath.Join(dir, "helper.pid")
+
+	parent := exec.Command(os.Args[0],
+		"-test.run=^TestKillProcessGroupsOnSignal$")
+	parent.Env = append(os.Environ(), TEST_PARENT_ENV+"="+pidPath)
+	if err := parent.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	helper := 0
+	deadline := time.Now().Add(10 * time.Second)
+	for helper == 0 && time.Now().Before(deadline) {
+		pidData, err := ioutil.ReadFile(pidPath)
+		if err == nil {
+			helper, _ = strconv.Atoi(strings.TrimSpace(string(pidData)))
+		} else {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	if helper == 0 {
+		parent.Process.Kill()
+		parent.Wait()
+		t.Fatal("The helper was not started")
+	}
+
+	parent.Process.Signal(syscall.SIGTERM)
+	parent.Wait()
+
+	deadline = time.Now().Add(5 * time.Second)
+	for processAlive(helper) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if processAlive(helper) {
+		syscall.Kill(helper, syscall.SIGKILL)
+		t.Errorf("The helper %d outlived the parent", helper)
+	}
+}
@@ -0,0 +1,62 @@
+package monitor
+
+import (
+	"github.com/SeanHeelan/Malamute/data"
+	"github.com/SeanHeelan/Malamute/session"
+	"time"
+)
+
+const (
+	// The test used far more CPU time than its seed
+	RESOURCE_CPU = "cpu"
+	// The test used far more memory than its seed
+	RESOURCE_MEMORY = "memory"
+)
+
+// checkResources compares the resources used by testCase against those
+// used by its seeds during the pre-flight run, and records an anomaly if
+// it exceeds the limits configured in the Resources section
+func checkResources(s *session.Session, testCase *data.TestCase) {
+	cfg := s.Config.Resources
+	if testCase.TestTimedOut ||
+		(cfg.CPUMultiplier == 0 && cfg.MemoryMultiplier == 0) {
+		return
+	}
+
+	// Tests generated from several seeds are compared against the most
+	// demanding of them
+	var baseCPU time.Duration
+	var baseRSS int64
+	found := false
+	for _, seed := range testCase.SeedFilePaths {
+		result, ok := s.Seeds[seed]
+		if !ok || result.Quarantined {
+			continue
+		}
+
+		found = true
+		if result.CPUTime > baseCPU {
+			baseCPU = result.CPUTime
+		}
+		if result.MaxRSS > baseRSS {
+			baseRSS = result.MaxRSS
+		}
+	}
+
+	if !found {
+		return
+	}
+
+	cpu := testCase.UserTime + testCase.SysTime
+	if cfg.CPUMultiplier != 0 && cpu >= cfg.MinCPU.Duration &&
+		cpu > baseCPU*time.Duration(cfg.CPUMultiplier) {
+		testCase.ResourceAnomaly = RESOURCE_CPU
+		return
+	}
+
+	if cfg.MemoryMultiplier != 0 &&
+		testCase.MaxRSS >= int64(cfg.MinMemoryMb)*1024 &&
+		testCase.MaxRSS > baseRSS*int64(cfg.MemoryMultiplier) {
+		testCase.ResourceAnomaly = RESOURCE_MEMORY
+	}
+}
@@ -0,0 +1,77 @@
+package monitor
+
+import (
+	"github.com/SeanHeelan/Malamute/config"
+	"github.com/SeanHeelan/Malamute/data"
+	"github.com/SeanHeelan/Malamute/session"
+	"testing"
+	"time"
+)
+
+func TestCheckResources(t *testing.T) {
+	seeds := map[string]*session.SeedResult{
+		"small.php": {CPUTime: 100 * time.Millisecond, MaxRSS: 10240},
+		"large.php": {CPUTime: time.Second, MaxRSS: 102400},
+		"quarantined.php": {Quarantined: true,
+			CPUTime: time.Millisecond, MaxRSS: 1},
+	}
+
+	tests := []struct {
+		name     string
+		seeds    []string
+		cpu      time.Duration
+		rss      int64
+		timedOut bool
+		disabled bool
+		want     string
+	}{
+		{name: "normal", seeds: []string{"small.php"},
+			cpu: 200 * time.Millisecond, rss: 20480},
+		{name: "slow", seeds: []string{"small.php"},
+			cpu: 2 * time.Second, rss: 10240, want: RESOURCE_CPU},
+		{name: "large", seeds: []string{"small.php"},
+			cpu: 100 * time.Millisecond, rss: 204800,
+			want: RESOURCE_MEMORY},
+		{name: "slow and large", seeds: []string{"small.php"},
+			cpu: 2 * time.Second, rss: 204800, want: RESOURCE_CPU},
+		// Compared against the most demanding seed
+		{name: "several seeds", seeds: []string{"small.php", "large.php"},
+			cpu: 2 * time.Second, rss: 204800},
+		// Below the minimums nothing is reported, however small the seed
+		{name: "below minimum cpu", seeds: []string{"small.php"},
+			cpu: 600 * time.Millisecond},
+		{name: "below minimum memory", seeds: []string{"small.php"},
+			rss: 60000},
+		{name: "quarantined seed", seeds: []string{"quarantined.php"},
+			cpu: 10 * time.Second, rss: 1024000},
+		{name: "unknown seed", seeds: []string{"new.php"},
+			cpu: 10 * time.Second, rss: 1024000},
+		{name: "timed out", seeds: []string{"small.php"},
+			cpu: 10 * time.Second, timedOut: true},
+		{name: "disabled", seeds: []string{"small.php"},
+			cpu: 10 * time.Second, rss: 1024000, disabled: true},
+	}
+
+	for _, test := range tests {
+		s := &session.Session{Config: &config.Config{}, Seeds: seeds}
+		if !test.disabled {
+			s.Config.Resources.CPUMultiplier = 5
+			s.Config.Resources.MinCPU.Duration = time.Second
+			s.Config.Resources.MemoryMultiplier = 5
+			s.Config.Resources.MinMemoryMb = 64
+		}
+
+		testCase := data.TestCase{
+			SeedFilePaths: test.seeds,
+			UserTime:      test.cpu / 2,
+			SysTime:       test.cpu / 2,
+			MaxRSS:        test.rss,
+			TestTimedOut:  test.timedOut,
+		}
+		checkResources(s, &testCase)
+		if testCase.ResourceAnomaly != test.want {
+			t.Errorf("%s: ResourceAnomaly = %q, want %q", test.name,
+				testCase.ResourceAnomaly, test.want)
+		}
+	}
+}
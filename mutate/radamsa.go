@@ -3,6 +3,7 @@ package mutate
 import (
 	"errors"
 	"fmt"
+	"github.com/SeanHeelan/Malamute/config"
 	"github.com/SeanHeelan/Malamute/data"
 	"github.com/SeanHeelan/Malamute/logging"
 	"github.com/SeanHeelan/Malamute/session"
@@ -70,6 +71,10 @@ func (r *Radamsa) Run(in chan Request, out chan data.TestCase,
 			cmdList = append(cmdList, "-m")
 			cmdList = append(cmdList, cfg.Radamsa.Mutations)
 		}
+		if cfg.TestProcessing.Mode == config.RUNMODE_EXPLORE_CRASHES {
+			cmdList = append(cmdList, "-p")
+			cmdList = append(cmdList, cfg.ExploreCrashes.Patterns)
+		}
 		cmdList = append(cmdList, "--seed")
 		cmdList = append(cmdList, seedStr)
 		cmdList = append(cmdList, "-n")
@@ -183,6 +188,10 @@ func (r *RadamsaMultiFile) Run(in chan Request, out chan data.TestCase,
 			cmdList = append(cmdList, "-m")
 			cmdList = append(cmdList, cfg.Radamsa.Mutations)
 		}
+		if cfg.TestProcessing.Mode == config.RUNMODE_EXPLORE_CRASHES {
+			cmdList = append(cmdList, "-p")
+			cmdList = append(cmdList, cfg.ExploreCrashes.Patterns)
+		}
 		cmdList = append(cmdList, "--seed")
 		cmdList = append(cmdList, seedStr)
 		cmdList = append(cmdList, "-n")
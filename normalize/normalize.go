@@ -0,0 +1,200 @@
+package normalize
+
+import (
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	PRESET_SPIDERMONKEY = "spidermonkey"
+	PRESET_V8           = "v8"
+	PRESET_PHP          = "php"
+
+	// REPLACE_SEPARATOR separates the pattern of a replacement rule from
+	// the text it is replaced with
+	REPLACE_SEPARATOR = " => "
+)
+
+// Rules that are always applied, whatever the preset. Addresses and process
+// IDs differ from one run to the next and are never interesting.
+var commonReplace = []string{
+	`0x[0-9a-fA-F]+ => 0xADDR`,
+	`==[0-9]+== => ==PID==`,
+}
+
+var presetReplace = map[string][]string{
+	PRESET_SPIDERMONKEY: []string{
+		`\b[0-9]+(\.[0-9]+)? ?ms\b => Nms`,
+	},
+	PRESET_V8: []string{
+		`\b[0-9]+(\.[0-9]+)? ?ms\b => Nms`,
+		`<JS(Function|Object|Array)[^>]*> => <JS$1>`,
+	},
+	PRESET_PHP: []string{
+		`Resource id #[0-9]+ => Resource id #N`,
+		`(object\([^)]*\))#[0-9]+ => $1#N`,
+		`\b[0-9a-f]{32}\b => HASH`,
+		`in \S+ on line => in FILE on line`,
+	},
+}
+
+var presetDrop = map[string][]string{
+	PRESET_SPIDERMONKEY: []string{
+		`^[Ww]arning: `,
+	},
+	PRESET_V8: []string{
+		`^\[(marking|compiling|optimizing|completed|deoptimizing|bailout)`,
+		`^Concurrent recompilation`,
+	},
+	PRESET_PHP: []string{
+		`^PHP Warning:  Module .* already loaded`,
+		`^Xdebug: `,
+	},
+}
+
+// floatPattern matches decimal numbers with a fractional part
+var floatPattern = regexp.MustCompile(`[-+]?[0-9]+\.[0-9]+([eE][-+]?[0-9]+)?`)
+
+// Replacement replaces every match of Pattern within a line with With,
+// which may refer to sub-matches using $1 etc.
+type Replacement struct {
+	Pattern *regexp.Regexp
+	With    string
+}
+
+// Normalizer removes differences from interpreter output that are not
+// interesting, such as addresses, timings and object IDs, so that the
+// output of different runs can be compared or hashed
+type Normalizer struct {
+	Replacements []Replacement
+	// Drops are patterns matching lines that are removed entirely
+	Drops []*regexp.Regexp
+	// FloatPrecision, if not 0, gives the number of significant digits
+	// that decimal numbers are rounded to
+	FloatPrecision int
+}
+
+// IsValidPreset indicates if preset names one of the built-in rule sets
+func IsValidPreset(preset string) bool {
+	_, ok := presetReplace[preset]
+	return ok
+}
+
+// parseReplacement parses a rule of the form <pattern> => <replacement>
+func parseReplacement(rule string) (Replacement, error) {
+	parts := strings.SplitN(rule, REPLACE_SEPARATOR, 2)
+	if len(parts) != 2 {
+		msg := fmt.Sprintf("Replacement rule '%s' must be of the form "+
+			"<pattern>%s<replacement>", rule, REPLACE_SEPARATOR)
+		return Replacement{}, errors.New(msg)
+	}
+
+	pattern, err := regexp.Compile(parts[0])
+	if err != nil {
+		msg := fmt.Sprintf("Invalid pattern in replacement rule '%s': %s",
+			rule, err)
+		return Replacement{}, errors.New(msg)
+	}
+
+	return Replacement{pattern, parts[1]}, nil
+}
+
+// New creates a Normalizer from the common rules and those of preset, which
+// may be empty, followed by the replacement rules in replace and the line
+// patterns in drop
+func New(preset string, replace []string, drop []string,
+	floatPrecision int) (*Normalizer, error) {
+
+	if floatPrecision < 0 {
+		return nil, errors.New("The float precision cannot be negative")
+	}
+	n := Normalizer{FloatPrecision: floatPrecision}
+
+	allReplace := append([]string{}, commonReplace...)
+	allDrop := []string{}
+	if len(preset) != 0 {
+		if !IsValidPreset(preset) {
+			msg := fmt.Sprintf("Unknown normalization preset : %s", preset)
+			return nil, errors.New(msg)
+		}
+		allReplace = append(allReplace, presetReplace[preset]...)
+		allDrop = append(allDrop, presetDrop[preset]...)
+	}
+	allReplace = append(allReplace, replace...)
+	allDrop = append(allDrop, drop...)
+
+	for _, rule := range allReplace {
+		r, err := parseReplacement(rule)
+		if err != nil {
+			return nil, err
+		}
+		n.Replacements = append(n.Replacements, r)
+	}
+
+	for _, rule := range allDrop {
+		pattern, err := regexp.Compile(rule)
+		if err != nil {
+			msg := fmt.Sprintf("Invalid line filter '%s': %s", rule, err)
+			return nil, errors.New(msg)
+		}
+		n.Drops = append(n.Drops, pattern)
+	}
+
+	return &n, nil
+}
+
+// roundFloat rounds the decimal number s to the configured precision
+func (n *Normalizer) roundFloat(s string) string {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return s
+	}
+
+	return strconv.FormatFloat(f, 'g', n.FloatPrecision, 64)
+}
+
+// Lines returns a normalized copy of lines. Trailing whitespace and
+// trailing blank lines are always removed.
+func (n *Normalizer) Lines(lines []string) []string {
+	normalized := []string{}
+
+LineLoop:
+	for _, line := range lines {
+		for _, drop := range n.Drops {
+			if drop.MatchString(line) {
+				continue LineLoop
+			}
+		}
+
+		for _, r := range n.Replacements {
+			line = r.Pattern.ReplaceAllString(line, r.With)
+		}
+
+		if n.FloatPrecision != 0 {
+			line = floatPattern.ReplaceAllStringFunc(line, n.roundFloat)
+		}
+
+		normalized = append(normalized, strings.TrimRight(line, " \t\r"))
+	}
+
+	for len(normalized) != 0 && len(normalized[len(normalized)-1]) == 0 {
+		normalized = normalized[:len(normalized)-1]
+	}
+
+	return normalized
+}
+
+// Hash returns the hex encoded SHA1 of the normalized form of lines
+func (n *Normalizer) Hash(lines []string) string {
+	h := sha1.New()
+	for _, line := range n.Lines(lines) {
+		h.Write([]byte(line))
+		h.Write([]byte("\n"))
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
@@ -0,0 +1,123 @@
+package normalize
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name      string
+		preset    string
+		replace   []string
+		drop      []string
+		precision int
+		lines     []string
+		want      []string
+	}{
+		{
+			name:  "common rules without a preset",
+			lines: []string{"ptr 0x7ffd1234abcd", "==4321==ERROR"},
+			want:  []string{"ptr 0xADDR", "==PID==ERROR"},
+		},
+		{
+			name:   "common rules with a preset",
+			preset: PRESET_PHP,
+			lines:  []string{"object(Foo)#12 at 0xdeadbeef"},
+			want:   []string{"object(Foo)#N at 0xADDR"},
+		},
+		{
+			name:   "php preset",
+			preset: PRESET_PHP,
+			lines: []string{"Resource id #5",
+				"Warning: oops in /tmp/t.php on line 3",
+				"Xdebug: loaded",
+				"d41d8cd98f00b204e9800998ecf8427e"},
+			want: []string{"Resource id #N",
+				"Warning: oops in FILE on line 3", "HASH"},
+		},
+		{
+			name:   "v8 preset",
+			preset: PRESET_V8,
+			lines: []string{"[marking 0x1 <JSFunction f>]",
+				"took 12.5 ms", "<JSObject foo>"},
+			want: []string{"took Nms", "<JSObject>"},
+		},
+		{
+			name:    "user rules after the preset",
+			preset:  PRESET_SPIDERMONKEY,
+			replace: []string{`Nms => TIME`},
+			drop:    []string{`^debug`},
+			lines:   []string{"5ms", "debug: x", "warning: y", "z"},
+			want:    []string{"TIME", "z"},
+		},
+		{
+			name:      "float precision",
+			precision: 3,
+			lines:     []string{"1.23456 -0.000123456 7"},
+			want:      []string{"1.23 -0.000123 7"},
+		},
+		{
+			name:  "trailing whitespace and blank lines",
+			lines: []string{"a \t", "", "b\r", "", "  "},
+			want:  []string{"a", "", "b"},
+		},
+		{
+			name:  "no lines",
+			lines: []string{},
+			want:  []string{},
+		},
+	}
+
+	for _, test := range tests {
+		n, err := New(test.preset, test.replace, test.drop, test.precision)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+
+		if got := n.Lines(test.lines); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: Lines = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		preset    string
+		replace   []string
+		drop      []string
+		precision int
+	}{
+		{name: "unknown preset", preset: "ruby"},
+		{name: "no separator", replace: []string{"abc"}},
+		{name: "bad replace pattern", replace: []string{"( => x"}},
+		{name: "bad drop pattern", drop: []string{"["}},
+		{name: "negative precision", precision: -1},
+	}
+
+	for _, test := range tests {
+		if _, err := New(test.preset, test.replace, test.drop,
+			test.precision); err == nil {
+			t.Errorf("%s: no error", test.name)
+		}
+	}
+}
+
+func TestHash(t *testing.T) {
+	n, err := New("", nil, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := n.Hash([]string{"crash at 0x1234", ""})
+	b := n.Hash([]string{"crash at 0xabcd  "})
+	c := n.Hash([]string{"crash at main"})
+	if a != b {
+		t.Errorf("Outputs differing only in addresses hash differently")
+	}
+	if a == c {
+		t.Errorf("Different outputs hash the same")
+	}
+}
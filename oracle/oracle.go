@@ -0,0 +1,272 @@
+package oracle
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/SeanHeelan/Malamute/normalize"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	PHPT_EXT = ".phpt"
+	PHP_EXT  = ".php"
+	JS_EXT   = ".js"
+
+	PHPT_SECTION_EXPECT      = "EXPECT"
+	PHPT_SECTION_EXPECTF     = "EXPECTF"
+	PHPT_SECTION_EXPECTREGEX = "EXPECTREGEX"
+
+	JIT_TEST_MARKER = "|jit-test|"
+	// The exit code of the SpiderMonkey shell when a script throws an
+	// uncaught exception
+	JIT_TEST_ERROR_EXITCODE = 3
+)
+
+// phptSection matches the header of a section of a .phpt file, e.g. --FILE--
+var phptSection = regexp.MustCompile(`^--([A-Z_]+)--\s*$`)
+
+// Expectation describes the result that running a seed is expected to have.
+// Each field that is set must be satisfied for a run to match.
+type Expectation struct {
+	// Source is the path of the file the expectation was read from
+	Source string
+	// Stdout, if not nil, is the exact output expected on stdout
+	Stdout []string
+	// StdoutPattern, if not nil, must match the whole of stdout
+	StdoutPattern *regexp.Regexp
+	// Error, if not empty, must appear in the output on stderr
+	Error string
+	// ExitCode, if CheckExitCode is set, is the expected exit code
+	ExitCode      int
+	CheckExitCode bool
+}
+
+// Load reads the expected result of running the seed at seedPath. For PHP
+// tests this is taken from the expected output section of the .phpt file
+// alongside the seed with the same name, as left by run-tests.php. A .phpt
+// file is not a script, and so never has an expectation itself. For
+// SpiderMonkey jit-tests it is taken from the |jit-test| header. If the seed
+// has no known expectation then nil is returned.
+func Load(seedPath string) (*Expectation, error) {
+	switch filepath.Ext(seedPath) {
+	case PHP_EXT:
+		phptPath := strings.TrimSuffix(seedPath, PHP_EXT) + PHPT_EXT
+		if _, err := os.Stat(phptPath); err != nil {
+			return nil, nil
+		}
+		return loadPhpt(phptPath)
+	case JS_EXT:
+		return loadJitTest(seedPath)
+	}
+
+	return nil, nil
+}
+
+// phptFormat translates the format specifiers used by --EXPECTF-- sections
+// into regular expressions
+var phptFormat = map[byte]string{
+	'e': `[\\/]`,
+	's': `[^\r\n]+`,
+	'S': `[^\r\n]*`,
+	'a': `.+`,
+	'A': `.*`,
+	'w': `\s*`,
+	'i': `[+-]?\d+`,
+	'd': `\d+`,
+	'x': `[0-9a-fA-F]+`,
+	'f': `[+-]?\.?\d+\.?\d*(?:[Ee][+-]?\d+)?`,
+	'c': `.`,
+	'0': `\x00`,
+}
+
+// compileExpectf converts the body of an --EXPECTF-- section into a regular
+// expression matching the whole output, without surrounding whitespace
+func compileExpectf(lines []string) (*regexp.Regexp, error) {
+	format := strings.TrimSpace(strings.Join(lines, "\n"))
+
+	var buf bytes.Buffer
+	buf.WriteString(`(?s)\A`)
+	for i := 0; i < len(format); i++ {
+		if format[i] == '%' && i+1 < len(format) {
+			spec := format[i+1]
+			if spec == 'r' {
+				// %r...%r encloses a raw regular expression
+				end := strings.Index(format[i+2:], "%r")
+				if end != -1 {
+					buf.WriteString("(?:" + format[i+2:i+2+end] + ")")
+					i += end + 3
+					continue
+				}
+			} else if re, ok := phptFormat[spec]; ok {
+				buf.WriteString(re)
+				i++
+				continue
+			}
+		}
+		buf.WriteString(regexp.QuoteMeta(format[i : i+1]))
+	}
+	buf.WriteString(`\z`)
+
+	return regexp.Compile(buf.String())
+}
+
+// loadPhpt reads the expected output section of the .phpt file at path
+func loadPhpt(path string) (*Expectation, error) {
+	fd, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	sections := make(map[string][]string)
+	current := ""
+	scanner := bufio.NewScanner(fd)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if m := phptSection.FindStringSubmatch(line); m != nil {
+			current = m[1]
+			sections[current] = []string{}
+			continue
+		}
+
+		if len(current) != 0 {
+			sections[current] = append(sections[current], line)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	e := Expectation{Source: path}
+	if lines, ok := sections[PHPT_SECTION_EXPECT]; ok {
+		e.Stdout = lines
+	} else if lines, ok := sections[PHPT_SECTION_EXPECTF]; ok {
+		if e.StdoutPattern, err = compileExpectf(lines); err != nil {
+			msg := fmt.Sprintf("Invalid %s section in %s: %s",
+				PHPT_SECTION_EXPECTF, path, err)
+			return nil, errors.New(msg)
+		}
+	} else if lines, ok := sections[PHPT_SECTION_EXPECTREGEX]; ok {
+		pattern := `(?s)\A(?:` + strings.TrimSpace(strings.Join(lines,
+			"\n")) + `)\z`
+		if e.StdoutPattern, err = regexp.Compile(pattern); err != nil {
+			msg := fmt.Sprintf("Invalid %s section in %s: %s",
+				PHPT_SECTION_EXPECTREGEX, path, err)
+			return nil, errors.New(msg)
+		}
+	} else {
+		return nil, nil
+	}
+
+	return &e, nil
+}
+
+// loadJitTest reads the |jit-test| header from the first line of the
+// jit-test at path, e.g.
+// // |jit-test| error: TypeError; --ion-eager
+func loadJitTest(path string) (*Expectation, error) {
+	fileData, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	firstLine := string(fileData)
+	if idx := strings.IndexByte(firstLine, '\n'); idx != -1 {
+		firstLine = firstLine[:idx]
+	}
+
+	idx := strings.Index(firstLine, JIT_TEST_MARKER)
+	if idx == -1 {
+		return nil, nil
+	}
+
+	e := Expectation{Source: path, CheckExitCode: true}
+	attrs := strings.Split(firstLine[idx+len(JIT_TEST_MARKER):], ";")
+	for _, attr := range attrs {
+		parts := strings.SplitN(attr, ":", 2)
+		name := strings.TrimSpace(parts[0])
+		value := ""
+		if len(parts) == 2 {
+			value = strings.TrimSpace(parts[1])
+		}
+
+		switch name {
+		case "error":
+			e.Error = value
+			e.ExitCode = JIT_TEST_ERROR_EXITCODE
+		case "exitstatus":
+			if e.ExitCode, err = strconv.Atoi(value); err != nil {
+				msg := fmt.Sprintf("Invalid exit status '%s' in %s", value,
+					path)
+				return nil, errors.New(msg)
+			}
+		case "crash", "allow-oom", "allow-overrecursed",
+			"allow-unhandlable-oom", "skip-if":
+			// The result of these tests can't be relied upon
+			return nil, nil
+		}
+	}
+
+	return &e, nil
+}
+
+// Check compares the result of a run against the expectation. Literal
+// output is normalized by n on both sides before it is compared, while
+// patterns are matched against the raw output, as they are written to
+// match the values normalization replaces. An empty string is returned if
+// the result matches, otherwise a description of the mismatch.
+func (e *Expectation) Check(stdout []string, stderr []string, exitCode int,
+	n *normalize.Normalizer) string {
+
+	if e.CheckExitCode && exitCode != e.ExitCode {
+		return fmt.Sprintf("Expected exit code %d, got %d", e.ExitCode,
+			exitCode)
+	}
+
+	if len(e.Error) != 0 {
+		found := false
+		for _, line := range stderr {
+			if strings.Contains(line, e.Error) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return fmt.Sprintf("Expected error '%s' was not reported",
+				e.Error)
+		}
+	}
+
+	if e.Stdout != nil {
+		actual := n.Lines(stdout)
+		expected := n.Lines(e.Stdout)
+		if len(expected) != len(actual) {
+			return fmt.Sprintf("Expected %d lines of output, got %d",
+				len(expected), len(actual))
+		}
+
+		for i := range expected {
+			if expected[i] != actual[i] {
+				return fmt.Sprintf("Output differs at line %d: expected "+
+					"'%s', got '%s'", i+1, expected[i], actual[i])
+			}
+		}
+	}
+
+	// Like run-tests.php, surrounding whitespace is ignored
+	if e.StdoutPattern != nil && !e.StdoutPattern.MatchString(
+		strings.TrimSpace(strings.Join(stdout, "\n"))) {
+		return "Output does not match the expected pattern"
+	}
+
+	return ""
+}
@@ -0,0 +1,290 @@
+package oracle
+
+import (
+	"github.com/SeanHeelan/Malamute/normalize"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompileExpectf(t *testing.T) {
+	tests := []struct {
+		format []string
+		output string
+		want   bool
+	}{
+		{[]string{"int(%d)"}, "int(42)", true},
+		{[]string{"int(%d)"}, "int(-42)", false},
+		{[]string{"int(%i)"}, "int(-42)", true},
+		{[]string{"float(%f)"}, "float(1.5E+3)", true},
+		{[]string{"%s in %s on line %d"}, "Notice in /t.php on line 3",
+			true},
+		{[]string{"a", "%a", "z"}, "a\nb\nc\nz", true},
+		{[]string{"a", "%A", "z"}, "a\n\nz", true},
+		{[]string{"%x"}, "dEaD", true},
+		{[]string{"%r[ab]+%r!"}, "abba!", true},
+		{[]string{"%r[ab]+%r!"}, "abc!", false},
+		// Regular expression syntax in the format is literal
+		{[]string{"a.b"}, "axb", false},
+		{[]string{"a.b"}, "a.b", true},
+		// The whole output must match
+		{[]string{"ok"}, "ok\nmore", false},
+		// An unknown or trailing specifier is literal
+		{[]string{"100%q%"}, "100%q%", true},
+	}
+
+	for _, test := range tests {
+		re, err := compileExpectf(test.format)
+		if err != nil {
+			t.Errorf("compileExpectf(%q): %s", test.format, err)
+			continue
+		}
+
+		if got := re.MatchString(test.output); got != test.want {
+			t.Errorf("%q matching %q = %v, want %v", test.format,
+				test.output, got, test.want)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oracle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"expect.phpt":  "--TEST--\nt\n--FILE--\n<?php\n--EXPECT--\na\nb\n",
+		"expect.php":   "<?php echo \"a\\nb\\n\";\n",
+		"expectf.phpt": "--FILE--\n<?php\n--EXPECTF--\nint(%d)\n",
+		"expectf.php":  "<?php\n",
+		"regex.phpt":   "--FILE--\n<?php\n--EXPECTREGEX--\n[0-9]+\n",
+		"regex.php":    "<?php\n",
+		"none.phpt":    "--TEST--\nt\n--FILE--\n<?php\n",
+		"none.php":     "<?php\n",
+		"alone.php":    "<?php\n",
+		"error.js":     "// |jit-test| error: TypeError; --ion-eager\n",
+		"status.js":    "// |jit-test| exitstatus: 6\n",
+		"crash.js":     "// |jit-test| crash; exitstatus: 6\n",
+		"badstatus.js": "// |jit-test| exitstatus: six\n",
+		"plain.js":     "print(1);\n// |jit-test| error: late\n",
+		"seed.txt":     "text\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name),
+			[]byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		seed     string
+		wantNil  bool
+		wantErr  bool
+		stdout   []string
+		exitCode int
+		match    string
+	}{
+		{seed: "expect.php", stdout: []string{"a", "b"}},
+		{seed: "expectf.php", match: "int(7)"},
+		{seed: "regex.php", match: "123"},
+		{seed: "none.php", wantNil: true},
+		{seed: "alone.php", wantNil: true},
+		// A .phpt seed is not a script
+		{seed: "expect.phpt", wantNil: true},
+		{seed: "error.js", exitCode: JIT_TEST_ERROR_EXITCODE},
+		{seed: "status.js", exitCode: 6},
+		{seed: "crash.js", wantNil: true},
+		{seed: "badstatus.js", wantErr: true},
+		// The header must be on the first line
+		{seed: "plain.js", wantNil: true},
+		{seed: "seed.txt", wantNil: true},
+	}
+
+	for _, test := range tests {
+		e, err := Load(filepath.Join(dir, test.seed))
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: err = %v, want error %v", test.seed, err,
+				test.wantErr)
+			continue
+		}
+		if test.wantErr {
+			continue
+		}
+
+		if (e == nil) != test.wantNil {
+			t.Errorf("%s: expectation = %+v, want nil %v", test.seed, e,
+				test.wantNil)
+			continue
+		}
+		if e == nil {
+			continue
+		}
+
+		if len(test.stdout) != 0 && len(e.Stdout) != len(test.stdout) {
+			t.Errorf("%s: Stdout = %q, want %q", test.seed, e.Stdout,
+				test.stdout)
+		}
+		if e.ExitCode != test.exitCode {
+			t.Errorf("%s: ExitCode = %d, want %d", test.seed, e.ExitCode,
+				test.exitCode)
+		}
+		if len(test.match) != 0 && (e.StdoutPattern == nil ||
+			!e.StdoutPattern.MatchString(test.match)) {
+			t.Errorf("%s: the expected pattern does not match %q",
+				test.seed, test.match)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	n, err := normalize.New("", nil, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pattern, err := compileExpectf([]string{"id %x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		expectation Expectation
+		stdout      []string
+		stderr      []string
+		exitCode    int
+		wantMatch   bool
+	}{
+		{
+			name:        "same output",
+			expectation: Expectation{Stdout: []string{"a", "b"}},
+			stdout:      []string{"a", "b", ""},
+			wantMatch:   true,
+		},
+		{
+			name:        "normalized output",
+			expectation: Expectation{Stdout: []string{"at 0x10"}},
+			stdout:      []string{"at 0x7fff"},
+			wantMatch:   true,
+		},
+		{
+			name:        "different line",
+			expectation: Expectation{Stdout: []string{"a", "b"}},
+			stdout:      []string{"a", "c"},
+		},
+		{
+			name:        "missing line",
+			expectation: Expectation{Stdout: []string{"a", "b"}},
+			stdout:      []string{"a"},
+		},
+		{
+			name:        "pattern",
+			expectation: Expectation{StdoutPattern: pattern},
+			stdout:      []string{"id ff"},
+			wantMatch:   true,
+		},
+		{
+			name:        "pattern mismatch",
+			expectation: Expectation{StdoutPattern: pattern},
+			stdout:      []string{"id"},
+		},
+		{
+			name: "error reported",
+			expectation: Expectation{Error: "TypeError",
+				ExitCode: JIT_TEST_ERROR_EXITCODE, CheckExitCode: true},
+			stderr:    []string{"t.js:1:1 TypeError: x is undefined"},
+			exitCode:  JIT_TEST_ERROR_EXITCODE,
+			wantMatch: true,
+		},
+		{
+			name: "error missing",
+			expectation: Expectation{Error: "TypeError",
+				ExitCode: JIT_TEST_ERROR_EXITCODE, CheckExitCode: true},
+			stderr:   []string{"RangeError"},
+			exitCode: JIT_TEST_ERROR_EXITCODE,
+		},
+		{
+			name:        "wrong exit code",
+			expectation: Expectation{ExitCode: 0, CheckExitCode: true},
+			exitCode:    1,
+		},
+		{
+			name:        "exit code not checked",
+			expectation: Expectation{Stdout: []string{}},
+			exitCode:    255,
+			wantMatch:   true,
+		},
+	}
+
+	for _, test := range tests {
+		mismatch := test.expectation.Check(test.stdout, test.stderr,
+			test.exitCode, n)
+		if got := len(mismatch) == 0; got != test.wantMatch {
+			t.Errorf("%s: Check = %q, want match %v", test.name, mismatch,
+				test.wantMatch)
+		}
+	}
+}
+
+// With the php preset, the values that EXPECTF specifiers match are
+// replaced by normalization, so patterns must see the raw output
+func TestCheckPhpPreset(t *testing.T) {
+	n, err := normalize.New(normalize.PRESET_PHP, nil, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	object, err := compileExpectf([]string{"object(stdClass)#%d (0) {",
+		"}", ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	warning, err := compileExpectf([]string{
+		"Warning: %s in %s on line %d"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		expectation Expectation
+		stdout      []string
+		wantMatch   bool
+	}{
+		{
+			name:        "object id",
+			expectation: Expectation{StdoutPattern: object},
+			stdout:      []string{"object(stdClass)#3 (0) {", "}", ""},
+			wantMatch:   true,
+		},
+		{
+			name:        "other class",
+			expectation: Expectation{StdoutPattern: object},
+			stdout:      []string{"object(Foo)#3 (0) {", "}", ""},
+		},
+		{
+			name:        "file and line",
+			expectation: Expectation{StdoutPattern: warning},
+			stdout: []string{
+				"Warning: oops in /tmp/t.php on line 3"},
+			wantMatch: true,
+		},
+		{
+			// Literal output is normalized on both sides
+			name: "literal object id",
+			expectation: Expectation{
+				Stdout: []string{"object(stdClass)#1 (0) {"}},
+			stdout:    []string{"object(stdClass)#7 (0) {"},
+			wantMatch: true,
+		},
+	}
+
+	for _, test := range tests {
+		mismatch := test.expectation.Check(test.stdout, nil, 0, n)
+		if got := len(mismatch) == 0; got != test.wantMatch {
+			t.Errorf("%s: Check = %q, want match %v", test.name, mismatch,
+				test.wantMatch)
+		}
+	}
+}
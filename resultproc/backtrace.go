@@ -0,0 +1,78 @@
+package resultproc
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+const (
+	BACKTRACE_NAME = "backtrace.txt"
+	CORE_NAME      = "core"
+)
+
+// Matches a frame printed by gdb's bt command, such as
+// "#1  0x000055555555513d in main (argc=2) at js.cpp:5" or, for a frame
+// whose PC is at the start of a line, "#0  foo (a=1) at foo.c:3"
+var gdbFrame = regexp.MustCompile(`^#([0-9]+)\s+(?:0x[0-9a-fA-F]+ in )?(\S+) \(`)
+
+// gdbBacktrace uses gdb to produce a backtrace of every thread from the
+// core file at corePath, which was written by binary. The backtrace of the
+// crashing thread comes first.
+func gdbBacktrace(gdbPath string, binary string,
+	corePath string) ([]string, error) {
+
+	cmd := exec.Command(gdbPath, "-batch", "-nx", "-ex", "bt",
+		"-ex", "thread apply all bt", binary, corePath)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		msg := fmt.Sprintf("Error running %s on %s: %s", gdbPath, corePath,
+			err)
+		return nil, errors.New(msg)
+	}
+
+	lines := []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	return lines, nil
+}
+
+// gdbFrames returns the function names from the top of the first backtrace
+// in lines, skipping frames gdb could not symbolize. nil is returned if
+// there are none.
+func gdbFrames(lines []string) []string {
+	frames := []string{}
+	for _, line := range lines {
+		m := gdbFrame.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+
+		if m[1] == "0" && len(frames) != 0 {
+			// The start of the backtrace of another thread
+			break
+		}
+
+		if m[2] == "??" {
+			continue
+		}
+
+		frames = append(frames, m[2])
+		if len(frames) == BUCKET_STACK_FRAMES {
+			break
+		}
+	}
+
+	if len(frames) == 0 {
+		return nil
+	}
+
+	return frames
+}
@@ -0,0 +1,102 @@
+package resultproc
+
+import (
+	"github.com/SeanHeelan/Malamute/data"
+	"github.com/SeanHeelan/Malamute/normalize"
+	"reflect"
+	"testing"
+)
+
+func TestGdbFrames(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  []string
+	}{
+		{
+			name: "single thread",
+			lines: []string{
+				"Core was generated by `js t.js'.",
+				"Program terminated with signal SIGSEGV, Segmentation fault.",
+				"#0  0x000055555555513d in js::Crash (cx=0x0) at js.cpp:5",
+				"#1  0x000055555555514e in js::Run () at js.cpp:9",
+				"#2  main (argc=2, argv=0x7ffe) at js.cpp:20",
+				"#3  0x00007ffff7829d90 in __libc_start_main () from libc.so.6",
+			},
+			want: []string{"js::Crash", "js::Run", "main"},
+		},
+		{
+			name: "unknown frames",
+			lines: []string{
+				"#0  0x0000000000000000 in ?? ()",
+				"#1  0x0000555555555150 in handler (sig=11) at a.c:3",
+			},
+			want: []string{"handler"},
+		},
+		{
+			// The backtrace of the crashing thread is printed first, and
+			// then again along with every other thread
+			name: "several threads",
+			lines: []string{
+				"#0  crash_here () at a.c:1",
+				"",
+				"Thread 2 (LWP 11):",
+				"#0  0x00007ffff7891117 in poll () from libc.so.6",
+				"#1  0x0000555555555160 in worker () at b.c:1",
+			},
+			want: []string{"crash_here"},
+		},
+		{
+			name:  "no backtrace",
+			lines: []string{"No stack."},
+		},
+		{
+			name:  "only unknown frames",
+			lines: []string{"#0  0x0000000000000000 in ?? ()"},
+		},
+	}
+
+	for _, test := range tests {
+		if got := gdbFrames(test.lines); !reflect.DeepEqual(got,
+			test.want) {
+			t.Errorf("%s: gdbFrames = %q, want %q", test.name, got,
+				test.want)
+		}
+	}
+}
+
+func TestCrashBucketBacktrace(t *testing.T) {
+	n, err := normalize.New("", nil, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backtrace := []string{"#0  0x1 in f () at a.c:1", "#1  0x2 in g () at a.c:2"}
+	tests := []struct {
+		name     string
+		testCase data.TestCase
+		want     string
+	}{
+		{
+			name: "backtrace",
+			testCase: data.TestCase{RunStderr: []string{"Segfault"},
+				Backtrace: backtrace},
+			want: BUCKET_PREFIX_BACKTRACE + "f < g",
+		},
+		{
+			// A stack trace printed by a sanitizer is preferred
+			name: "stack trace and backtrace",
+			testCase: data.TestCase{
+				RunStderr: []string{"#0 0x1 in a /a.c:1"},
+				Backtrace: backtrace},
+			want: BUCKET_PREFIX_STACK + "a",
+		},
+	}
+
+	for _, test := range tests {
+		if got := crashBucket(test.testCase, n); got != test.want {
+			t.Errorf("%s: crashBucket = %q, want %q", test.name, got,
+				test.want)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package resultproc
+
+import (
+	"github.com/SeanHeelan/Malamute/config"
+	"github.com/SeanHeelan/Malamute/data"
+	"github.com/SeanHeelan/Malamute/normalize"
+	"regexp"
+	"strings"
+)
+
+const (
+	// The number of frames from the top of a symbolized stack trace that
+	// identify a crash
+	BUCKET_STACK_FRAMES = 3
+
+	BUCKET_PREFIX_STACK     = "stack:"
+	BUCKET_PREFIX_BACKTRACE = "backtrace:"
+	BUCKET_PREFIX_STDERR    = "stderr:"
+	// The number of hex characters of the stderr hash used in a bucket
+	BUCKET_HASH_LEN = 16
+)
+
+// Matches a symbolized frame from a sanitizer report, such as
+// "#0 0x4f5e2a in js::jit::Foo(JSContext*) /src/js/Foo.cpp:12:3".
+// Unsymbolized frames have no function name, and so don't match.
+var symbolizedFrame = regexp.MustCompile(`^\s*#([0-9]+) 0x[0-9a-fA-F]+ in (.+?)( /\S+| \(\S+\))?$`)
+
+// stackFrames returns the function names from the top of the first
+// symbolized stack trace in lines, or nil if there isn't one
+func stackFrames(lines []string) []string {
+	frames := []string{}
+	for _, line := range lines {
+		m := symbolizedFrame.FindStringSubmatch(line)
+		if m == nil {
+			if len(frames) != 0 {
+				// The end of the first stack trace
+				break
+			}
+			continue
+		}
+
+		frames = append(frames, m[2])
+		if len(frames) == BUCKET_STACK_FRAMES {
+			break
+		}
+	}
+
+	if len(frames) == 0 {
+		return nil
+	}
+
+	return frames
+}
+
+// crashBucket derives a bucket for a crashing test case. If a symbolized
+// stack trace was printed, or its raw frames were symbolized, or failing
+// that gdb produced a backtrace from the core file, then the bucket is made
+// of the functions at the top of it.
+// Otherwise it is a hash of the normalized stderr output.
+func crashBucket(testCase data.TestCase, n *normalize.Normalizer) string {
+	stderr := testCase.RunStderr
+	if testCase.SymbolizedStderr != nil {
+		stderr = testCase.SymbolizedStderr
+	}
+
+	if frames := stackFrames(stderr); frames != nil {
+		return BUCKET_PREFIX_STACK + strings.Join(frames, " < ")
+	}
+
+	if frames := gdbFrames(testCase.Backtrace); frames != nil {
+		return BUCKET_PREFIX_BACKTRACE + strings.Join(frames, " < ")
+	}
+
+	// The path of the fuzz file differs for every test, so it is replaced
+	// before hashing
+	stderr = []string{}
+	for _, line := range testCase.RunStderr {
+		stderr = append(stderr, strings.Replace(line, testCase.FuzzFilePath,
+			config.INTERPRETER_ARGS_FUZZ_FILE_MARKER, -1))
+	}
+
+	return BUCKET_PREFIX_STDERR + n.Hash(stderr)[:BUCKET_HASH_LEN]
+}
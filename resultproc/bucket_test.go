@@ -0,0 +1,124 @@
+package resultproc
+
+import (
+	"github.com/SeanHeelan/Malamute/data"
+	"github.com/SeanHeelan/Malamute/normalize"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStackFrames(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  []string
+	}{
+		{
+			name: "sanitizer report",
+			lines: []string{
+				"==1==ERROR: AddressSanitizer: heap-use-after-free",
+				"    #0 0x4f5e2a in zend_hash_find /src/Zend/zend_hash.c:12:3",
+				"    #1 0x4f5e3b in php_array_merge (/usr/bin/php+0x4f5e3b)",
+				"    #2 0x4f5e4c in execute_ex",
+				"    #3 0x4f5e5d in main /src/sapi/cli/php_cli.c:1",
+			},
+			want: []string{"zend_hash_find", "php_array_merge",
+				"execute_ex"},
+		},
+		{
+			name: "names with spaces",
+			lines: []string{
+				"#0 0x1 in js::jit::Foo(JSContext*, bool) /src/Foo.cpp:1",
+			},
+			want: []string{"js::jit::Foo(JSContext*, bool)"},
+		},
+		{
+			// Only the first trace identifies the crash, not e.g. the
+			// allocation trace that follows it
+			name: "several traces",
+			lines: []string{
+				"#0 0x1 in crash_here /a.c:1",
+				"",
+				"freed by thread T0 here:",
+				"#0 0x2 in free",
+				"#1 0x3 in release_it /b.c:2",
+			},
+			want: []string{"crash_here"},
+		},
+		{
+			name: "unsymbolized",
+			lines: []string{
+				"#0 0x4f5e2a (/usr/bin/php+0x4f5e2a)",
+			},
+		},
+		{
+			name:  "no trace",
+			lines: []string{"Segmentation fault"},
+		},
+	}
+
+	for _, test := range tests {
+		if got := stackFrames(test.lines); !reflect.DeepEqual(got,
+			test.want) {
+			t.Errorf("%s: stackFrames = %q, want %q", test.name, got,
+				test.want)
+		}
+	}
+}
+
+func TestCrashBucket(t *testing.T) {
+	n, err := normalize.New("", nil, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trace := []string{"#0 0x1 in a /a.c:1", "#1 0x2 in b /b.c:2"}
+	stderrA := data.TestCase{FuzzFilePath: "/tmp/1.php",
+		RunStderr: []string{"Fatal error in /tmp/1.php at 0x7f01"}}
+	stderrB := data.TestCase{FuzzFilePath: "/tmp/2.php",
+		RunStderr: []string{"Fatal error in /tmp/2.php at 0x7f02"}}
+	stderrC := data.TestCase{FuzzFilePath: "/tmp/3.php",
+		RunStderr: []string{"Other error"}}
+
+	tests := []struct {
+		name     string
+		testCase data.TestCase
+		want     string
+	}{
+		{
+			name:     "stack",
+			testCase: data.TestCase{RunStderr: trace},
+			want:     BUCKET_PREFIX_STACK + "a < b",
+		},
+		{
+			name: "symbolized stack",
+			testCase: data.TestCase{
+				RunStderr:        []string{"#0 0x1 (/php+0x1)"},
+				SymbolizedStderr: trace},
+			want: BUCKET_PREFIX_STACK + "a < b",
+		},
+		{
+			// The fuzz file path and addresses differ between runs
+			name:     "stderr",
+			testCase: stderrA,
+			want:     crashBucket(stderrB, n),
+		},
+	}
+
+	for _, test := range tests {
+		if got := crashBucket(test.testCase, n); got != test.want {
+			t.Errorf("%s: crashBucket = %q, want %q", test.name, got,
+				test.want)
+		}
+	}
+
+	bucket := crashBucket(stderrA, n)
+	if !strings.HasPrefix(bucket, BUCKET_PREFIX_STDERR) ||
+		len(bucket) != len(BUCKET_PREFIX_STDERR)+BUCKET_HASH_LEN {
+		t.Errorf("Malformed stderr bucket %q", bucket)
+	}
+	if bucket == crashBucket(stderrC, n) {
+		t.Errorf("Different stderr output has the same bucket")
+	}
+}
@@ -0,0 +1,203 @@
+package resultproc
+
+import (
+	"errors"
+	"fmt"
+	"github.com/SeanHeelan/Malamute/config"
+	"github.com/SeanHeelan/Malamute/data"
+	"github.com/SeanHeelan/Malamute/session"
+	"log"
+	"os"
+)
+
+// ResultProcessor is a single stage of the chain that test cases are passed
+// through once they have been executed
+type ResultProcessor interface {
+	// Process examines testCase, and may annotate it. If false is returned
+	// then the test case is dropped, and not passed to later stages. An
+	// error is logged, and the test case passed on regardless.
+	Process(testCase *data.TestCase) (bool, error)
+}
+
+// Constructor creates a ResultProcessor for the session s
+type Constructor func(s *session.Session) (ResultProcessor, error)
+
+// The result processors added with Register
+var registered = make(map[string]Constructor)
+
+// Register makes a result processor available under name, so that it may be
+// used in the chain named in the ResultProcessing section of the config. It
+// is intended to be called from the init function of the package providing
+// the processor. The built-in processors cannot be replaced.
+func Register(name string, constructor Constructor) {
+	registered[name] = constructor
+}
+
+// NewProcessor creates the result processor called name for the session s
+func NewProcessor(name string, s *session.Session) (ResultProcessor, error) {
+	switch name {
+	case config.RESULTPROC_ORACLE:
+		return newOracleProcessor(s)
+	case config.RESULTPROC_CLASSIFY:
+		return newClassifierProcessor(s)
+	case config.RESULTPROC_LINEAGE:
+		return newLineageProcessor(s)
+	case config.RESULTPROC_CRASHDB:
+		return newCrashDBProcessor(s)
+	case config.RESULTPROC_IGNORE:
+		return newIgnoreProcessor(s)
+	case config.RESULTPROC_DEDUP:
+		return newDedupProcessor(s)
+	case config.RESULTPROC_MINIMIZE:
+		return newMinimizeProcessor(s)
+	case config.RESULTPROC_PRESERVE:
+		return newPreserveProcessor(s)
+	case config.RESULTPROC_INTERESTING:
+		return newInterestingProcessor(s)
+	case config.RESULTPROC_NOTIFY:
+		return newNotifyProcessor(s)
+	}
+
+	if constructor, ok := registered[name]; ok {
+		return constructor(s)
+	}
+
+	msg := fmt.Sprintf("Unknown result processor : %s", name)
+	return nil, errors.New(msg)
+}
+
+// analysis runs the result processors that decide whether a test case
+// triggers a bug, and which bucket it falls in
+type analysis []ResultProcessor
+
+// NewAnalysis creates a result processor that decides whether a test case
+// triggers a bug, and which bucket it falls in, in the same way as the chain.
+// It is intended for checking bugs again outside of the chain.
+func NewAnalysis(s *session.Session) (ResultProcessor, error) {
+	a := analysis{}
+	for _, name := range []string{config.RESULTPROC_ORACLE,
+		config.RESULTPROC_CLASSIFY} {
+
+		stage, err := NewProcessor(name, s)
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, stage)
+	}
+
+	return a, nil
+}
+
+func (a analysis) Process(testCase *data.TestCase) (bool, error) {
+	for _, stage := range a {
+		if _, err := process(stage, testCase); err != nil {
+			return true, err
+		}
+	}
+
+	return true, nil
+}
+
+// Chain passes each test case through a series of result processors
+type Chain struct {
+	names  []string
+	stages []ResultProcessor
+}
+
+// NewChain creates the chain of result processors named in the
+// ResultProcessing section of the config of s
+func NewChain(s *session.Session) (*Chain, error) {
+	c := Chain{}
+	for _, name := range s.Config.ResultProcessing.Chain {
+		stage, err := NewProcessor(name, s)
+		if err != nil {
+			msg := fmt.Sprintf("Could not create result processor %s: %s",
+				name, err)
+			return nil, errors.New(msg)
+		}
+
+		c.names = append(c.names, name)
+		c.stages = append(c.stages, stage)
+	}
+
+	return &c, nil
+}
+
+// process runs a single stage on testCase, turning a panic into an error so
+// that a faulty stage cannot bring down the session
+func process(stage ResultProcessor, testCase *data.TestCase) (keep bool,
+	err error) {
+
+	defer func() {
+		if r := recover(); r != nil {
+			keep = true
+			err = errors.New(fmt.Sprintf("panic: %v", r))
+		}
+	}()
+
+	return stage.Process(testCase)
+}
+
+// Process passes testCase through each stage of the chain, until one drops
+// it. Unless the test case was preserved its fuzz file, and anything else
+// left by its execution, is then removed.
+func (c *Chain) Process(testCase *data.TestCase) {
+	for i, stage := range c.stages {
+		keep, err := process(stage, testCase)
+		if err != nil {
+			log.Printf("Result processor %s failed on %s: %s",
+				c.names[i], testCase.FuzzFilePath, err)
+		}
+
+		if !keep {
+			testCase.DroppedBy = c.names[i]
+			break
+		}
+	}
+
+	if len(testCase.PreservationDir) == 0 {
+		cleanUp(*testCase)
+	}
+}
+
+// Run starts a work loop that consumes test cases, passes each through the
+// chain and then on to out. Every test case is passed on, including those
+// dropped by a stage.
+func (c *Chain) Run(in chan data.TestCase, out chan data.TestCase) {
+	for {
+		testCase := <-in
+		if len(testCase.SeedFilePaths) == 0 {
+			close(out)
+			break
+		}
+
+		c.Process(&testCase)
+		out <- testCase
+	}
+}
+
+// cleanUp removes the fuzz file of a test case that was not preserved, along
+// with any other files left by its execution
+func cleanUp(testCase data.TestCase) {
+	if testCase.BugFound && len(testCase.DroppedBy) == 0 {
+		log.Printf("The potential bug triggered by %s was not preserved, "+
+			"so it has been left in place", testCase.FuzzFilePath)
+		return
+	}
+
+	err := os.Remove(testCase.FuzzFilePath)
+	if err != nil {
+		log.Printf("Failed to remove %s\n. Removed by the test?",
+			testCase.FuzzFilePath)
+	}
+
+	if len(testCase.MinimizedPath) != 0 {
+		err := os.Remove(testCase.MinimizedPath)
+		if err != nil && !os.IsNotExist(err) {
+			log.Printf("Failed to remove %s : %s", testCase.MinimizedPath,
+				err)
+		}
+	}
+
+	RemoveRunFiles(testCase)
+}
@@ -0,0 +1,225 @@
+package resultproc
+
+import (
+	"errors"
+	"github.com/SeanHeelan/Malamute/config"
+	"github.com/SeanHeelan/Malamute/data"
+	"github.com/SeanHeelan/Malamute/session"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// stubProcessor records that it saw a test case, and then behaves as
+// configured
+type stubProcessor struct {
+	seen  *[]string
+	name  string
+	keep  bool
+	err   error
+	panic bool
+	bug   bool
+}
+
+func (p *stubProcessor) Process(testCase *data.TestCase) (bool, error) {
+	*p.seen = append(*p.seen, p.name)
+	if p.panic {
+		panic("boom")
+	}
+	if p.bug {
+		testCase.BugFound = true
+	}
+
+	return p.keep, p.err
+}
+
+func TestChainProcess(t *testing.T) {
+	tests := []struct {
+		name          string
+		stages        []stubProcessor
+		preserved     bool
+		wantSeen      []string
+		wantDroppedBy string
+		wantRemoved   bool
+	}{
+		{
+			name: "kept",
+			stages: []stubProcessor{{name: "a", keep: true},
+				{name: "b", keep: true}},
+			wantSeen:    []string{"a", "b"},
+			wantRemoved: true,
+		},
+		{
+			name: "dropped",
+			stages: []stubProcessor{{name: "a", keep: true},
+				{name: "b"}, {name: "c", keep: true}},
+			wantSeen:      []string{"a", "b"},
+			wantDroppedBy: "b",
+			wantRemoved:   true,
+		},
+		{
+			// An error is logged, and the test case kept
+			name: "error",
+			stages: []stubProcessor{{name: "a", keep: true,
+				err: errors.New("oops")}, {name: "b", keep: true}},
+			wantSeen:    []string{"a", "b"},
+			wantRemoved: true,
+		},
+		{
+			name: "panic",
+			stages: []stubProcessor{{name: "a", panic: true},
+				{name: "b", keep: true}},
+			wantSeen:    []string{"a", "b"},
+			wantRemoved: true,
+		},
+		{
+			// A bug that nothing preserved is left in place
+			name: "bug not preserved",
+			stages: []stubProcessor{{name: "a", keep: true, bug: true},
+				{name: "b", keep: true}},
+			wantSeen: []string{"a", "b"},
+		},
+		{
+			name: "bug dropped",
+			stages: []stubProcessor{{name: "a", keep: true, bug: true},
+				{name: "ignore"}},
+			wantSeen:      []string{"a", "ignore"},
+			wantDroppedBy: "ignore",
+			wantRemoved:   true,
+		},
+		{
+			name:      "preserved",
+			stages:    []stubProcessor{{name: "a", keep: true}},
+			preserved: true,
+			wantSeen:  []string{"a"},
+		},
+	}
+
+	dir, err := ioutil.TempDir("", "chain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, test := range tests {
+		fuzzFile := filepath.Join(dir, "t.php")
+		if err := ioutil.WriteFile(fuzzFile, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		seen := []string{}
+		chain := Chain{}
+		for i := range test.stages {
+			stage := test.stages[i]
+			stage.seen = &seen
+			chain.names = append(chain.names, stage.name)
+			chain.stages = append(chain.stages, &stage)
+		}
+
+		testCase := data.TestCase{FuzzFilePath: fuzzFile}
+		if test.preserved {
+			testCase.PreservationDir = dir
+		}
+		chain.Process(&testCase)
+
+		if !reflect.DeepEqual(seen, test.wantSeen) {
+			t.Errorf("%s: stages %q saw the test case, want %q", test.name,
+				seen, test.wantSeen)
+		}
+		if testCase.DroppedBy != test.wantDroppedBy {
+			t.Errorf("%s: DroppedBy = %q, want %q", test.name,
+				testCase.DroppedBy, test.wantDroppedBy)
+		}
+
+		_, err := os.Stat(fuzzFile)
+		if removed := os.IsNotExist(err); removed != test.wantRemoved {
+			t.Errorf("%s: fuzz file removed %v, want %v", test.name,
+				removed, test.wantRemoved)
+		}
+	}
+}
+
+func TestNewChain(t *testing.T) {
+	Register("stub", func(s *session.Session) (ResultProcessor, error) {
+		return &stubProcessor{seen: &[]string{}, keep: true}, nil
+	})
+
+	tests := []struct {
+		chain   []string
+		wantErr bool
+	}{
+		{[]string{}, false},
+		{[]string{"stub", "stub"}, false},
+		{[]string{config.RESULTPROC_DEDUP, "stub"}, false},
+		{[]string{"stub", "missing"}, true},
+	}
+
+	for _, test := range tests {
+		s := &session.Session{Config: &config.Config{}}
+		s.Config.ResultProcessing.Chain = test.chain
+
+		chain, err := NewChain(s)
+		if (err != nil) != test.wantErr {
+			t.Errorf("NewChain(%q): err = %v, want error %v", test.chain,
+				err, test.wantErr)
+			continue
+		}
+		if err == nil && len(chain.stages) != len(test.chain) {
+			t.Errorf("NewChain(%q) has %d stages", test.chain,
+				len(chain.stages))
+		}
+	}
+}
+
+func TestDedupProcessor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dedup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// A crash preserved by an earlier run of the session
+	crashDir := filepath.Join(dir, "1")
+	if err := os.Mkdir(crashDir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteBugDescriptor(crashDir,
+		BugDescriptor{Bucket: "stack:a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &session.Session{Config: &config.Config{}, PreservationDir: dir}
+	s.Config.Dedup.MaxPerBucket = 2
+	p, err := newDedupProcessor(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		bucket string
+		want   bool
+	}{
+		{"stack:a", true},
+		{"stack:a", false},
+		{"stack:b", true},
+		{"stack:b", true},
+		{"stack:b", false},
+		// Test cases without a bucket are not crashes
+		{"", true},
+		{"", true},
+		{"", true},
+	}
+
+	for i, test := range tests {
+		keep, err := p.Process(&data.TestCase{Bucket: test.bucket})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if keep != test.want {
+			t.Errorf("Test case %d in bucket %q kept %v, want %v", i,
+				test.bucket, keep, test.want)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package resultproc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/SeanHeelan/Malamute/config"
+	"github.com/SeanHeelan/Malamute/data"
+	"github.com/SeanHeelan/Malamute/session"
+	"github.com/SeanHeelan/Malamute/triage"
+	"github.com/kballard/go-shellquote"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	// The class given to bugs reported by the classifier that were not
+	// otherwise detected
+	BUGCLASS_CLASSIFIER = "classifier"
+)
+
+// ClassifierVerdict is the result printed by a user-supplied classifier.
+// Fields that are not set leave the classification unchanged.
+type ClassifierVerdict struct {
+	// Bug, if set, indicates whether the test case triggers a bug
+	Bug *bool
+	// BugClass, if set, overrides the class of the bug. It defaults to
+	// BUGCLASS_CLASSIFIER for bugs that were not otherwise detected.
+	BugClass string
+	// Bucket, if set, overrides the bucket of the bug
+	Bucket string
+	// Severity, if set, overrides the severity of the bug. It must be one
+	// of the triage.SEVERITY_* constants.
+	Severity string
+	// Notes are recorded alongside the bug
+	Notes string
+}
+
+// classifier runs the command from the Classifier section of the config,
+// giving it the chance to override the decision of the oracle. It does
+// nothing if no command is configured.
+type classifier struct {
+	args     []string
+	timeout  time.Duration
+	allTests bool
+}
+
+func newClassifierProcessor(s *session.Session) (ResultProcessor, error) {
+	return newClassifier(s.Config)
+}
+
+// newClassifier creates a classifier as described by cfg
+func newClassifier(cfg *config.Config) (*classifier, error) {
+	if len(cfg.Classifier.Command) == 0 {
+		return &classifier{}, nil
+	}
+
+	args, err := shellquote.Split(cfg.Classifier.Command)
+	if err != nil || len(args) == 0 {
+		msg := fmt.Sprintf("Failed to parse the classifier command : %s",
+			cfg.Classifier.Command)
+		return nil, errors.New(msg)
+	}
+
+	return &classifier{
+		args:     args,
+		timeout:  cfg.Classifier.Timeout.Duration,
+		allTests: cfg.Classifier.AllTests,
+	}, nil
+}
+
+func (c *classifier) Process(testCase *data.TestCase) (bool, error) {
+	if len(c.args) == 0 {
+		return true, nil
+	}
+
+	return true, c.classify(testCase)
+}
+
+// run passes testCase to the classifier command and returns its verdict
+func (c *classifier) run(testCase *data.TestCase) (*ClassifierVerdict,
+	error) {
+
+	input, err := json.Marshal(testCase)
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout, stderr bytes.Buffer
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, c.args[0], c.args[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := runCommand(ctx, cmd); err == context.DeadlineExceeded {
+		msg := fmt.Sprintf("%s took longer than %s", c.args[0], c.timeout)
+		return nil, errors.New(msg)
+	} else if err != nil {
+		msg := fmt.Sprintf("Error running %s: %s: %s", c.args[0], err,
+			strings.TrimSpace(stderr.String()))
+		return nil, errors.New(msg)
+	}
+
+	var verdict ClassifierVerdict
+	if err := json.Unmarshal(stdout.Bytes(), &verdict); err != nil {
+		msg := fmt.Sprintf("Could not parse the verdict of %s: %s",
+			c.args[0], err)
+		return nil, errors.New(msg)
+	}
+
+	return &verdict, nil
+}
+
+// classify runs the classifier on testCase, if it is a candidate bug or the
+// classifier is to be run on all tests, and applies its verdict
+func (c *classifier) classify(testCase *data.TestCase) error {
+	if !testCase.BugFound && !c.allTests {
+		return nil
+	}
+
+	verdict, err := c.run(testCase)
+	if err != nil {
+		return err
+	}
+
+	testCase.ClassifierNotes = verdict.Notes
+	if verdict.Bug != nil {
+		if !*verdict.Bug && testCase.BugFound {
+			// Nothing about the rejected bug should be reported
+			testCase.ClassifierRejected = true
+			testCase.BugClass = ""
+			testCase.Bucket = ""
+			testCase.Severity = ""
+			testCase.SeverityReason = ""
+		} else if *verdict.Bug && !testCase.BugFound {
+			testCase.BugClass = BUGCLASS_CLASSIFIER
+		}
+		testCase.BugFound = *verdict.Bug
+	}
+
+	if !testCase.BugFound {
+		return nil
+	}
+
+	if len(verdict.BugClass) != 0 {
+		testCase.BugClass = verdict.BugClass
+	}
+
+	if len(verdict.Bucket) != 0 {
+		testCase.Bucket = verdict.Bucket
+	}
+
+	if len(verdict.Severity) != 0 {
+		rank := triage.Rank(verdict.Severity)
+		if rank > triage.Rank(triage.SEVERITY_UNKNOWN) {
+			msg := fmt.Sprintf("The classifier returned an unknown "+
+				"severity : %s", verdict.Severity)
+			return errors.New(msg)
+		}
+		testCase.Severity = verdict.Severity
+		testCase.SeverityReason = "classifier"
+		if len(verdict.Notes) != 0 {
+			testCase.SeverityReason = verdict.Notes
+		}
+	}
+
+	return nil
+}
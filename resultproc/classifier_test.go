@@ -0,0 +1,160 @@
+package resultproc
+
+import (
+	"github.com/SeanHeelan/Malamute/config"
+	"github.com/SeanHeelan/Malamute/data"
+	"github.com/SeanHeelan/Malamute/triage"
+	"testing"
+	"time"
+)
+
+// classifierCommand returns a command that reads the test case and then
+// prints output
+func classifierCommand(output string) string {
+	return `sh -c 'cat > /dev/null; printf "%s" "$1"' sh '` + output + `'`
+}
+
+func TestClassifier(t *testing.T) {
+	crash := data.TestCase{BugFound: true, BugClass: BUGCLASS_CRASH,
+		Bucket: "stack:a", Severity: triage.SEVERITY_MEDIUM}
+	clean := data.TestCase{}
+
+	tests := []struct {
+		name     string
+		output   string
+		allTests bool
+		testCase data.TestCase
+		want     data.TestCase
+		wantErr  bool
+	}{
+		{
+			name:     "no change",
+			output:   `{}`,
+			testCase: crash,
+			want:     crash,
+		},
+		{
+			name:     "rejected",
+			output:   `{"Bug": false, "Notes": "expected"}`,
+			testCase: crash,
+			want: data.TestCase{ClassifierRejected: true,
+				ClassifierNotes: "expected"},
+		},
+		{
+			name: "overridden",
+			output: `{"BugClass": "leak", "Bucket": "mine", ` +
+				`"Severity": "high"}`,
+			testCase: crash,
+			want: data.TestCase{BugFound: true, BugClass: "leak",
+				Bucket: "mine", Severity: triage.SEVERITY_HIGH,
+				SeverityReason: "classifier"},
+		},
+		{
+			name:     "not a candidate",
+			output:   `{"Bug": true}`,
+			testCase: clean,
+			want:     clean,
+		},
+		{
+			name:     "reported",
+			output:   `{"Bug": true, "Notes": "odd output"}`,
+			allTests: true,
+			testCase: clean,
+			want: data.TestCase{BugFound: true,
+				BugClass: BUGCLASS_CLASSIFIER, ClassifierNotes: "odd output"},
+		},
+		{
+			name:     "unknown severity",
+			output:   `{"Severity": "dire"}`,
+			testCase: crash,
+			want:     crash,
+			wantErr:  true,
+		},
+		{
+			name:     "invalid verdict",
+			output:   `not json`,
+			testCase: crash,
+			want:     crash,
+			wantErr:  true,
+		},
+	}
+
+	for _, test := range tests {
+		cfg := &config.Config{}
+		cfg.Classifier.Command = classifierCommand(test.output)
+		cfg.Classifier.AllTests = test.allTests
+		cfg.Classifier.Timeout.Duration = 5 * time.Second
+		c, err := newClassifier(cfg)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+
+		testCase := test.testCase
+		keep, err := c.Process(&testCase)
+		if !keep {
+			t.Errorf("%s: the test case was dropped", test.name)
+		}
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: err = %v, want error %v", test.name, err,
+				test.wantErr)
+		}
+
+		if testCase.BugFound != test.want.BugFound ||
+			testCase.BugClass != test.want.BugClass ||
+			testCase.Bucket != test.want.Bucket ||
+			testCase.Severity != test.want.Severity ||
+			testCase.SeverityReason != test.want.SeverityReason ||
+			testCase.ClassifierNotes != test.want.ClassifierNotes ||
+			testCase.ClassifierRejected != test.want.ClassifierRejected {
+			t.Errorf("%s: got %+v, want %+v", test.name, testCase,
+				test.want)
+		}
+	}
+}
+
+func TestClassifierErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+	}{
+		{"failing command", `sh -c 'cat > /dev/null; exit 1'`},
+		{"missing command", `/nonexistent/classifier`},
+		{"slow command", `sh -c 'cat > /dev/null; exec sleep 5'`},
+		// A helper left running must not keep the command from being
+		// waited on
+		{"slow helper", `sh -c 'cat > /dev/null; sleep 30 & sleep 30'`},
+	}
+
+	for _, test := range tests {
+		cfg := &config.Config{}
+		cfg.Classifier.Command = test.command
+		cfg.Classifier.Timeout.Duration = 100 * time.Millisecond
+		c, err := newClassifier(cfg)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+
+		testCase := data.TestCase{BugFound: true, BugClass: BUGCLASS_CRASH}
+		start := time.Now()
+		if _, err := c.Process(&testCase); err == nil {
+			t.Errorf("%s: no error", test.name)
+		}
+		if elapsed := time.Since(start); elapsed > 10*time.Second {
+			t.Errorf("%s: took %s", test.name, elapsed)
+		}
+		if !testCase.BugFound || testCase.BugClass != BUGCLASS_CRASH {
+			t.Errorf("%s: the classification was changed", test.name)
+		}
+	}
+
+	// Nothing is run when no command is configured
+	c, err := newClassifier(&config.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keep, err := c.Process(&data.TestCase{BugFound: true}); !keep ||
+		err != nil {
+		t.Errorf("The classifier without a command returned %v, %v", keep,
+			err)
+	}
+}
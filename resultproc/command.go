@@ -0,0 +1,32 @@
+package resultproc
+
+import (
+	"context"
+	"os/exec"
+	"syscall"
+)
+
+// runCommand runs cmd, which must have been created with
+// exec.CommandContext from ctx, in its own process group. Once ctx is done
+// the whole group is killed, as a helper left running would keep the
+// output pipes of cmd open and so stop it from ever being waited on.
+func runCommand(ctx context.Context, cmd *exec.Cmd) error {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		<-done
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,47 @@
+package resultproc
+
+import (
+	"github.com/SeanHeelan/Malamute/crashdb"
+	"github.com/SeanHeelan/Malamute/data"
+	"github.com/SeanHeelan/Malamute/session"
+	"log"
+)
+
+// crashDBProcessor labels bugs as new or known by the crash database, if
+// there is one. Bugs are added to the database by the preserve processor.
+type crashDBProcessor struct {
+	db *crashdb.DB
+}
+
+func newCrashDBProcessor(s *session.Session) (ResultProcessor, error) {
+	p := crashDBProcessor{}
+	if len(s.Config.CrashDB.Dir) == 0 {
+		return &p, nil
+	}
+
+	var err error
+	if p.db, err = crashdb.Open(s.Config.CrashDB.Dir); err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+func (p *crashDBProcessor) Process(testCase *data.TestCase) (bool, error) {
+	if p.db == nil || !testCase.BugFound || len(testCase.Bucket) == 0 {
+		return true, nil
+	}
+
+	label, known, err := p.db.Label(testCase.Bucket)
+	if err != nil {
+		log.Printf("Could not query the crash database : %s", err)
+		return true, nil
+	}
+
+	testCase.CrashDBLabel = label
+	if known != nil {
+		testCase.KnownCrashDir = known.CrashDir
+	}
+
+	return true, nil
+}
@@ -0,0 +1,64 @@
+package resultproc
+
+import (
+	"encoding/json"
+	"github.com/SeanHeelan/Malamute/data"
+	"github.com/SeanHeelan/Malamute/session"
+	"io/ioutil"
+	"path/filepath"
+)
+
+// dedupProcessor drops crashes from buckets that have already had the
+// configured number of crashes preserved. Crashes preserved by earlier runs
+// of the session are taken into account.
+type dedupProcessor struct {
+	maxPerBucket int
+	counts       map[string]int
+}
+
+func newDedupProcessor(s *session.Session) (ResultProcessor, error) {
+	p := dedupProcessor{
+		maxPerBucket: s.Config.Dedup.MaxPerBucket,
+		counts:       make(map[string]int),
+	}
+	if p.maxPerBucket == 0 {
+		return &p, nil
+	}
+
+	descPaths, err := filepath.Glob(filepath.Join(s.PreservationDir, "*",
+		BUG_DESC_NAME))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, descPath := range descPaths {
+		jsonData, err := ioutil.ReadFile(descPath)
+		if err != nil {
+			continue
+		}
+
+		var bugDesc BugDescriptor
+		if err := json.Unmarshal(jsonData, &bugDesc); err != nil {
+			continue
+		}
+
+		if len(bugDesc.Bucket) != 0 {
+			p.counts[bugDesc.Bucket]++
+		}
+	}
+
+	return &p, nil
+}
+
+func (p *dedupProcessor) Process(testCase *data.TestCase) (bool, error) {
+	if p.maxPerBucket == 0 || len(testCase.Bucket) == 0 {
+		return true, nil
+	}
+
+	if p.counts[testCase.Bucket] >= p.maxPerBucket {
+		return false, nil
+	}
+	p.counts[testCase.Bucket]++
+
+	return true, nil
+}
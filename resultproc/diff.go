@@ -0,0 +1,147 @@
+package resultproc
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/SeanHeelan/Malamute/data"
+	"github.com/SeanHeelan/Malamute/monitor"
+	"github.com/SeanHeelan/Malamute/normalize"
+	"os"
+)
+
+const (
+	// The largest number of entries in the table of common subsequence
+	// lengths built by diffLines. Outputs that differ over more than this
+	// are not aligned, and the lines of each are simply listed.
+	DIFF_MAX_TABLE = 1 << 22
+)
+
+// diffLines produces a line based diff of a and b, in the style of
+// diff -u but without hunk headers. Lines only in a are prefixed with '-',
+// lines only in b with '+' and common lines with ' '.
+func diffLines(a []string, b []string) []string {
+	// Lines common to the start and end of both are kept out of the table
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix &&
+		a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	diff := []string{}
+	for _, line := range a[:prefix] {
+		diff = append(diff, " "+line)
+	}
+	diff = append(diff, diffMiddle(a[prefix:len(a)-suffix],
+		b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		diff = append(diff, " "+line)
+	}
+
+	return diff
+}
+
+// diffMiddle produces the diff of a and b for diffLines, using a table of
+// the lengths of their common subsequences, unless that would hold more
+// than DIFF_MAX_TABLE entries
+func diffMiddle(a []string, b []string) []string {
+	diff := []string{}
+	if (len(a)+1)*(len(b)+1) > DIFF_MAX_TABLE {
+		for _, line := range a {
+			diff = append(diff, "-"+line)
+		}
+		for _, line := range b {
+			diff = append(diff, "+"+line)
+		}
+		return diff
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if a[i] == b[j] {
+			diff = append(diff, " "+a[i])
+			i++
+			j++
+		} else if lcs[i+1][j] >= lcs[i][j+1] {
+			diff = append(diff, "-"+a[i])
+			i++
+		} else {
+			diff = append(diff, "+"+b[j])
+			j++
+		}
+	}
+
+	for ; i < len(a); i++ {
+		diff = append(diff, "-"+a[i])
+	}
+	for ; j < len(b); j++ {
+		diff = append(diff, "+"+b[j])
+	}
+
+	return diff
+}
+
+// writeDiffs stores, at path, the differences between the results of the
+// main interpreter and those of each divergent profile of testCase, and
+// notes each profile that timed out. Output is normalized by n, as it was
+// when the differential monitor compared it, so that only the differences
+// that made the profile diverge are shown.
+func writeDiffs(path string, testCase data.TestCase,
+	n *normalize.Normalizer) error {
+
+	fd, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	writer := bufio.NewWriter(fd)
+	mainStdout := n.Lines(
+		testCase.DifferentialStdout[monitor.DIFF_MAIN_PROFILE])
+	mainExitCode := testCase.DifferentialExitCodes[monitor.DIFF_MAIN_PROFILE]
+
+	for _, name := range testCase.DivergentProfiles {
+		fmt.Fprintf(writer, "--- %s\n+++ %s\n", monitor.DIFF_MAIN_PROFILE,
+			name)
+		exitCode := testCase.DifferentialExitCodes[name]
+		if exitCode != mainExitCode {
+			fmt.Fprintf(writer, "exit code %d != %d\n", mainExitCode,
+				exitCode)
+		}
+
+		for _, line := range diffLines(mainStdout,
+			n.Lines(testCase.DifferentialStdout[name])) {
+			fmt.Fprintln(writer, line)
+		}
+		fmt.Fprintln(writer)
+	}
+
+	for _, name := range testCase.TimedOutProfiles {
+		fmt.Fprintf(writer, "--- %s\n+++ %s\ntimed out\n\n",
+			monitor.DIFF_MAIN_PROFILE, name)
+	}
+
+	return writer.Flush()
+}
@@ -0,0 +1,101 @@
+package resultproc
+
+import (
+	"fmt"
+	"github.com/SeanHeelan/Malamute/data"
+	"github.com/SeanHeelan/Malamute/monitor"
+	"github.com/SeanHeelan/Malamute/normalize"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		a    []string
+		b    []string
+		want []string
+	}{
+		{[]string{}, []string{}, []string{}},
+		{[]string{"a", "b"}, []string{"a", "b"}, []string{" a", " b"}},
+		{[]string{"a"}, []string{}, []string{"-a"}},
+		{[]string{}, []string{"a"}, []string{"+a"}},
+		{[]string{"a", "b", "c"}, []string{"a", "x", "c"},
+			[]string{" a", "-b", "+x", " c"}},
+		{[]string{"a", "b", "c"}, []string{"b", "c", "d"},
+			[]string{"-a", " b", " c", "+d"}},
+		{[]string{"a", "b", "c", "d"}, []string{"a", "c", "b", "d"},
+			[]string{" a", "-b", " c", "+b", " d"}},
+	}
+
+	for _, test := range tests {
+		if got := diffLines(test.a, test.b); !reflect.DeepEqual(got,
+			test.want) {
+			t.Errorf("diffLines(%q, %q) = %q, want %q", test.a, test.b,
+				got, test.want)
+		}
+	}
+}
+
+func TestWriteDiffs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, DIFF_NAME)
+
+	// Addresses are normalized away, as the monitor did when comparing
+	// the output
+	testCase := data.TestCase{
+		DifferentialStdout: map[string][]string{
+			monitor.DIFF_MAIN_PROFILE: {"1", "2", "at 0x10"},
+			"jit":                     {"1", "3", "at 0x20"},
+		},
+		DifferentialExitCodes: map[string]int{
+			monitor.DIFF_MAIN_PROFILE: 0,
+			"jit":                     SIGSEGV,
+		},
+		DivergentProfiles: []string{"jit"},
+		TimedOutProfiles:  []string{"opcache"},
+	}
+	n, err := normalize.New("", nil, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeDiffs(path, testCase, n); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	main := monitor.DIFF_MAIN_PROFILE
+	want := "--- " + main + "\n+++ jit\nexit code 0 != 139\n" +
+		" 1\n-2\n+3\n at 0xADDR\n\n" +
+		"--- " + main + "\n+++ opcache\ntimed out\n\n"
+	if string(got) != want {
+		t.Errorf("Diff file is\n%s\nwant\n%s", got, want)
+	}
+}
+
+// Outputs too large to align are listed in full rather than exhausting
+// memory
+func TestDiffLinesLarge(t *testing.T) {
+	a := []string{"same"}
+	b := []string{"same"}
+	for i := 0; i < 3000; i++ {
+		a = append(a, fmt.Sprintf("a%d", i))
+		b = append(b, fmt.Sprintf("b%d", i))
+	}
+
+	diff := diffLines(a, b)
+	if len(diff) != 6001 || diff[0] != " same" || diff[1] != "-a0" ||
+		diff[3001] != "+b0" {
+		t.Errorf("diffLines produced %d lines, starting %q", len(diff),
+			diff[:2])
+	}
+}
@@ -0,0 +1,154 @@
+package resultproc
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/SeanHeelan/Malamute/data"
+	"github.com/SeanHeelan/Malamute/session"
+	"github.com/SeanHeelan/Malamute/triage"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	// The kinds of entry in an ignore list, as described on
+	// config.Ignore.File
+	IGNORE_BUCKET = "bucket"
+	IGNORE_ASSERT = "assert"
+	IGNORE_STDERR = "stderr"
+)
+
+// IgnoreRule is a single entry of an ignore list
+type IgnoreRule struct {
+	// Kind is one of the IGNORE_* constants
+	Kind    string
+	Pattern string
+	Note    string
+	// URL, if not empty, links to the report of the bug
+	URL string
+	re  *regexp.Regexp
+}
+
+// Label describes the rule in the stats, by its note and bug URL
+func (r *IgnoreRule) Label() string {
+	if len(r.URL) == 0 {
+		return r.Note
+	}
+
+	return fmt.Sprintf("%s <%s>", r.Note, r.URL)
+}
+
+// matches indicates if testCase triggers the bug that the rule describes
+func (r *IgnoreRule) matches(testCase *data.TestCase) bool {
+	switch r.Kind {
+	case IGNORE_BUCKET:
+		return testCase.Bucket == r.Pattern
+	case IGNORE_ASSERT:
+		message := triage.AssertionMessage(testCase.RunStderr)
+		return len(message) != 0 && strings.Contains(message, r.Pattern)
+	case IGNORE_STDERR:
+		for _, line := range testCase.RunStderr {
+			if r.re.MatchString(line) {
+				return true
+			}
+		}
+		for _, line := range testCase.SymbolizedStderr {
+			if r.re.MatchString(line) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// LoadIgnoreList reads the ignore list at path, in the format described on
+// config.Ignore.File
+func LoadIgnoreList(path string) ([]*IgnoreRule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rules := []*IgnoreRule{}
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) < 3 || len(fields) > 4 {
+			msg := fmt.Sprintf("%s:%d: Expected a kind, pattern, note and "+
+				"optional bug URL separated by tabs", path, lineNo)
+			return nil, errors.New(msg)
+		}
+
+		rule := IgnoreRule{
+			Kind:    strings.ToLower(fields[0]),
+			Pattern: fields[1],
+			Note:    fields[2],
+		}
+		if len(fields) == 4 {
+			rule.URL = fields[3]
+		}
+
+		switch rule.Kind {
+		case IGNORE_BUCKET, IGNORE_ASSERT:
+		case IGNORE_STDERR:
+			if rule.re, err = regexp.Compile(rule.Pattern); err != nil {
+				msg := fmt.Sprintf("%s:%d: Invalid regular expression %s : "+
+					"%s", path, lineNo, rule.Pattern, err)
+				return nil, errors.New(msg)
+			}
+		default:
+			msg := fmt.Sprintf("%s:%d: Unknown kind of entry %s", path,
+				lineNo, rule.Kind)
+			return nil, errors.New(msg)
+		}
+
+		rules = append(rules, &rule)
+	}
+
+	return rules, scanner.Err()
+}
+
+// ignoreProcessor drops bugs that match an entry of the ignore list, so
+// that only new material is preserved. The matching entry is recorded in
+// the test case, so that the bug is still counted in the stats.
+type ignoreProcessor struct {
+	rules []*IgnoreRule
+}
+
+func newIgnoreProcessor(s *session.Session) (ResultProcessor, error) {
+	p := ignoreProcessor{}
+	if len(s.Config.Ignore.File) == 0 {
+		return &p, nil
+	}
+
+	var err error
+	if p.rules, err = LoadIgnoreList(s.Config.Ignore.File); err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+func (p *ignoreProcessor) Process(testCase *data.TestCase) (bool, error) {
+	if !testCase.BugFound {
+		return true, nil
+	}
+
+	for _, rule := range p.rules {
+		if rule.matches(testCase) {
+			testCase.IgnoredAs = rule.Label()
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
@@ -0,0 +1,146 @@
+package resultproc
+
+import (
+	"github.com/SeanHeelan/Malamute/config"
+	"github.com/SeanHeelan/Malamute/data"
+	"github.com/SeanHeelan/Malamute/session"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadIgnoreList(t *testing.T) {
+	tests := []struct {
+		name      string
+		list      string
+		wantRules []IgnoreRule
+		wantErr   bool
+	}{
+		{
+			name: "rules",
+			list: "# Known bugs\n\n" +
+				"bucket\tstack:a\tFiled\thttps://bugs.php.net/1\n" +
+				"  ASSERT\tzend_hash\tWon't fix  \n" +
+				"stderr\tleak of \\d+ bytes\tLeaks\n",
+			wantRules: []IgnoreRule{
+				{Kind: IGNORE_BUCKET, Pattern: "stack:a", Note: "Filed",
+					URL: "https://bugs.php.net/1"},
+				{Kind: IGNORE_ASSERT, Pattern: "zend_hash",
+					Note: "Won't fix"},
+				{Kind: IGNORE_STDERR, Pattern: "leak of \\d+ bytes",
+					Note: "Leaks"},
+			},
+		},
+		{name: "empty", list: "", wantRules: []IgnoreRule{}},
+		{name: "too few fields", list: "bucket\tstack:a\n", wantErr: true},
+		{name: "too many fields", list: "bucket\ta\tb\tc\td\n",
+			wantErr: true},
+		{name: "unknown kind", list: "signal\t11\tSegfaults\n",
+			wantErr: true},
+		{name: "invalid regexp", list: "stderr\t(\tBroken\n",
+			wantErr: true},
+	}
+
+	dir, err := ioutil.TempDir("", "ignore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, test := range tests {
+		path := filepath.Join(dir, "ignore.txt")
+		if err := ioutil.WriteFile(path, []byte(test.list), 0644); err !=
+			nil {
+			t.Fatal(err)
+		}
+
+		rules, err := LoadIgnoreList(path)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: err = %v, want error %v", test.name, err,
+				test.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		if len(rules) != len(test.wantRules) {
+			t.Errorf("%s: %d rules, want %d", test.name, len(rules),
+				len(test.wantRules))
+			continue
+		}
+		for i, rule := range rules {
+			want := test.wantRules[i]
+			if rule.Kind != want.Kind || rule.Pattern != want.Pattern ||
+				rule.Note != want.Note || rule.URL != want.URL {
+				t.Errorf("%s: rule %d = %+v, want %+v", test.name, i, *rule,
+					want)
+			}
+		}
+	}
+
+	if _, err := LoadIgnoreList(filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("A missing ignore list was loaded")
+	}
+}
+
+func TestIgnoreProcessor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ignore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "ignore.txt")
+	list := "bucket\tstack:a\tFiled\thttps://bugs.php.net/1\n" +
+		"assert\tzend_hash\tWon't fix\n" +
+		"stderr\tin f \\(a\\.c:\\d+\\)\tKnown\n"
+	if err := ioutil.WriteFile(path, []byte(list), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &session.Session{Config: &config.Config{}}
+	s.Config.Ignore.File = path
+	p, err := newIgnoreProcessor(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		testCase data.TestCase
+		want     string
+	}{
+		{"bucket", data.TestCase{BugFound: true, Bucket: "stack:a"},
+			"Filed <https://bugs.php.net/1>"},
+		{"other bucket", data.TestCase{BugFound: true, Bucket: "stack:b"},
+			""},
+		{"assertion", data.TestCase{BugFound: true, RunStderr: []string{
+			"php: zend_hash.c:1: f: Assertion `x' failed."}}, "Won't fix"},
+		// Assertion patterns only match the assertion message
+		{"not an assertion", data.TestCase{BugFound: true,
+			RunStderr: []string{"Warning: zend_hash"}}, ""},
+		{"stderr", data.TestCase{BugFound: true,
+			RunStderr: []string{"#0 0x1 in f (a.c:12)"}}, "Known"},
+		{"symbolized stderr", data.TestCase{BugFound: true,
+			RunStderr:        []string{"#0 0x1 (php+0x1)"},
+			SymbolizedStderr: []string{"#0 0x1 in f (a.c:3)"}}, "Known"},
+		// Only bugs are ignored
+		{"no bug", data.TestCase{Bucket: "stack:a"}, ""},
+	}
+
+	for _, test := range tests {
+		testCase := test.testCase
+		keep, err := p.Process(&testCase)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+
+		if keep != (len(test.want) == 0) ||
+			testCase.IgnoredAs != test.want {
+			t.Errorf("%s: kept %v, IgnoredAs = %q, want %q", test.name,
+				keep, testCase.IgnoredAs, test.want)
+		}
+	}
+}
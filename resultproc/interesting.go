@@ -0,0 +1,181 @@
+package resultproc
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/SeanHeelan/Malamute/config"
+	"github.com/SeanHeelan/Malamute/data"
+	"github.com/SeanHeelan/Malamute/fs"
+	"github.com/SeanHeelan/Malamute/normalize"
+	"github.com/SeanHeelan/Malamute/session"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// The file in the interesting directory recording why each test case
+	// was kept. Each line holds the name of a test case, its reason label,
+	// its exit code and its normalized error message, separated by tabs.
+	INTERESTING_INDEX = "reasons.txt"
+
+	// The test case covered new edges
+	INTERESTING_COVERAGE = "coverage"
+	// The test case exited with an exit code not seen before
+	INTERESTING_EXIT_CODE = "exit-code"
+	// The test case wrote an error message not seen before
+	INTERESTING_STDERR = "stderr"
+)
+
+// Matches the parts of an error message that are likely to change with
+// every mutation, such as numbers and quoted names
+var messageNoise = regexp.MustCompile("'[^']*'|\"[^\"]*\"|`[^`]*`|[0-9]+")
+
+// interestingProcessor keeps test cases that do not trigger a bug, but
+// behave in a way not seen before, in the interesting directory of the
+// session
+type interestingProcessor struct {
+	s          *session.Session
+	enabled    bool
+	normalizer *normalize.Normalizer
+	exitCodes  map[string]bool
+	messages   map[string]bool
+}
+
+func newInterestingProcessor(s *session.Session) (ResultProcessor, error) {
+	p := interestingProcessor{
+		s:         s,
+		enabled:   s.Config.Interesting.Enabled,
+		exitCodes: make(map[string]bool),
+		messages:  make(map[string]bool),
+	}
+	if !p.enabled {
+		return &p, nil
+	}
+
+	var err error
+	p.normalizer, err = normalize.New(s.Config.Normalize.Preset,
+		s.Config.Normalize.Replace, s.Config.Normalize.DropLine,
+		s.Config.Normalize.FloatPrecision)
+	if err != nil {
+		return nil, err
+	}
+
+	// Behaviour seen by earlier runs of the session is not novel
+	f, err := os.Open(filepath.Join(s.InterestingDir, INTERESTING_INDEX))
+	if os.IsNotExist(err) {
+		return &p, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), "\t", 4)
+		if len(fields) != 4 {
+			continue
+		}
+
+		p.exitCodes[fields[2]] = true
+		if len(fields[3]) != 0 {
+			p.messages[fields[3]] = true
+		}
+	}
+
+	return &p, scanner.Err()
+}
+
+// message returns the first line written to stderr by testCase, normalized
+// and with anything likely to change with every mutation masked out
+func (p *interestingProcessor) message(testCase *data.TestCase) string {
+	stderr := []string{}
+	for _, line := range testCase.RunStderr {
+		stderr = append(stderr, strings.Replace(line, testCase.FuzzFilePath,
+			config.INTERPRETER_ARGS_FUZZ_FILE_MARKER, -1))
+	}
+
+	for _, line := range p.normalizer.Lines(stderr) {
+		line = strings.TrimSpace(messageNoise.ReplaceAllString(line, "_"))
+		if len(line) != 0 {
+			return strings.Replace(line, "\t", " ", -1)
+		}
+	}
+
+	return ""
+}
+
+// store saves the fuzz file of testCase at path. A test case already added
+// to the corpus, as those with new coverage are, is not stored twice, and
+// path is instead a symbolic link to the copy in the corpus. The link is
+// relative, so that the session directory may be moved.
+func (p *interestingProcessor) store(testCase *data.TestCase,
+	path string) error {
+
+	if len(testCase.CorpusPath) == 0 {
+		return fs.CopyFileContents(testCase.FuzzFilePath, path)
+	}
+
+	target, err := filepath.Rel(p.s.InterestingDir, testCase.CorpusPath)
+	if err != nil {
+		return err
+	}
+
+	return os.Symlink(target, path)
+}
+
+func (p *interestingProcessor) Process(testCase *data.TestCase) (bool,
+	error) {
+
+	if !p.enabled || testCase.BugFound || testCase.TestTimedOut {
+		return true, nil
+	}
+
+	exitCode := strconv.Itoa(testCase.ExitCode)
+	message := p.message(testCase)
+
+	reason := ""
+	if testCase.NewEdges != 0 {
+		reason = INTERESTING_COVERAGE
+	} else if !p.exitCodes[exitCode] {
+		reason = INTERESTING_EXIT_CODE
+	} else if len(message) != 0 && !p.messages[message] {
+		reason = INTERESTING_STDERR
+	}
+
+	p.exitCodes[exitCode] = true
+	if len(message) != 0 {
+		p.messages[message] = true
+	}
+
+	if len(reason) == 0 {
+		return true, nil
+	}
+
+	name := fmt.Sprintf("%d_%s", time.Now().UnixNano(),
+		filepath.Base(testCase.FuzzFilePath))
+	path := filepath.Join(p.s.InterestingDir, name)
+	if err := p.store(testCase, path); err != nil {
+		return true, err
+	}
+
+	indexPath := filepath.Join(p.s.InterestingDir, INTERESTING_INDEX)
+	f, err := os.OpenFile(indexPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE,
+		0666)
+	if err != nil {
+		return true, err
+	}
+	defer f.Close()
+
+	if _, err := fmt.Fprintf(f, "%s\t%s\t%s\t%s\n", name, reason, exitCode,
+		message); err != nil {
+		return true, err
+	}
+
+	testCase.InterestingPath = path
+	testCase.InterestingReason = reason
+	return true, nil
+}
@@ -0,0 +1,113 @@
+package resultproc
+
+import (
+	"github.com/SeanHeelan/Malamute/config"
+	"github.com/SeanHeelan/Malamute/data"
+	"github.com/SeanHeelan/Malamute/session"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInterestingProcessor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "interesting")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fuzzFile := filepath.Join(dir, "t.php")
+	if err := ioutil.WriteFile(fuzzFile, []byte("<?php\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := &session.Session{Config: &config.Config{},
+		InterestingDir: filepath.Join(dir, "interesting")}
+	s.Config.Interesting.Enabled = true
+	if err := os.Mkdir(s.InterestingDir, 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		testCase data.TestCase
+		want     string
+	}{
+		{"first exit code", data.TestCase{ExitCode: 0},
+			INTERESTING_EXIT_CODE},
+		{"same exit code", data.TestCase{ExitCode: 0}, ""},
+		{"new exit code", data.TestCase{ExitCode: 255},
+			INTERESTING_EXIT_CODE},
+		{"new message", data.TestCase{ExitCode: 255, RunStderr: []string{
+			"", "Fatal error: Uncaught 'a' in " + fuzzFile + " on line 3"}},
+			INTERESTING_STDERR},
+		// Strings and numbers differ with every mutation
+		{"same message", data.TestCase{ExitCode: 255, RunStderr: []string{
+			"Fatal error: Uncaught \"bb\" in " + fuzzFile + " on line 12"}},
+			""},
+		{"other message", data.TestCase{ExitCode: 255, RunStderr: []string{
+			"Warning: Division by zero"}}, INTERESTING_STDERR},
+		{"new edges", data.TestCase{ExitCode: 0, NewEdges: 3},
+			INTERESTING_COVERAGE},
+		{"bug", data.TestCase{ExitCode: 139, BugFound: true}, ""},
+		{"timed out", data.TestCase{TestTimedOut: true, ExitCode: 137}, ""},
+	}
+
+	// Everything processed so far is recorded, and is not novel once the
+	// session is resumed. New coverage is decided by the caller, and is
+	// always kept
+	for _, resumed := range []bool{false, true} {
+		p, err := newInterestingProcessor(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, test := range tests {
+			testCase := test.testCase
+			testCase.FuzzFilePath = fuzzFile
+
+			want := test.want
+			if resumed && want != INTERESTING_COVERAGE {
+				want = ""
+			}
+
+			if _, err := p.Process(&testCase); err != nil {
+				t.Fatalf("%s: %s", test.name, err)
+			}
+			if testCase.InterestingReason != want {
+				t.Errorf("%s, resumed %v: reason %q, want %q", test.name,
+					resumed, testCase.InterestingReason, want)
+			}
+			if got := len(testCase.InterestingPath) != 0; got !=
+				(len(want) != 0) {
+				t.Errorf("%s, resumed %v: kept in %q", test.name, resumed,
+					testCase.InterestingPath)
+			}
+		}
+	}
+
+	// A test case in the corpus is linked to rather than copied
+	corpusPath := filepath.Join(dir, "corpus.php")
+	if err := ioutil.WriteFile(corpusPath, []byte("<?php\n"),
+		0644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := newInterestingProcessor(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCase := data.TestCase{FuzzFilePath: fuzzFile, NewEdges: 1,
+		CorpusPath: corpusPath}
+	if _, err := p.Process(&testCase); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Lstat(testCase.InterestingPath)
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("%s is not a link: %v", testCase.InterestingPath, err)
+	}
+	if target, err := filepath.EvalSymlinks(
+		testCase.InterestingPath); err != nil || target != corpusPath {
+		t.Errorf("%s links to %q, want %q: %v", testCase.InterestingPath,
+			target, corpusPath, err)
+	}
+}
@@ -0,0 +1,125 @@
+package resultproc
+
+import (
+	"errors"
+	"fmt"
+	"github.com/SeanHeelan/Malamute/config"
+	"github.com/SeanHeelan/Malamute/data"
+	"github.com/SeanHeelan/Malamute/session"
+	"path/filepath"
+)
+
+const (
+	// The bug is of the same class and in the same bucket as the crash it
+	// descends from
+	LINEAGE_VARIANT = "variant"
+	// The bug is of a different class, or in a different bucket, to the
+	// crash it descends from
+	LINEAGE_SIBLING = "sibling"
+)
+
+// CrashParent is a preserved crash whose trigger is explored
+type CrashParent struct {
+	// Dir is the absolute path of the crash directory
+	Dir string
+	// TriggerPath is the absolute path of the trigger file in Dir
+	TriggerPath string
+	BugClass    string
+	Bucket      string
+	// Lineage lists the crash directories the crash descends from, oldest
+	// first, followed by Dir
+	Lineage []string
+}
+
+// LoadCrashParents reads the bug descriptor preserved in each of dirs
+func LoadCrashParents(dirs []string) ([]CrashParent, error) {
+	parents := []CrashParent{}
+	for _, dir := range dirs {
+		absDir, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		bugDesc, err := ReadBugDescriptor(absDir)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(bugDesc.TriggerFileName) == 0 {
+			msg := fmt.Sprintf("The bug descriptor in %s does not name a "+
+				"trigger file", absDir)
+			return nil, errors.New(msg)
+		}
+
+		parents = append(parents, CrashParent{
+			Dir:         absDir,
+			TriggerPath: filepath.Join(absDir, bugDesc.TriggerFileName),
+			BugClass:    bugDesc.BugClass,
+			Bucket:      bugDesc.Bucket,
+			Lineage:     append(append([]string{}, bugDesc.Lineage...), absDir),
+		})
+	}
+
+	return parents, nil
+}
+
+// lineage relates test cases to the explored crashes they descend from. The
+// crashes are indexed by the paths of their triggers, which are the seeds of
+// the tests generated from them. It is empty if crashes are not being
+// explored.
+type lineage map[string]CrashParent
+
+func newLineageProcessor(s *session.Session) (ResultProcessor, error) {
+	return newLineage(s.Config)
+}
+
+// newLineage loads the crashes explored under cfg
+func newLineage(cfg *config.Config) (lineage, error) {
+	if cfg.TestProcessing.Mode != config.RUNMODE_EXPLORE_CRASHES {
+		return lineage{}, nil
+	}
+
+	parents, err := LoadCrashParents(cfg.ExploreCrashes.CrashDir)
+	if err != nil {
+		return nil, err
+	}
+
+	l := make(lineage)
+	for _, parent := range parents {
+		l[parent.TriggerPath] = parent
+	}
+
+	return l, nil
+}
+
+func (l lineage) Process(testCase *data.TestCase) (bool, error) {
+	l.relate(testCase)
+	return true, nil
+}
+
+// relate records the crash that testCase descends from and, if it triggers
+// a bug, how that bug relates to the crash. Tests generated from several
+// seeds are related to the first of them that is an explored crash.
+func (l lineage) relate(testCase *data.TestCase) {
+	for _, seed := range testCase.SeedFilePaths {
+		parent, ok := l[seed]
+		if !ok {
+			continue
+		}
+
+		testCase.ParentCrashDir = parent.Dir
+		testCase.ParentBucket = parent.Bucket
+		testCase.Lineage = parent.Lineage
+		if !testCase.BugFound {
+			return
+		}
+
+		if testCase.BugClass == parent.BugClass &&
+			testCase.Bucket == parent.Bucket {
+			testCase.ParentRelation = LINEAGE_VARIANT
+		} else {
+			testCase.ParentRelation = LINEAGE_SIBLING
+		}
+		return
+	}
+}
@@ -0,0 +1,140 @@
+package resultproc
+
+import (
+	"github.com/SeanHeelan/Malamute/data"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadCrashParents(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lineage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	crashDir := filepath.Join(dir, "1")
+	noTriggerDir := filepath.Join(dir, "2")
+	for _, d := range []string{crashDir, noTriggerDir} {
+		if err := os.Mkdir(d, 0777); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := WriteBugDescriptor(crashDir, BugDescriptor{
+		TriggerFileName: "t.php", BugClass: BUGCLASS_CRASH,
+		Bucket: "stack:a", Lineage: []string{"/old"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteBugDescriptor(noTriggerDir,
+		BugDescriptor{Bucket: "stack:b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	parents, err := LoadCrashParents([]string{crashDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []CrashParent{{
+		Dir:         crashDir,
+		TriggerPath: filepath.Join(crashDir, "t.php"),
+		BugClass:    BUGCLASS_CRASH,
+		Bucket:      "stack:a",
+		Lineage:     []string{"/old", crashDir},
+	}}
+	if !reflect.DeepEqual(parents, want) {
+		t.Errorf("LoadCrashParents = %+v, want %+v", parents, want)
+	}
+
+	for _, dirs := range [][]string{{noTriggerDir},
+		{filepath.Join(dir, "missing")}} {
+		if _, err := LoadCrashParents(dirs); err == nil {
+			t.Errorf("LoadCrashParents(%q) did not fail", dirs)
+		}
+	}
+}
+
+func TestLineageRelate(t *testing.T) {
+	parent := CrashParent{Dir: "/crashes/1", TriggerPath: "/crashes/1/t.php",
+		BugClass: BUGCLASS_CRASH, Bucket: "stack:a",
+		Lineage: []string{"/crashes/0", "/crashes/1"}}
+	other := CrashParent{Dir: "/crashes/2", TriggerPath: "/crashes/2/t.php",
+		BugClass: BUGCLASS_CRASH, Bucket: "stack:b",
+		Lineage: []string{"/crashes/2"}}
+	l := lineage{parent.TriggerPath: parent, other.TriggerPath: other}
+
+	tests := []struct {
+		name         string
+		testCase     data.TestCase
+		wantParent   string
+		wantRelation string
+	}{
+		{
+			name: "unrelated",
+			testCase: data.TestCase{SeedFilePaths: []string{"/seeds/a.php"},
+				BugFound: true, BugClass: BUGCLASS_CRASH, Bucket: "stack:a"},
+		},
+		{
+			name: "no bug",
+			testCase: data.TestCase{
+				SeedFilePaths: []string{parent.TriggerPath}},
+			wantParent: parent.Dir,
+		},
+		{
+			name: "variant",
+			testCase: data.TestCase{
+				SeedFilePaths: []string{parent.TriggerPath},
+				BugFound:      true, BugClass: BUGCLASS_CRASH,
+				Bucket: "stack:a"},
+			wantParent:   parent.Dir,
+			wantRelation: LINEAGE_VARIANT,
+		},
+		{
+			name: "other bucket",
+			testCase: data.TestCase{
+				SeedFilePaths: []string{parent.TriggerPath},
+				BugFound:      true, BugClass: BUGCLASS_CRASH,
+				Bucket: "stack:c"},
+			wantParent:   parent.Dir,
+			wantRelation: LINEAGE_SIBLING,
+		},
+		{
+			name: "other class",
+			testCase: data.TestCase{
+				SeedFilePaths: []string{parent.TriggerPath},
+				BugFound:      true, BugClass: "leak", Bucket: "stack:a"},
+			wantParent:   parent.Dir,
+			wantRelation: LINEAGE_SIBLING,
+		},
+		{
+			// The first seed that is an explored crash is the parent
+			name: "several seeds",
+			testCase: data.TestCase{SeedFilePaths: []string{"/seeds/a.php",
+				other.TriggerPath, parent.TriggerPath},
+				BugFound: true, BugClass: BUGCLASS_CRASH, Bucket: "stack:a"},
+			wantParent:   other.Dir,
+			wantRelation: LINEAGE_SIBLING,
+		},
+	}
+
+	for _, test := range tests {
+		testCase := test.testCase
+		l.relate(&testCase)
+
+		if testCase.ParentCrashDir != test.wantParent {
+			t.Errorf("%s: ParentCrashDir = %q, want %q", test.name,
+				testCase.ParentCrashDir, test.wantParent)
+		}
+		if testCase.ParentRelation != test.wantRelation {
+			t.Errorf("%s: ParentRelation = %q, want %q", test.name,
+				testCase.ParentRelation, test.wantRelation)
+		}
+		if len(test.wantParent) != 0 && !reflect.DeepEqual(
+			testCase.Lineage, l[filepath.Join(test.wantParent,
+				"t.php")].Lineage) {
+			t.Errorf("%s: Lineage = %q", test.name, testCase.Lineage)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package resultproc
+
+import (
+	"bytes"
+	"github.com/SeanHeelan/Malamute/data"
+	"github.com/SeanHeelan/Malamute/monitor"
+	"github.com/SeanHeelan/Malamute/session"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// Minimized test cases are named after their fuzz file, with this
+	// prefix
+	MINIMIZED_PREFIX = "min_"
+)
+
+// minimizeProcessor reduces crashing test cases by removing chunks of lines,
+// halving the chunk size each time no chunk can be removed, for as long as
+// the crash still reproduces. Reduction stops once the interpreter has been
+// run maxRuns times, or maxTime has passed, so that the chain is not held up
+// for long.
+type minimizeProcessor struct {
+	enabled  bool
+	maxRuns  int
+	maxTime  time.Duration
+	target   *monitor.Target
+	opts     monitor.RunOptions
+	analyser *crashAnalyser
+}
+
+func newMinimizeProcessor(s *session.Session) (ResultProcessor, error) {
+	p := minimizeProcessor{
+		enabled: s.Config.Minimize.Enabled,
+		maxRuns: s.Config.Minimize.MaxRuns,
+		maxTime: s.Config.Minimize.MaxTime.Duration,
+	}
+	if !p.enabled {
+		return &p, nil
+	}
+
+	var err error
+	if p.target, err = monitor.InterpreterTarget(s.Config); err != nil {
+		return nil, err
+	}
+
+	// Candidates are bucketed in the same way as the original crash
+	if p.analyser, err = newCrashAnalyser(s.Config); err != nil {
+		return nil, err
+	}
+
+	// Nothing but the result of each run is of interest, though a core is
+	// needed to bucket by backtrace
+	p.opts = monitor.Options(s.Config, "")
+	p.opts.Coverage = false
+	p.opts.Output.Spill = false
+
+	return &p, nil
+}
+
+// sameCrash indicates if candidate crashed in the same way as original. A
+// bucket made from a stack trace or backtrace must match exactly. Other
+// buckets include output that minimization is likely to change, so for those
+// the exit code and triage verdict are compared instead.
+func (p *minimizeProcessor) sameCrash(original *data.TestCase,
+	candidate data.TestCase) bool {
+
+	if Classify(candidate) != BUGCLASS_CRASH {
+		return false
+	}
+
+	p.analyser.analyse(&candidate)
+	if strings.HasPrefix(original.Bucket, BUCKET_PREFIX_STACK) ||
+		strings.HasPrefix(original.Bucket, BUCKET_PREFIX_BACKTRACE) {
+		return candidate.Bucket == original.Bucket
+	}
+
+	return candidate.ExitCode == original.ExitCode &&
+		candidate.Severity == original.Severity &&
+		candidate.SeverityReason == original.SeverityReason
+}
+
+// reproduces runs the interpreter on lines, written to path, and indicates
+// if the crash of testCase reproduced
+func (p *minimizeProcessor) reproduces(testCase *data.TestCase,
+	path string, lines [][]byte) (bool, error) {
+
+	if err := ioutil.WriteFile(path, bytes.Join(lines, []byte("\n")),
+		0777); err != nil {
+		return false, err
+	}
+
+	candidate := data.NewTestCase()
+	candidate.FuzzFilePath = path
+	candidate.SeedFilePaths = testCase.SeedFilePaths
+	opts := p.opts
+	opts.Timeout = testCase.Timeout
+	if err := p.target.Execute(&candidate, opts); err != nil {
+		return false, err
+	}
+	defer RemoveRunFiles(candidate)
+
+	return p.sameCrash(testCase, candidate), nil
+}
+
+func (p *minimizeProcessor) Process(testCase *data.TestCase) (bool, error) {
+	// Candidates are only run under the main interpreter
+	if !p.enabled || testCase.BugClass != BUGCLASS_CRASH ||
+		len(testCase.CrashedProfile) != 0 {
+		return true, nil
+	}
+
+	fileData, err := ioutil.ReadFile(testCase.FuzzFilePath)
+	if err != nil {
+		return true, err
+	}
+
+	lines := bytes.Split(fileData, []byte("\n"))
+	originalCount := len(lines)
+	minimizedPath := filepath.Join(filepath.Dir(testCase.FuzzFilePath),
+		MINIMIZED_PREFIX+filepath.Base(testCase.FuzzFilePath))
+
+	runs := 0
+	deadline := time.Now().Add(p.maxTime)
+	for chunk := len(lines) / 2; chunk != 0; chunk /= 2 {
+		for start := 0; start < len(lines) && runs < p.maxRuns &&
+			time.Now().Before(deadline); {
+			end := start + chunk
+			if end > len(lines) {
+				end = len(lines)
+			}
+
+			candidate := append(append([][]byte{}, lines[:start]...),
+				lines[end:]...)
+			runs++
+			same, err := p.reproduces(testCase, minimizedPath, candidate)
+			if err != nil {
+				os.Remove(minimizedPath)
+				return true, err
+			}
+
+			if same {
+				lines = candidate
+			} else {
+				start = end
+			}
+		}
+	}
+
+	if len(lines) == originalCount {
+		err := os.Remove(minimizedPath)
+		if err != nil && !os.IsNotExist(err) {
+			return true, err
+		}
+		return true, nil
+	}
+
+	// The last candidate tried may not have been kept
+	err = ioutil.WriteFile(minimizedPath, bytes.Join(lines, []byte("\n")),
+		0777)
+	if err != nil {
+		os.Remove(minimizedPath)
+		return true, err
+	}
+	testCase.MinimizedPath = minimizedPath
+
+	return true, nil
+}
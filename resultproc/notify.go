@@ -0,0 +1,74 @@
+package resultproc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/SeanHeelan/Malamute/data"
+	"github.com/SeanHeelan/Malamute/session"
+	"github.com/kballard/go-shellquote"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// notifyProcessor runs the configured command for each preserved bug, e.g.
+// to file it to a tracker
+type notifyProcessor struct {
+	args    []string
+	timeout time.Duration
+}
+
+func newNotifyProcessor(s *session.Session) (ResultProcessor, error) {
+	p := notifyProcessor{}
+	if len(s.Config.Notify.Command) == 0 {
+		return &p, nil
+	}
+
+	args, err := shellquote.Split(s.Config.Notify.Command)
+	if err != nil || len(args) == 0 {
+		msg := fmt.Sprintf("Failed to parse the notify command : %s",
+			s.Config.Notify.Command)
+		return nil, errors.New(msg)
+	}
+	p.args = args
+	p.timeout = s.Config.Notify.Timeout.Duration
+
+	return &p, nil
+}
+
+func (p *notifyProcessor) Process(testCase *data.TestCase) (bool, error) {
+	if len(p.args) == 0 || len(testCase.PreservationDir) == 0 {
+		return true, nil
+	}
+
+	args := append(p.args[1:len(p.args):len(p.args)],
+		testCase.PreservationDir)
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, p.args[0], args...)
+	cmd.Env = append(os.Environ(),
+		"MALAMUTE_BUG_CLASS="+testCase.BugClass,
+		"MALAMUTE_BUCKET="+testCase.Bucket,
+		"MALAMUTE_SEVERITY="+testCase.Severity,
+		"MALAMUTE_CRASHDB_LABEL="+testCase.CrashDBLabel)
+
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	if err := runCommand(ctx, cmd); err == context.DeadlineExceeded {
+		msg := fmt.Sprintf("%s took longer than %s on %s", p.args[0],
+			p.timeout, testCase.PreservationDir)
+		return true, errors.New(msg)
+	} else if err != nil {
+		msg := fmt.Sprintf("Error running %s on %s: %s: %s", p.args[0],
+			testCase.PreservationDir, err,
+			strings.TrimSpace(output.String()))
+		return true, errors.New(msg)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,77 @@
+package resultproc
+
+import (
+	"github.com/SeanHeelan/Malamute/config"
+	"github.com/SeanHeelan/Malamute/data"
+	"github.com/SeanHeelan/Malamute/session"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNotifyProcessor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	record := filepath.Join(dir, "notified")
+	tests := []struct {
+		name    string
+		command string
+		wantErr bool
+	}{
+		{
+			name: "notified",
+			command: "/bin/sh -c 'echo \"$1 $MALAMUTE_BUCKET\" > " +
+				record + "' notify",
+		},
+		{
+			name:    "failure",
+			command: "/bin/sh -c 'echo oops; exit 1'",
+			wantErr: true,
+		},
+		{
+			// A helper left running must not keep the command from
+			// being waited on
+			name:    "timed out",
+			command: "/bin/sh -c 'sleep 30 & sleep 30'",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		s := &session.Session{Config: &config.Config{}}
+		s.Config.Notify.Command = test.command
+		s.Config.Notify.Timeout.Duration = time.Second
+		p, err := newNotifyProcessor(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		testCase := data.TestCase{PreservationDir: "/s/crashes/1",
+			Bucket: "stack:a"}
+		start := time.Now()
+		keep, err := p.Process(&testCase)
+		if !keep {
+			t.Errorf("%s: the test case was dropped", test.name)
+		}
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: err = %v, want error %v", test.name, err,
+				test.wantErr)
+		}
+		if elapsed := time.Since(start); elapsed > 10*time.Second {
+			t.Errorf("%s: took %s", test.name, elapsed)
+		}
+	}
+
+	recorded, err := ioutil.ReadFile(record)
+	if err != nil || strings.TrimSpace(string(recorded)) !=
+		"/s/crashes/1 stack:a" {
+		t.Errorf("Notified with %q, %v", recorded, err)
+	}
+}
@@ -0,0 +1,160 @@
+package resultproc
+
+import (
+	"github.com/SeanHeelan/Malamute/config"
+	"github.com/SeanHeelan/Malamute/coverage"
+	"github.com/SeanHeelan/Malamute/data"
+	"github.com/SeanHeelan/Malamute/normalize"
+	"github.com/SeanHeelan/Malamute/session"
+	"github.com/SeanHeelan/Malamute/triage"
+	"log"
+	"os/exec"
+	"path/filepath"
+)
+
+// oracleProcessor decides whether each test case triggers a bug, and of
+// what class. Crashes are analysed further: their stack traces are
+// symbolized, backtraces are produced from their cores, and they are
+// bucketed and triaged. The number of new edges covered by each test case is
+// also counted.
+type oracleProcessor struct {
+	analyser *crashAnalyser
+	edges    *coverage.EdgeSet
+}
+
+// crashAnalyser symbolizes, buckets and triages crashes in the same way for
+// every processor that needs to
+type crashAnalyser struct {
+	normalizer *normalize.Normalizer
+	sym        *symbolizer
+	gdbPath    string
+	// gdbAvailable indicates that backtraces can be produced from cores
+	gdbAvailable bool
+	// gdbBinary, if not empty, is given to gdb in place of the interpreter
+	gdbBinary string
+}
+
+func newCrashAnalyser(cfg *config.Config) (*crashAnalyser, error) {
+	normalizer, err := normalize.New(cfg.Normalize.Preset,
+		cfg.Normalize.Replace, cfg.Normalize.DropLine,
+		cfg.Normalize.FloatPrecision)
+	if err != nil {
+		return nil, err
+	}
+
+	a := crashAnalyser{normalizer: normalizer, gdbPath: cfg.Cores.GdbPath}
+	if cfg.Cores.Enabled {
+		if _, err := exec.LookPath(cfg.Cores.GdbPath); err == nil {
+			a.gdbAvailable = true
+		} else {
+			log.Printf("%s is not available, so no backtraces will be "+
+				"produced from cores", cfg.Cores.GdbPath)
+		}
+	}
+
+	a.sym, err = newSymbolizer(cfg)
+	if err != nil {
+		log.Printf("Stack traces will not be symbolized: %s", err)
+	}
+
+	// gdb is given the unstripped binary, if there is one
+	if cfg.Symbolize.Enabled {
+		a.gdbBinary = cfg.Symbolize.Binary
+	}
+
+	return &a, nil
+}
+
+func newOracleProcessor(s *session.Session) (ResultProcessor, error) {
+	analyser, err := newCrashAnalyser(s.Config)
+	if err != nil {
+		return nil, err
+	}
+	p := oracleProcessor{analyser: analyser}
+
+	// The edges covered by earlier runs of the session are not new
+	if s.Config.Coverage.Enabled {
+		p.edges, err = coverage.OpenEdgeSet(filepath.Join(s.SessionDir,
+			session.EDGES_FILE))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &p, nil
+}
+
+func (p *oracleProcessor) Process(testCase *data.TestCase) (bool, error) {
+	if p.edges != nil {
+		var err error
+		testCase.NewEdges, err = p.edges.Add(testCase.Coverage)
+		if err != nil {
+			log.Printf("Could not record the edges covered by %s : %s",
+				testCase.FuzzFilePath, err)
+		}
+		// The coverage is no longer needed, and can be large
+		testCase.Coverage = nil
+	}
+
+	testCase.BugClass = Classify(*testCase)
+	testCase.BugFound = len(testCase.BugClass) != 0
+	if testCase.BugClass == BUGCLASS_CRASH {
+		p.analyser.analyse(testCase)
+	}
+
+	return true, nil
+}
+
+// analyse symbolizes, buckets and triages a crashing test case
+func (a *crashAnalyser) analyse(testCase *data.TestCase) {
+	if profile := crashedProfile(*testCase); len(profile) != 0 {
+		a.analyseProfile(testCase, profile)
+		return
+	}
+
+	var err error
+	if a.sym != nil {
+		testCase.SymbolizedStderr, err = a.sym.symbolize(testCase.RunStderr)
+		if err != nil {
+			log.Printf("Could not symbolize the stderr of %s : %s",
+				testCase.FuzzFilePath, err)
+		}
+	}
+
+	if a.gdbAvailable && len(testCase.CorePath) != 0 {
+		binary := testCase.ApplicationPath
+		if len(a.gdbBinary) != 0 {
+			binary = a.gdbBinary
+		}
+		testCase.Backtrace, err = gdbBacktrace(a.gdbPath,
+			binary, testCase.CorePath)
+		if err != nil {
+			log.Printf("Could not produce a backtrace for %s : %s",
+				testCase.FuzzFilePath, err)
+		}
+	}
+	testCase.Bucket = crashBucket(*testCase, a.normalizer)
+
+	verdict := triage.Classify(testCase.RunStderr, testCase.ExitCode)
+	testCase.Severity = verdict.Severity
+	testCase.SeverityReason = verdict.Reason
+}
+
+// analyseProfile symbolizes, buckets and triages a test case that only
+// crashed under the differential profile called profile, using the output
+// of that profile. No core is kept for a profile, so there is no backtrace.
+func (a *crashAnalyser) analyseProfile(testCase *data.TestCase,
+	profile string) {
+
+	crash := *testCase
+	crash.RunStderr = testCase.DifferentialStderr[profile]
+	crash.ExitCode = testCase.DifferentialExitCodes[profile]
+	crash.CorePath = ""
+	a.analyse(&crash)
+
+	testCase.CrashedProfile = profile
+	testCase.SymbolizedStderr = crash.SymbolizedStderr
+	testCase.Bucket = crash.Bucket
+	testCase.Severity = crash.Severity
+	testCase.SeverityReason = crash.SeverityReason
+}
@@ -0,0 +1,93 @@
+package resultproc
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/SeanHeelan/Malamute/config"
+	"github.com/kballard/go-shellquote"
+	"os"
+	"strings"
+)
+
+const (
+	REPRO_NAME = "repro.sh"
+)
+
+// shellArg converts an argument recorded by the execution monitor into a
+// quoted shell word, with the fuzz file and fuzz data markers replaced by
+// references to the trigger file
+func shellArg(arg string) string {
+	var buf bytes.Buffer
+	fileParts := strings.Split(arg, config.INTERPRETER_ARGS_FUZZ_FILE_MARKER)
+	for i, filePart := range fileParts {
+		if i > 0 {
+			buf.WriteString(`"$TRIGGER"`)
+		}
+
+		dataParts := strings.Split(filePart,
+			config.INTERPRETER_ARGS_FUZZ_DATA_MARKER)
+		for j, dataPart := range dataParts {
+			if j > 0 {
+				buf.WriteString(`"$(cat "$TRIGGER")"`)
+			}
+
+			if len(dataPart) != 0 {
+				buf.WriteString(shellquote.Join(dataPart))
+			}
+		}
+	}
+
+	if buf.Len() == 0 {
+		return "''"
+	}
+
+	return buf.String()
+}
+
+// writeReproScript creates a shell script at path that re-runs the
+// application on the trigger described by bugDesc, in the same way as the
+// execution monitor did. The script is expected to live in the same
+// directory as the trigger. delimiter is only used if the test was fed to a
+// persistent interpreter.
+func writeReproScript(path string, bugDesc BugDescriptor,
+	delimiter string) error {
+
+	fd, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0777)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	w := bufio.NewWriter(fd)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "#!/bin/sh")
+	fmt.Fprintf(w, "# Reproduces the bug described in %s\n", BUG_DESC_NAME)
+	fmt.Fprintln(w, `cd "$(dirname "$0")"`)
+	fmt.Fprintf(w, "TRIGGER=%s\n\n", shellquote.Join("./"+
+		bugDesc.TriggerFileName))
+
+	cmdLine := []string{"env"}
+	for _, envMod := range bugDesc.ApplicationEnv {
+		cmdLine = append(cmdLine, shellquote.Join(envMod))
+	}
+	cmdLine = append(cmdLine, shellquote.Join(bugDesc.ApplicationPath))
+	for _, arg := range bugDesc.ApplicationArgs {
+		cmdLine = append(cmdLine, shellArg(arg))
+	}
+	cmd := strings.Join(cmdLine, " ")
+
+	switch bugDesc.InputMode {
+	case config.INPUT_MODE_STDIN:
+		fmt.Fprintf(w, "%s < \"$TRIGGER\"\n", cmd)
+	case config.MONITOR_PERSISTENT:
+		quotedDelim := shellquote.Join(delimiter)
+		fmt.Fprintf(w, "{ printf '%%s\\n' %s; cat \"$TRIGGER\"; "+
+			"printf '\\n%%s\\n' %s; } | %s\n", quotedDelim, quotedDelim, cmd)
+	default:
+		fmt.Fprintln(w, cmd)
+	}
+
+	return nil
+}
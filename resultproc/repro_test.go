@@ -0,0 +1,95 @@
+package resultproc
+
+import (
+	"github.com/SeanHeelan/Malamute/config"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestShellArg(t *testing.T) {
+	file := config.INTERPRETER_ARGS_FUZZ_FILE_MARKER
+	data := config.INTERPRETER_ARGS_FUZZ_DATA_MARKER
+
+	tests := []struct {
+		arg  string
+		want string
+	}{
+		{"-n", "-n"},
+		{"", "''"},
+		{"a b", `'a b'`},
+		{file, `"$TRIGGER"`},
+		{"--file=" + file, `--file="$TRIGGER"`},
+		{data, `"$(cat "$TRIGGER")"`},
+		{"x " + data + " y", `'x '"$(cat "$TRIGGER")"' y'`},
+	}
+
+	for _, test := range tests {
+		if got := shellArg(test.arg); got != test.want {
+			t.Errorf("shellArg(%q) = %s, want %s", test.arg, got, test.want)
+		}
+	}
+}
+
+// The script runs the application as the execution monitor did, in each
+// input mode
+func TestWriteReproScript(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	trigger := "t 1.php"
+	if err := ioutil.WriteFile(filepath.Join(dir, trigger),
+		[]byte("echo 'hi';"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		args      []string
+		inputMode string
+		want      string
+	}{
+		{"file", []string{"-c", `cat "$1"`, "sh",
+			config.INTERPRETER_ARGS_FUZZ_FILE_MARKER},
+			config.INPUT_MODE_FILE, "echo 'hi';"},
+		{"data", []string{"-c", `printf '%s' "$1"`, "sh",
+			config.INTERPRETER_ARGS_FUZZ_DATA_MARKER},
+			config.INPUT_MODE_ARG, "echo 'hi';"},
+		{"stdin", []string{"-c", "cat"}, config.INPUT_MODE_STDIN,
+			"echo 'hi';"},
+		{"persistent", []string{"-c", "cat"}, config.MONITOR_PERSISTENT,
+			"--D--\necho 'hi';\n--D--\n"},
+	}
+
+	for _, test := range tests {
+		bugDesc := BugDescriptor{
+			TriggerFileName: trigger,
+			ApplicationPath: "/bin/sh",
+			ApplicationEnv:  []string{"REPRO=1"},
+			ApplicationArgs: test.args,
+			InputMode:       test.inputMode,
+		}
+
+		path := filepath.Join(dir, REPRO_NAME)
+		if err := writeReproScript(path, bugDesc, "--D--"); err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+
+		output, err := exec.Command(path).Output()
+		if err != nil {
+			t.Errorf("%s: running the script failed: %s", test.name, err)
+			continue
+		}
+		if got := strings.TrimSuffix(string(output), "\n"); got !=
+			strings.TrimSuffix(test.want, "\n") {
+			t.Errorf("%s: output %q, want %q", test.name, output,
+				test.want)
+		}
+	}
+}
@@ -5,8 +5,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/SeanHeelan/Malamute/crashdb"
 	"github.com/SeanHeelan/Malamute/data"
+	"github.com/SeanHeelan/Malamute/fs"
 	"github.com/SeanHeelan/Malamute/monitor"
+	"github.com/SeanHeelan/Malamute/normalize"
+	"github.com/SeanHeelan/Malamute/session"
 	"io/ioutil"
 	"log"
 	"os"
@@ -26,6 +30,26 @@ const (
 	BUG_DESC_NAME = "bugdesc.json"
 	STDOUT_NAME   = "stdout.data"
 	STDERR_NAME   = "stderr.data"
+	DIFF_NAME     = "stdout.diff"
+	// The full output, when stdout.data or stderr.data were truncated
+	STDOUT_FULL_NAME = "stdout.full"
+	STDERR_FULL_NAME = "stderr.full"
+	// Outputs of differential profiles are stored as <profile> + these
+	PROFILE_STDOUT_EXT = ".stdout"
+	PROFILE_STDERR_EXT = ".stderr"
+
+	// The application crashed or was caught by a sanitizer
+	BUGCLASS_CRASH = "crash"
+	// The differential profiles disagreed on the output of the test
+	BUGCLASS_DIVERGENCE = "divergence"
+	// An unmutated seed did not produce its expected result
+	BUGCLASS_REGRESSION = "regression"
+	// The test timed out, and again when re-run with a longer timeout
+	BUGCLASS_HANG = "hang"
+	// The test used far more CPU time than its seed
+	BUGCLASS_PERFORMANCE = "performance"
+	// The test used far more memory than its seed
+	BUGCLASS_MEMORY = "memory"
 )
 
 // BugDescriptor provides information on a test case that is considered
@@ -34,6 +58,16 @@ const (
 // information. All file names are relative to the directory in which the
 // marshalled BugDescriptor is found.
 type BugDescriptor struct {
+	// BugClass is one of the BUGCLASS_* constants
+	BugClass string
+	// Bucket identifies crashes that appear to be the same bug. See
+	// crashBucket for how it is derived.
+	Bucket string
+	// Severity is one of the triage.SEVERITY_* constants, assigned to
+	// crashes by an automatic triage of their output. SeverityReason
+	// explains it.
+	Severity       string
+	SeverityReason string
 	// TriggerFileName specifies the name of the file that triggers
 	// the bug.
 	TriggerFileName string
@@ -49,18 +83,40 @@ type BugDescriptor struct {
 	// ApplicationEnv specifies any extra environment variables that were
 	// used during the execution of the test
 	ApplicationEnv []string
+	// ApplicationArgs specifies the arguments the application was run with.
+	// The fuzz file path and contents are represented by the corresponding
+	// markers from the config package.
+	ApplicationArgs []string
+	// InputMode specifies how the trigger was provided to the application
+	InputMode string
+	// ReproScriptName specifies the name of a shell script that re-runs the
+	// application on the trigger
+	ReproScriptName string
 	// RunExitCode is the exit code recorded after running the application
 	// on the trigger file
 	RunExitCode int
-	// RunExeSeconds specifies how long the test ran for before the bug was
+	// RunWallTime specifies how long the test ran for before the bug was
 	// triggered
-	RunExeSeconds int
+	RunWallTime time.Duration
+	// RunCPUTime specifies the CPU time, user and system, used by the test
+	RunCPUTime time.Duration
+	// RunMaxRSS specifies the peak resident set size of the test in
+	// kilobytes
+	RunMaxRSS int64
 	// RunStdoutData contains the path to a file holding the data recorded
 	// from STDOUT during the execution of the application on the test case
 	RunStdoutPath string
 	// RunStderrData contains the path to a file holding the data recorded
 	// from STDERR during the execution of the application on the test case
 	RunStderrPath string
+	// StdoutTruncated and StderrTruncated indicate that output was
+	// discarded from the middle of the stdout and stderr data
+	StdoutTruncated bool
+	StderrTruncated bool
+	// FullStdoutName and FullStderrName specify the names of files holding
+	// the full output, if it was truncated
+	FullStdoutName string
+	FullStderrName string
 	// OverallTestCaseCount gives the total number of tests generated,
 	// including this one, at the time that the bug was recorded
 	OverallTestCaseCount int
@@ -68,6 +124,75 @@ type BugDescriptor struct {
 	// the seed file of the test case, including this one, at the time that
 	// the bug was recorded
 	SeedFileTestCaseCounts map[string]int
+	// DivergentProfiles lists the differential profiles whose results
+	// differed from those of the main interpreter
+	DivergentProfiles []string
+	// DifferentialExitCodes gives the exit code of the application under
+	// each differential profile
+	DifferentialExitCodes map[string]int
+	// DifferentialStdoutPaths contains the paths to files holding the data
+	// recorded from STDOUT under each differential profile, and
+	// DifferentialStderrPaths those recorded from STDERR
+	DifferentialStdoutPaths map[string]string
+	DifferentialStderrPaths map[string]string
+	// TimedOutProfiles lists the differential profiles that timed out
+	TimedOutProfiles []string
+	// CrashedProfile names the differential profile the application
+	// crashed under, if it did not crash under the main interpreter
+	CrashedProfile string
+	// ExpectationMismatch describes how the result of the test differed
+	// from the expected result of its seed
+	ExpectationMismatch string
+	// SymbolizedStderrName specifies the name of a file holding the stderr
+	// output with its raw stack frames symbolized
+	SymbolizedStderrName string
+	// ClassifierNotes holds the notes returned by the user-supplied
+	// classifier
+	ClassifierNotes string
+	// ParentCrashDir specifies the crash directory of the explored crash
+	// that the trigger descends from, and ParentBucket its bucket.
+	// ParentRelation is one of the LINEAGE_* constants.
+	ParentCrashDir string
+	ParentBucket   string
+	ParentRelation string
+	// Lineage lists the crash directories the trigger descends from,
+	// oldest first and ending with ParentCrashDir
+	Lineage []string
+	// CrashDBLabel is one of the crashdb.LABEL_* constants, indicating
+	// whether the bucket was already in the crash database when the bug was
+	// found. KnownCrashDir is the first crash of the bucket in the
+	// database, if it was.
+	CrashDBLabel  string
+	KnownCrashDir string
+	// BisectLastGood and BisectFirstBad specify the last build in which
+	// the trigger did not reproduce the bug, and the first in which it
+	// did, as found by the bisect command. BisectLastGood is empty if the
+	// bug reproduced in every build.
+	BisectLastGood string
+	BisectFirstBad string
+	// MinimizedFileName specifies the name of a reduced version of the
+	// trigger file, if one was produced
+	MinimizedFileName string
+	// BacktraceName specifies the name of a file holding the backtrace
+	// produced by gdb from the core file
+	BacktraceName string
+	// CoreName specifies the name of the core file, if it was kept
+	CoreName string
+	// Timeout is the time the test was allowed to run for
+	Timeout time.Duration
+	// HangTimeout is the longer timeout used to confirm a hang
+	HangTimeout time.Duration
+	// HangKind specifies whether a hang was busy or blocked
+	HangKind string
+	// CPUPercent gives the CPU usage of a hang while it was confirmed
+	CPUPercent int
+	// HangExited indicates that the test timed out, but exited with a
+	// non-zero exit code when re-run to confirm the hang. The results are
+	// those of that run.
+	HangExited bool
+	// DiffPath contains the path to a file holding the differences between
+	// the output of the main interpreter and the divergent profiles
+	DiffPath string
 }
 
 func NewBugDescriptor(testCase data.TestCase) BugDescriptor {
@@ -75,158 +200,468 @@ func NewBugDescriptor(testCase data.TestCase) BugDescriptor {
 
 	b.SeedFileTestCaseCounts = testCase.SeedFuzzCounts
 	b.OverallTestCaseCount = testCase.TotalFuzzCount
-	b.RunExeSeconds = testCase.ExeSeconds
+	b.RunWallTime = testCase.WallTime
+	b.RunCPUTime = testCase.UserTime + testCase.SysTime
+	b.RunMaxRSS = testCase.MaxRSS
 	b.RunExitCode = testCase.ExitCode
 	b.ApplicationEnv = testCase.ApplicationEnv
 	b.ApplicationPath = testCase.ApplicationPath
+	b.ApplicationArgs = testCase.ApplicationArgs
+	b.InputMode = testCase.InputMode
+	b.DivergentProfiles = testCase.DivergentProfiles
+	b.DifferentialExitCodes = testCase.DifferentialExitCodes
+	b.TimedOutProfiles = testCase.TimedOutProfiles
+	b.CrashedProfile = testCase.CrashedProfile
+	b.Bucket = testCase.Bucket
+	b.Severity = testCase.Severity
+	b.SeverityReason = testCase.SeverityReason
+	b.ClassifierNotes = testCase.ClassifierNotes
+	b.ExpectationMismatch = testCase.ExpectationMismatch
+	b.Timeout = testCase.Timeout
+	b.HangTimeout = testCase.HangTimeout
+	b.HangKind = testCase.HangKind
+	b.CPUPercent = testCase.CPUPercent
+	b.HangExited = testCase.HangExited
+	b.StdoutTruncated = testCase.StdoutTruncated
+	b.StderrTruncated = testCase.StderrTruncated
 	b.OriginalSeedPaths = testCase.SeedFilePaths
+	b.ParentCrashDir = testCase.ParentCrashDir
+	b.ParentBucket = testCase.ParentBucket
+	b.ParentRelation = testCase.ParentRelation
+	b.Lineage = testCase.Lineage
+	b.CrashDBLabel = testCase.CrashDBLabel
+	b.KnownCrashDir = testCase.KnownCrashDir
 
 	return b
 }
 
-// LogFile saves crashing tests cases to the preservation directory and
-// simply deletes non-crashing test cases. Crashing tests are stored in
-// their own sub-directory of the preservation directory, along with the
-// seed test from which they were generated. In this sub-directory LogFile
-// will also store any data written to stderr and stdout during the
-// execution of a crashing test case.
-func LogFile(preserveDir string, in chan data.TestCase,
-	out chan data.TestCase, errOut chan error) {
-
-	for {
-		testCase := <-in
-		if len(testCase.SeedFilePaths) == 0 {
-			close(out)
-			break
-		}
-
-		switch testCase.ExitCode {
-		case SIGABRT, SIGFPE, SIGKILL, SIGSEGV, SIGTERM, SIGILL,
-			monitor.ASAN_EXITCODE:
-			testCase.BugFound = true
-			bugDesc := NewBugDescriptor(testCase)
-
-			// Each crash gets its own output directory
-			now := time.Now()
-			fuzzFileBase := filepath.Base(testCase.FuzzFilePath)
-			crashDirName := fmt.Sprintf("%d_%s", now.Unix(), fuzzFileBase)
-			crashDirPath := filepath.Join(preserveDir, crashDirName)
-			err := os.Mkdir(crashDirPath, 0777)
-
-			if err != nil {
-				msg := fmt.Sprintf("Could not create output directory %s"+
-					"for the crash file %s", crashDirPath,
-					testCase.FuzzFilePath)
-				errOut <- errors.New(msg)
-				continue
-			}
+// ReadBugDescriptor loads the BugDescriptor stored in crashDir
+func ReadBugDescriptor(crashDir string) (BugDescriptor, error) {
+	var bugDesc BugDescriptor
+	bugDescPath := filepath.Join(crashDir, BUG_DESC_NAME)
+	jsonData, err := ioutil.ReadFile(bugDescPath)
+	if err != nil {
+		return bugDesc, err
+	}
 
-			testCase.PreservationDir = crashDirPath
-
-			// Store the original files
-			for _, seedFilePath := range testCase.SeedFilePaths {
-				origPathWithSlashes := filepath.ToSlash(seedFilePath)
-				origPathWithUScores := strings.Replace(origPathWithSlashes, "/", "_", -1)
-				storagePathForOrig := filepath.Join(crashDirPath, origPathWithUScores)
-
-				fileData, err := ioutil.ReadFile(seedFilePath)
-				if err != nil {
-					msg := fmt.Sprintf("Could not read the original file %s"+
-						"for the crash file %s. Error %s", seedFilePath,
-						testCase.FuzzFilePath, err)
-					errOut <- errors.New(msg)
-					continue
-				}
-
-				err = ioutil.WriteFile(storagePathForOrig, fileData, 0777)
-				if err != nil {
-					msg := fmt.Sprintf("Could not move the file %s to %s. Error %s",
-						seedFilePath, storagePathForOrig, err)
-					errOut <- errors.New(msg)
-					continue
-				}
-
-				bugDesc.SeedFileNames = append(bugDesc.SeedFileNames, origPathWithUScores)
-			}
+	if err := json.Unmarshal(jsonData, &bugDesc); err != nil {
+		msg := fmt.Sprintf("Could not parse %s. Error %s", bugDescPath, err)
+		return bugDesc, errors.New(msg)
+	}
 
-			// Store the fuzz file
-			fileBase := filepath.Base(testCase.FuzzFilePath)
-			newPath := filepath.Join(crashDirPath, fileBase)
-			err = os.Rename(testCase.FuzzFilePath, newPath)
-			if err != nil {
-				msg := fmt.Sprintf("Could not move the fuzz file %s to %s. Error %s",
-					testCase.FuzzFilePath, newPath, err)
-				errOut <- errors.New(msg)
-				continue
-			}
+	return bugDesc, nil
+}
 
-			bugDesc.TriggerFileName = fileBase
+// WriteBugDescriptor stores bugDesc in crashDir, replacing any descriptor
+// already there
+func WriteBugDescriptor(crashDir string, bugDesc BugDescriptor) error {
+	bugDescPath := filepath.Join(crashDir, BUG_DESC_NAME)
+	jsonData, err := json.Marshal(bugDesc)
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling data: %s", err)
+		return errors.New(msg)
+	}
 
-			// Store the stdout data
-			stdoutPath := filepath.Join(crashDirPath, STDOUT_NAME)
-			stdoutFd, err := os.Create(stdoutPath)
-			if err != nil {
-				errOut <- err
-			}
-			writer := bufio.NewWriter(stdoutFd)
-			for _, line := range testCase.RunStdout {
-				fmt.Fprintln(writer, line)
-			}
-			writer.Flush()
-			stdoutFd.Close()
-			bugDesc.RunStdoutPath = stdoutPath
-			// Store the stderr data
-			stderrPath := filepath.Join(crashDirPath, STDERR_NAME)
-			stderrFd, err := os.Create(stderrPath)
-			if err != nil {
-				errOut <- err
-			}
-			writer = bufio.NewWriter(stderrFd)
-			for _, line := range testCase.RunStderr {
-				fmt.Fprintln(writer, line)
-			}
-			writer.Flush()
-			stderrFd.Close()
-			bugDesc.RunStderrPath = stderrPath
-
-			// Store the bug descriptor
-			bugDescPath := filepath.Join(crashDirPath, BUG_DESC_NAME)
-			fd, err := os.Create(bugDescPath)
-			if err != nil {
-				msg := fmt.Sprintf("Failed to create file %s: %s",
-					bugDescPath, err)
-				errOut <- errors.New(msg)
-				continue
-			}
+	if err := ioutil.WriteFile(bugDescPath, jsonData, 0666); err != nil {
+		msg := fmt.Sprintf("Failed to write bug descriptor %s: %s",
+			bugDescPath, err)
+		return errors.New(msg)
+	}
 
-			if jsonData, err := json.Marshal(bugDesc); err != nil {
-				msg := fmt.Sprintf("Error marshalling data: %s", err)
-				errOut <- errors.New(msg)
-				fd.Close()
-				continue
-			} else {
-				if _, err := fd.Write(jsonData); err != nil {
-					msg := fmt.Sprintf("Failed to write bug descriptor: %s",
-						err)
-					errOut <- errors.New(msg)
-					fd.Close()
-					continue
-				}
-			}
+	return nil
+}
+
+// isCrash indicates if exitCode is that of a crash
+func isCrash(exitCode int) bool {
+	switch exitCode {
+	case SIGABRT, SIGFPE, SIGKILL, SIGSEGV, SIGTERM, SIGILL,
+		monitor.ASAN_EXITCODE:
+		return true
+	}
+
+	return false
+}
+
+// crashedProfile returns the first differential profile that testCase
+// crashed under, if it did not crash under the main interpreter, and
+// otherwise the empty string
+func crashedProfile(testCase data.TestCase) string {
+	if isCrash(testCase.ExitCode) {
+		return ""
+	}
+
+	// A profile that crashed has a different exit code to the main
+	// interpreter, and so is divergent
+	for _, name := range testCase.DivergentProfiles {
+		if isCrash(testCase.DifferentialExitCodes[name]) {
+			return name
+		}
+	}
+
+	return ""
+}
+
+// Classify returns the class of bug triggered by testCase, or the empty
+// string if it doesn't appear to trigger one. A crash under any of the
+// differential profiles is a crash.
+func Classify(testCase data.TestCase) string {
+	if testCase.TestTimedOut {
+		if testCase.HangConfirmed {
+			return BUGCLASS_HANG
+		}
+		return ""
+	}
+
+	if isCrash(testCase.ExitCode) || len(crashedProfile(testCase)) != 0 {
+		return BUGCLASS_CRASH
+	}
+
+	if len(testCase.DivergentProfiles) != 0 {
+		return BUGCLASS_DIVERGENCE
+	}
+
+	// Mutated tests are expected to differ from their seeds, so only
+	// unmutated ones are reported
+	if len(testCase.ExpectationMismatch) != 0 && testCase.Unmutated {
+		return BUGCLASS_REGRESSION
+	}
+
+	switch testCase.ResourceAnomaly {
+	case monitor.RESOURCE_CPU:
+		return BUGCLASS_PERFORMANCE
+	case monitor.RESOURCE_MEMORY:
+		return BUGCLASS_MEMORY
+	}
+
+	return ""
+}
+
+// writeLines stores lines in a new file at path
+func writeLines(path string, lines []string) error {
+	fd, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	writer := bufio.NewWriter(fd)
+	for _, line := range lines {
+		fmt.Fprintln(writer, line)
+	}
 
-			fd.Close()
+	return writer.Flush()
+}
+
+// preserveProcessor saves test cases that trigger bugs to the preservation
+// directory, or to the hangs directory for hangs. Each is stored in its own
+// sub-directory along with the seed tests from which it was generated, any
+// data written to stderr and stdout during its execution, and the output of
+// any differential profiles. Preserved crashes are added to the crash
+// database, if there is one. Test cases that cover new edges are added to
+// the corpus.
+type preserveProcessor struct {
+	s       *session.Session
+	crashDB *crashdb.DB
+}
 
-			out <- testCase
+func newPreserveProcessor(s *session.Session) (ResultProcessor, error) {
+	p := preserveProcessor{s: s}
+	if len(s.Config.CrashDB.Dir) != 0 {
+		var err error
+		if p.crashDB, err = crashdb.Open(s.Config.CrashDB.Dir); err != nil {
+			return nil, err
+		}
+	}
+
+	return &p, nil
+}
+
+// addToCrashDB records the preserved crash testCase in the crash database
+func (p *preserveProcessor) addToCrashDB(testCase *data.TestCase) error {
+	crashDir, err := filepath.Abs(testCase.PreservationDir)
+	if err != nil {
+		return err
+	}
+
+	sessionDir, err := filepath.Abs(p.s.SessionDir)
+	if err != nil {
+		return err
+	}
+
+	return p.crashDB.Add(crashdb.Entry{
+		Bucket:         testCase.Bucket,
+		BugClass:       testCase.BugClass,
+		Severity:       testCase.Severity,
+		SeverityReason: testCase.SeverityReason,
+		CrashDir:       crashDir,
+		SessionDir:     sessionDir,
+		Interpreter:    testCase.ApplicationPath,
+		Time:           time.Now(),
+	})
+}
+
+func (p *preserveProcessor) Process(testCase *data.TestCase) (bool, error) {
+	if testCase.BugFound {
+		if err := preserve(p.s, testCase, testCase.BugClass); err != nil {
+			return true, err
+		}
+
+		if p.crashDB != nil && len(testCase.Bucket) != 0 {
+			return true, p.addToCrashDB(testCase)
+		}
+		return true, nil
+	}
+
+	if testCase.NewEdges != 0 {
+		corpusPath, err := addToCorpus(p.s.CorpusDir, testCase.FuzzFilePath)
+		if err != nil {
+			msg := fmt.Sprintf("Could not add %s to the corpus. Error %s",
+				testCase.FuzzFilePath, err)
+			return true, errors.New(msg)
+		}
+		testCase.CorpusPath = corpusPath
+	}
+
+	return true, nil
+}
+
+// RemoveRunFiles removes the files, other than the fuzz file, left by the
+// execution of testCase, such as its core and spilled output
+func RemoveRunFiles(testCase data.TestCase) {
+	paths := []string{testCase.CorePath, testCase.StdoutSpillPath,
+		testCase.StderrSpillPath}
+	for _, path := range paths {
+		if len(path) == 0 {
 			continue
 		}
 
-		testCase.BugFound = false
-		err := os.Remove(testCase.FuzzFilePath)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Printf("Failed to remove %s : %s", path, err)
+		}
+	}
+}
+
+// moveSpill moves the file holding the full output of a stream, if there
+// is one, into crashDirPath under name. The name is returned, or an empty
+// string if there was nothing to move.
+func moveSpill(spillPath string, crashDirPath string,
+	name string) (string, error) {
+
+	if len(spillPath) == 0 {
+		return "", nil
+	}
+
+	if err := os.Rename(spillPath, filepath.Join(crashDirPath,
+		name)); err != nil {
+		msg := fmt.Sprintf("Could not move %s to %s. Error %s", spillPath,
+			crashDirPath, err)
+		return "", errors.New(msg)
+	}
+
+	return name, nil
+}
+
+// preserve moves testCase into its own sub-directory of the preservation
+// directory, along with its seeds, its output and a descriptor of the bug
+func preserve(s *session.Session, testCase *data.TestCase,
+	bugClass string) error {
+
+	bugDesc := NewBugDescriptor(*testCase)
+	bugDesc.BugClass = bugClass
+
+	// Each bug gets its own output directory
+	now := time.Now()
+	fuzzFileBase := filepath.Base(testCase.FuzzFilePath)
+	crashDirName := fmt.Sprintf("%d_%s", now.Unix(), fuzzFileBase)
+	preserveDir := s.PreservationDir
+	if bugClass == BUGCLASS_HANG {
+		preserveDir = s.HangsDir
+	}
+	crashDirPath := filepath.Join(preserveDir, crashDirName)
+	err := os.Mkdir(crashDirPath, 0777)
+
+	if err != nil {
+		msg := fmt.Sprintf("Could not create output directory %s"+
+			"for the crash file %s", crashDirPath,
+			testCase.FuzzFilePath)
+		return errors.New(msg)
+	}
+
+	// Store the original files
+	for _, seedFilePath := range testCase.SeedFilePaths {
+		origPathWithSlashes := filepath.ToSlash(seedFilePath)
+		origPathWithUScores := strings.Replace(origPathWithSlashes, "/", "_", -1)
+		storagePathForOrig := filepath.Join(crashDirPath, origPathWithUScores)
+
+		fileData, err := ioutil.ReadFile(seedFilePath)
 		if err != nil {
-			log.Printf("Failed to remove %s\n. Removed by the test?",
-				testCase.FuzzFilePath)
+			msg := fmt.Sprintf("Could not read the original file %s"+
+				"for the crash file %s. Error %s", seedFilePath,
+				testCase.FuzzFilePath, err)
+			return errors.New(msg)
 		}
 
-		out <- testCase
+		err = ioutil.WriteFile(storagePathForOrig, fileData, 0777)
+		if err != nil {
+			msg := fmt.Sprintf("Could not move the file %s to %s. Error %s",
+				seedFilePath, storagePathForOrig, err)
+			return errors.New(msg)
+		}
+
+		bugDesc.SeedFileNames = append(bugDesc.SeedFileNames, origPathWithUScores)
 	}
+
+	// Store the fuzz file
+	fileBase := filepath.Base(testCase.FuzzFilePath)
+	newPath := filepath.Join(crashDirPath, fileBase)
+	err = os.Rename(testCase.FuzzFilePath, newPath)
+	if err != nil {
+		msg := fmt.Sprintf("Could not move the fuzz file %s to %s. Error %s",
+			testCase.FuzzFilePath, newPath, err)
+		return errors.New(msg)
+	}
+
+	bugDesc.TriggerFileName = fileBase
+
+	if len(testCase.MinimizedPath) != 0 {
+		minimizedName := MINIMIZED_PREFIX + fileBase
+		minimizedPath := filepath.Join(crashDirPath, minimizedName)
+		if err := os.Rename(testCase.MinimizedPath,
+			minimizedPath); err != nil {
+			msg := fmt.Sprintf("Could not move the minimized file %s to "+
+				"%s. Error %s", testCase.MinimizedPath, minimizedPath, err)
+			return errors.New(msg)
+		}
+		testCase.MinimizedPath = minimizedPath
+		bugDesc.MinimizedFileName = minimizedName
+	}
+
+	// Store a script to reproduce the bug
+	reproPath := filepath.Join(crashDirPath, REPRO_NAME)
+	err = writeReproScript(reproPath, bugDesc, s.Config.Persistent.Delimiter)
+	if err != nil {
+		msg := fmt.Sprintf("Could not write %s. Error %s", reproPath, err)
+		return errors.New(msg)
+	}
+	bugDesc.ReproScriptName = REPRO_NAME
+
+	// Store the stdout and stderr data
+	stdoutPath := filepath.Join(crashDirPath, STDOUT_NAME)
+	if err := writeLines(stdoutPath, testCase.RunStdout); err != nil {
+		return err
+	}
+	bugDesc.RunStdoutPath = stdoutPath
+
+	stderrPath := filepath.Join(crashDirPath, STDERR_NAME)
+	if err := writeLines(stderrPath, testCase.RunStderr); err != nil {
+		return err
+	}
+	bugDesc.RunStderrPath = stderrPath
+
+	bugDesc.FullStdoutName, err = moveSpill(testCase.StdoutSpillPath,
+		crashDirPath, STDOUT_FULL_NAME)
+	if err != nil {
+		return err
+	}
+	testCase.StdoutSpillPath = ""
+
+	bugDesc.FullStderrName, err = moveSpill(testCase.StderrSpillPath,
+		crashDirPath, STDERR_FULL_NAME)
+	if err != nil {
+		return err
+	}
+	testCase.StderrSpillPath = ""
+
+	if testCase.SymbolizedStderr != nil {
+		symbolizedPath := filepath.Join(crashDirPath, STDERR_SYMBOLIZED_NAME)
+		err := writeLines(symbolizedPath, testCase.SymbolizedStderr)
+		if err != nil {
+			return err
+		}
+		bugDesc.SymbolizedStderrName = STDERR_SYMBOLIZED_NAME
+	}
+
+	// Store the backtrace, and the core if it is to be kept
+	if len(testCase.Backtrace) != 0 {
+		backtracePath := filepath.Join(crashDirPath, BACKTRACE_NAME)
+		if err := writeLines(backtracePath, testCase.Backtrace); err != nil {
+			return err
+		}
+		bugDesc.BacktraceName = BACKTRACE_NAME
+	}
+
+	if len(testCase.CorePath) != 0 {
+		if s.Config.Cores.KeepCores {
+			corePath := filepath.Join(crashDirPath, CORE_NAME)
+			if err := os.Rename(testCase.CorePath, corePath); err != nil {
+				return err
+			}
+			testCase.CorePath = corePath
+			bugDesc.CoreName = CORE_NAME
+		} else if err := os.Remove(testCase.CorePath); err != nil {
+			log.Printf("Failed to remove %s : %s", testCase.CorePath, err)
+		}
+	}
+
+	// Store the output of each differential profile, and how the divergent
+	// ones differ from the main interpreter
+	if len(testCase.DifferentialStdout) != 0 {
+		bugDesc.DifferentialStdoutPaths = make(map[string]string)
+		for name, lines := range testCase.DifferentialStdout {
+			path := filepath.Join(crashDirPath, name+PROFILE_STDOUT_EXT)
+			if err := writeLines(path, lines); err != nil {
+				return err
+			}
+			bugDesc.DifferentialStdoutPaths[name] = path
+		}
+	}
+
+	if len(testCase.DifferentialStderr) != 0 {
+		bugDesc.DifferentialStderrPaths = make(map[string]string)
+		for name, lines := range testCase.DifferentialStderr {
+			path := filepath.Join(crashDirPath, name+PROFILE_STDERR_EXT)
+			if err := writeLines(path, lines); err != nil {
+				return err
+			}
+			bugDesc.DifferentialStderrPaths[name] = path
+		}
+	}
+
+	if len(testCase.DivergentProfiles) != 0 ||
+		len(testCase.TimedOutProfiles) != 0 {
+		n, err := normalize.New(s.Config.Normalize.Preset,
+			s.Config.Normalize.Replace, s.Config.Normalize.DropLine,
+			s.Config.Normalize.FloatPrecision)
+		if err != nil {
+			return err
+		}
+
+		diffPath := filepath.Join(crashDirPath, DIFF_NAME)
+		if err := writeDiffs(diffPath, *testCase, n); err != nil {
+			return err
+		}
+		bugDesc.DiffPath = diffPath
+	}
+
+	// Store the bug descriptor
+	if err := WriteBugDescriptor(crashDirPath, bugDesc); err != nil {
+		return err
+	}
+
+	testCase.PreservationDir = crashDirPath
+	return nil
+}
+
+// addToCorpus copies the fuzz file into the corpus directory, giving it a
+// unique name, and returns the path of the copy
+func addToCorpus(corpusDir string, fuzzFilePath string) (string, error) {
+	corpusName := fmt.Sprintf("%d_%s", time.Now().UnixNano(),
+		filepath.Base(fuzzFilePath))
+	corpusPath := filepath.Join(corpusDir, corpusName)
+
+	if err := fs.CopyFileContents(fuzzFilePath, corpusPath); err != nil {
+		return "", err
+	}
+
+	return corpusPath, nil
 }
@@ -0,0 +1,108 @@
+package resultproc
+
+import (
+	"github.com/SeanHeelan/Malamute/data"
+	"github.com/SeanHeelan/Malamute/monitor"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name        string
+		testCase    data.TestCase
+		wantClass   string
+		wantProfile string
+	}{
+		{
+			name:     "clean exit",
+			testCase: data.TestCase{ExitCode: 0},
+		},
+		{
+			name:     "non-zero exit",
+			testCase: data.TestCase{ExitCode: 255},
+		},
+		{
+			name:      "segfault",
+			testCase:  data.TestCase{ExitCode: SIGSEGV},
+			wantClass: BUGCLASS_CRASH,
+		},
+		{
+			name:      "sanitizer",
+			testCase:  data.TestCase{ExitCode: monitor.ASAN_EXITCODE},
+			wantClass: BUGCLASS_CRASH,
+		},
+		{
+			name:     "unconfirmed timeout",
+			testCase: data.TestCase{TestTimedOut: true, ExitCode: SIGKILL},
+		},
+		{
+			name: "confirmed hang",
+			testCase: data.TestCase{TestTimedOut: true,
+				HangConfirmed: true},
+			wantClass: BUGCLASS_HANG,
+		},
+		{
+			name: "divergence",
+			testCase: data.TestCase{
+				DivergentProfiles: []string{"jit"},
+				DifferentialExitCodes: map[string]int{
+					monitor.DIFF_MAIN_PROFILE: 0, "jit": 0},
+			},
+			wantClass: BUGCLASS_DIVERGENCE,
+		},
+		{
+			name: "crash under a profile",
+			testCase: data.TestCase{
+				DivergentProfiles: []string{"opcache", "jit"},
+				DifferentialExitCodes: map[string]int{
+					monitor.DIFF_MAIN_PROFILE: 0, "opcache": 1,
+					"jit": SIGABRT},
+			},
+			wantClass:   BUGCLASS_CRASH,
+			wantProfile: "jit",
+		},
+		{
+			name: "crash under main and a profile",
+			testCase: data.TestCase{
+				ExitCode:          SIGSEGV,
+				DivergentProfiles: []string{"jit"},
+				DifferentialExitCodes: map[string]int{
+					monitor.DIFF_MAIN_PROFILE: SIGSEGV, "jit": SIGABRT},
+			},
+			wantClass: BUGCLASS_CRASH,
+		},
+		{
+			name: "mismatch of a mutated test",
+			testCase: data.TestCase{ExpectationChecked: true,
+				ExpectationMismatch: "line 1 differs"},
+		},
+		{
+			name: "mismatch of an unmutated test",
+			testCase: data.TestCase{ExpectationChecked: true,
+				ExpectationMismatch: "line 1 differs", Unmutated: true},
+			wantClass: BUGCLASS_REGRESSION,
+		},
+		{
+			name:      "cpu anomaly",
+			testCase:  data.TestCase{ResourceAnomaly: monitor.RESOURCE_CPU},
+			wantClass: BUGCLASS_PERFORMANCE,
+		},
+		{
+			name: "memory anomaly",
+			testCase: data.TestCase{
+				ResourceAnomaly: monitor.RESOURCE_MEMORY},
+			wantClass: BUGCLASS_MEMORY,
+		},
+	}
+
+	for _, test := range tests {
+		if got := Classify(test.testCase); got != test.wantClass {
+			t.Errorf("%s: Classify = %q, want %q", test.name, got,
+				test.wantClass)
+		}
+		if got := crashedProfile(test.testCase); got != test.wantProfile {
+			t.Errorf("%s: crashedProfile = %q, want %q", test.name, got,
+				test.wantProfile)
+		}
+	}
+}
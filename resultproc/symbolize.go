@@ -0,0 +1,184 @@
+package resultproc
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/SeanHeelan/Malamute/config"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	STDERR_SYMBOLIZED_NAME = "stderr.symbolized"
+	// The function or location reported for an address that could not be
+	// resolved
+	SYMBOLIZE_UNKNOWN = "??"
+)
+
+// Matches a raw frame from a sanitizer report, such as
+// "#0 0x4f5e2a  (/opt/js+0x4f5e2a)". The module and offset are captured.
+var rawFrame = regexp.MustCompile(`^(\s*#[0-9]+ 0x[0-9a-fA-F]+)\s+(?:in\s+)?\((\S+)\+(0x[0-9a-fA-F]+)\)`)
+
+// symbolizer resolves raw frames of one module using llvm-symbolizer or
+// addr2line
+type symbolizer struct {
+	path      string
+	addr2line bool
+	binary    string
+	module    string
+}
+
+// resolvedFrame describes the function and source location of an address
+type resolvedFrame struct {
+	function string
+	location string
+}
+
+// newSymbolizer creates a symbolizer as described by the Symbolize section
+// of cfg. nil is returned if symbolization is disabled.
+func newSymbolizer(cfg *config.Config) (*symbolizer, error) {
+	if !cfg.Symbolize.Enabled {
+		return nil, nil
+	}
+
+	sym := symbolizer{
+		binary: cfg.Symbolize.Binary,
+		module: cfg.Symbolize.Module,
+	}
+
+	candidates := []string{"llvm-symbolizer", "addr2line"}
+	if len(cfg.Symbolize.SymbolizerPath) != 0 {
+		candidates = []string{cfg.Symbolize.SymbolizerPath}
+	}
+
+	for _, candidate := range candidates {
+		if path, err := exec.LookPath(candidate); err == nil {
+			sym.path = path
+			break
+		}
+	}
+
+	if len(sym.path) == 0 {
+		msg := fmt.Sprintf("None of %s are available to symbolize with",
+			strings.Join(candidates, ", "))
+		return nil, errors.New(msg)
+	}
+
+	sym.addr2line = strings.Contains(filepath.Base(sym.path), "addr2line")
+	return &sym, nil
+}
+
+// resolve returns the function and location of each of offsets, which are
+// relative to the start of the binary
+func (sym *symbolizer) resolve(offsets []string) ([]resolvedFrame, error) {
+	var cmd *exec.Cmd
+	if sym.addr2line {
+		args := append([]string{"-f", "-C", "-e", sym.binary}, offsets...)
+		cmd = exec.Command(sym.path, args...)
+	} else {
+		args := append([]string{"--obj=" + sym.binary}, offsets...)
+		cmd = exec.Command(sym.path, args...)
+	}
+
+	output, err := cmd.Output()
+	if err != nil {
+		msg := fmt.Sprintf("Error running %s on %s: %s", sym.path,
+			sym.binary, err)
+		return nil, errors.New(msg)
+	}
+
+	// addr2line prints a function and location line for each address.
+	// llvm-symbolizer does the same, but with a blank line after each
+	// address, and extra pairs of lines for any functions inlined at it.
+	// The innermost function, which is printed first, is the one used.
+	frames := []resolvedFrame{}
+	lines := []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) != 0 {
+			lines = append(lines, line)
+		}
+
+		if (sym.addr2line && len(lines) == 2) ||
+			(!sym.addr2line && len(line) == 0 && len(lines) != 0) {
+			frame := resolvedFrame{function: lines[0]}
+			if len(lines) > 1 {
+				frame.location = lines[1]
+			}
+			frames = append(frames, frame)
+			lines = []string{}
+		}
+	}
+
+	if !sym.addr2line && len(lines) != 0 {
+		frames = append(frames, resolvedFrame{function: lines[0]})
+		if len(lines) > 1 {
+			frames[len(frames)-1].location = lines[1]
+		}
+	}
+
+	if len(frames) != len(offsets) {
+		msg := fmt.Sprintf("%s resolved %d of %d addresses", sym.path,
+			len(frames), len(offsets))
+		return nil, errors.New(msg)
+	}
+
+	return frames, nil
+}
+
+// symbolize returns a copy of lines in which the raw frames of the module
+// have been rewritten as symbolized frames. nil is returned if there were
+// no frames that could be resolved.
+func (sym *symbolizer) symbolize(lines []string) ([]string, error) {
+	indexes := []int{}
+	offsets := []string{}
+	for i, line := range lines {
+		m := rawFrame.FindStringSubmatch(line)
+		if m == nil || filepath.Base(m[2]) != sym.module {
+			continue
+		}
+
+		indexes = append(indexes, i)
+		offsets = append(offsets, m[3])
+	}
+
+	if len(offsets) == 0 {
+		return nil, nil
+	}
+
+	frames, err := sym.resolve(offsets)
+	if err != nil {
+		return nil, err
+	}
+
+	symbolized := make([]string, len(lines))
+	copy(symbolized, lines)
+	resolved := 0
+	for i, frame := range frames {
+		if frame.function == SYMBOLIZE_UNKNOWN {
+			continue
+		}
+
+		m := rawFrame.FindStringSubmatch(lines[indexes[i]])
+		location := frame.location
+		if len(location) == 0 ||
+			strings.HasPrefix(location, SYMBOLIZE_UNKNOWN+":") {
+			location = fmt.Sprintf("(%s+%s)", m[2], m[3])
+		}
+
+		symbolized[indexes[i]] = fmt.Sprintf("%s in %s %s", m[1],
+			frame.function, location)
+		resolved++
+	}
+
+	if resolved == 0 {
+		return nil, nil
+	}
+
+	return symbolized, nil
+}
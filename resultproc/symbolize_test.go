@@ -0,0 +1,156 @@
+package resultproc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Fake symbolizers that resolve offset 0x10 to f and 0x20 to g, inlined
+// into h, and cannot resolve anything else
+const (
+	FAKE_LLVM_SYMBOLIZER = `#!/bin/sh
+shift
+for offset in "$@"; do
+	case $offset in
+	0x10) printf 'f\n/src/f.c:1:2\n\n' ;;
+	0x20) printf 'g\n/src/g.c:3:4\nh\n/src/h.c:5:6\n\n' ;;
+	*) printf '??\n??:0:0\n\n' ;;
+	esac
+done
+`
+	FAKE_ADDR2LINE = `#!/bin/sh
+shift 4
+for offset in "$@"; do
+	case $offset in
+	0x10) printf 'f\n/src/f.c:1\n' ;;
+	0x20) printf 'g\n/src/g.c:3\n' ;;
+	*) printf '??\n??:0\n' ;;
+	esac
+done
+`
+)
+
+func TestSymbolize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "symbolize")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	llvmPath := filepath.Join(dir, "llvm-symbolizer")
+	addr2linePath := filepath.Join(dir, "addr2line")
+	for path, script := range map[string]string{
+		llvmPath:      FAKE_LLVM_SYMBOLIZER,
+		addr2linePath: FAKE_ADDR2LINE,
+	} {
+		if err := ioutil.WriteFile(path, []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stderr := []string{
+		"==1==ERROR: AddressSanitizer: SEGV on unknown address",
+		"    #0 0x4f5e2a  (/opt/php/bin/php+0x10)",
+		"    #1 0x4f5e3b in (/opt/php/bin/php+0x20)",
+		"    #2 0x4f5e4c  (/opt/php/bin/php+0x30)",
+		"    #3 0x7f0001  (/lib/libc.so.6+0x10)",
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		lines  []string
+		want   []string
+		frames []string
+	}{
+		{
+			name:  "llvm-symbolizer",
+			path:  llvmPath,
+			lines: stderr,
+			want: []string{
+				stderr[0],
+				"    #0 0x4f5e2a in f /src/f.c:1:2",
+				"    #1 0x4f5e3b in g /src/g.c:3:4",
+				stderr[3],
+				stderr[4],
+			},
+			frames: []string{"f", "g"},
+		},
+		{
+			name:  "addr2line",
+			path:  addr2linePath,
+			lines: stderr,
+			want: []string{
+				stderr[0],
+				"    #0 0x4f5e2a in f /src/f.c:1",
+				"    #1 0x4f5e3b in g /src/g.c:3",
+				stderr[3],
+				stderr[4],
+			},
+			frames: []string{"f", "g"},
+		},
+		{
+			name:  "nothing resolved",
+			path:  llvmPath,
+			lines: []string{"#0 0x1 (/opt/php/bin/php+0x40)"},
+		},
+		{
+			name:  "no frames of the module",
+			path:  llvmPath,
+			lines: []string{stderr[0], stderr[4]},
+		},
+	}
+
+	for _, test := range tests {
+		sym := symbolizer{
+			path:      test.path,
+			addr2line: test.path == addr2linePath,
+			binary:    "/opt/php/bin/php.debug",
+			module:    "php",
+		}
+
+		got, err := sym.symbolize(test.lines)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: symbolize = %q, want %q", test.name, got,
+				test.want)
+		}
+		if frames := stackFrames(got); !reflect.DeepEqual(frames,
+			test.frames) {
+			t.Errorf("%s: frames of the symbolized output = %q, want %q",
+				test.name, frames, test.frames)
+		}
+	}
+}
+
+func TestRawFrame(t *testing.T) {
+	tests := []struct {
+		line       string
+		wantModule string
+		wantOffset string
+	}{
+		{"#0 0x4f5e2a  (/opt/js+0x4f5e2a)", "/opt/js", "0x4f5e2a"},
+		{"    #12 0x1 in (libphp.so+0xff)", "libphp.so", "0xff"},
+		{"#0 0x1 in main /src/main.c:3", "", ""},
+		{"Segmentation fault", "", ""},
+	}
+
+	for _, test := range tests {
+		m := rawFrame.FindStringSubmatch(test.line)
+		module, offset := "", ""
+		if m != nil {
+			module, offset = m[2], m[3]
+		}
+
+		if module != test.wantModule || offset != test.wantOffset {
+			t.Errorf("%q: module %q, offset %q, want %q, %q", test.line,
+				module, offset, test.wantModule, test.wantOffset)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package session
+
+import (
+	"fmt"
+	"io"
+)
+
+const (
+	// The number of buckets in a Histogram. Bucket i counts values below
+	// 2^i, and the last bucket counts everything larger.
+	HISTOGRAM_BUCKETS = 24
+)
+
+// Histogram records a distribution of values in power of two buckets
+type Histogram struct {
+	Counts []int
+}
+
+// Add records a single value
+func (h *Histogram) Add(value int64) {
+	if h.Counts == nil {
+		h.Counts = make([]int, HISTOGRAM_BUCKETS)
+	}
+
+	idx := 0
+	for idx < HISTOGRAM_BUCKETS-1 && int64(1)<<uint(idx) <= value {
+		idx++
+	}
+
+	h.Counts[idx]++
+}
+
+// write prints a line for each non-empty bucket, with values given in unit
+func (h *Histogram) write(w io.Writer, unit string) {
+	for idx, count := range h.Counts {
+		if count == 0 {
+			continue
+		}
+
+		if idx == HISTOGRAM_BUCKETS-1 {
+			fmt.Fprintf(w, ">= %d%s : %d\n", int64(1)<<uint(idx-1), unit,
+				count)
+		} else {
+			fmt.Fprintf(w, "< %d%s : %d\n", int64(1)<<uint(idx), unit, count)
+		}
+	}
+}
@@ -6,10 +6,13 @@ import (
 	"fmt"
 	"github.com/SeanHeelan/Malamute/config"
 	"github.com/SeanHeelan/Malamute/fs"
+	"github.com/SeanHeelan/Malamute/triage"
 	"io/ioutil"
 	"os"
 	"path"
+	"sort"
 	"strconv"
+	"time"
 )
 
 const (
@@ -19,14 +22,107 @@ const (
 	SUMMARY_FILE     = "summary.txt"
 	TEST_CASES_DIR   = "test_cases"
 	PRESERVATION_DIR = "crashes"
+	HANGS_DIR        = "hangs"
+	CORPUS_DIR       = "corpus"
+	INTERESTING_DIR  = "interesting"
+	PROFILE_DIR      = "profiles"
+	PROFILE_DATA     = "merged.profdata"
+	PROFILE_FILES    = "coverage_files.txt"
+	PROFILE_FUNCS    = "coverage_functions.txt"
+	PREFLIGHT_FILE   = "preflight.txt"
+	RECHECK_FILE     = "recheck.txt"
+	EDGES_FILE       = "edges.txt"
 	DIR_PERMS        = 0755
+
+	SEED_OUTCOME_OK       = "ok"
+	SEED_OUTCOME_FAILED   = "failed"
+	SEED_OUTCOME_CRASHED  = "crashed"
+	SEED_OUTCOME_TIMEDOUT = "timed out"
+
+	// A crash of the bucket still crashes in the same bucket
+	BUCKET_STATUS_CRASHING = "crashing"
+	// No crash of the bucket crashes any more
+	BUCKET_STATUS_FIXED = "fixed"
+	// The crashes of the bucket still crash, but in other buckets
+	BUCKET_STATUS_CHANGED = "changed signature"
 )
 
+// SeedResult records the outcome of running a seed, unmutated, during the
+// pre-flight run
+type SeedResult struct {
+	// Outcome is one of the SEED_OUTCOME_* constants
+	Outcome  string
+	ExitCode int
+	Duration time.Duration
+	// Quarantined indicates that the seed will not be fuzzed, for the
+	// reason given by Reason
+	Quarantined bool
+	Reason      string
+	// CPUTime and MaxRSS give the resources used by the seed, as described
+	// on data.TestCase
+	CPUTime time.Duration
+	MaxRSS  int64
+	// Timeout, if not 0, is the timeout calibrated for tests generated from
+	// the seed
+	Timeout time.Duration
+}
+
+// BucketCheck records the result of re-running the preserved crashes of a
+// bucket against a build of the interpreter
+type BucketCheck struct {
+	Time        time.Time
+	Interpreter string
+	// Status is one of the BUCKET_STATUS_* constants
+	Status string
+	// Crashing, Fixed and Changed count the crashes of the bucket that
+	// still crashed in it, no longer crashed, or crashed in another bucket
+	Crashing int
+	Fixed    int
+	Changed  int
+	// NewBuckets lists the buckets that changed crashes were placed in
+	NewBuckets []string
+}
+
+// BucketStatus tracks whether the crashes of a bucket reproduce against
+// successive builds of the interpreter
+type BucketStatus struct {
+	// Status is the status found by the most recent check
+	Status string
+	// History holds every check of the bucket, oldest first
+	History []BucketCheck
+}
+
 type Stats struct {
 	CrashCount                int
 	TestCasesProcessed        int
 	TimedOutTests             int
+	UnrunnableTests           int
+	HangCount                 int
+	LeakedProcesses           int
+	CoverageEdges             int
+	CorpusSize                int
+	ExpectationsChecked       int
+	ExpectationMismatches     int
+	ExpectationsSkipped       int
+	Regressions               int
+	ProfileLines              int
+	ProfileLinesCovered       int
+	ProfileFunctions          int
+	ProfileFunctionsCovered   int
 	ExitCodeCounts            map[string]int
+	CrashBuckets              map[string]int
+	CrashBucketSeverities     map[string]triage.Verdict
+	CrashSeverityCounts       map[string]int
+	PerformanceAnomalies      int
+	MemoryAnomalies           int
+	ClassifierRejections      int
+	InterestingCounts         map[string]int
+	LineageCounts             map[string]int
+	KnownCrashes              int
+	IgnoredCounts             map[string]int
+	WallTimeMs                Histogram
+	CPUTimeMs                 Histogram
+	MaxRSSMb                  Histogram
 	TestCasesProcessedPerSeed map[string]int
 }
 
@@ -49,6 +145,96 @@ func (s *Stats) AddExitCode(exitCode int) {
 	}
 }
 
+// AddResourceUsage records the resources used by a single test
+func (s *Stats) AddResourceUsage(wallTime time.Duration,
+	cpuTime time.Duration, maxRSS int64) {
+
+	s.WallTimeMs.Add(int64(wallTime / time.Millisecond))
+	s.CPUTimeMs.Add(int64(cpuTime / time.Millisecond))
+	s.MaxRSSMb.Add(maxRSS / 1024)
+}
+
+// AddCrashBucket increments the counter for a bucket of similar crashes,
+// and records the triage verdict of the crash against the bucket if it is
+// the most severe seen in it
+func (s *Stats) AddCrashBucket(bucket string, verdict triage.Verdict) {
+	// Sessions created before crashes were bucketed or triaged will not
+	// have the maps
+	if s.CrashBuckets == nil {
+		s.CrashBuckets = make(map[string]int)
+	}
+	if s.CrashBucketSeverities == nil {
+		s.CrashBucketSeverities = make(map[string]triage.Verdict)
+	}
+	if s.CrashSeverityCounts == nil {
+		s.CrashSeverityCounts = make(map[string]int)
+	}
+
+	s.CrashBuckets[bucket]++
+	s.CrashSeverityCounts[verdict.Severity]++
+	if worst, ok := s.CrashBucketSeverities[bucket]; !ok ||
+		triage.Rank(verdict.Severity) < triage.Rank(worst.Severity) {
+		s.CrashBucketSeverities[bucket] = verdict
+	}
+}
+
+// AddInteresting increments the counter of test cases kept as interesting
+// for reason
+func (s *Stats) AddInteresting(reason string) {
+	// Sessions created before interesting test cases were kept will not
+	// have the map
+	if s.InterestingCounts == nil {
+		s.InterestingCounts = make(map[string]int)
+	}
+	s.InterestingCounts[reason]++
+}
+
+// AddLineage increments the counter of bugs found with relation to the
+// explored crash they descend from
+func (s *Stats) AddLineage(relation string) {
+	// Sessions created before crashes could be explored will not have the
+	// map
+	if s.LineageCounts == nil {
+		s.LineageCounts = make(map[string]int)
+	}
+	s.LineageCounts[relation]++
+}
+
+// AddIgnored increments the counter of bugs that matched the entry of the
+// ignore list labelled label
+func (s *Stats) AddIgnored(label string) {
+	// Sessions created before bugs could be ignored will not have the map
+	if s.IgnoredCounts == nil {
+		s.IgnoredCounts = make(map[string]int)
+	}
+	s.IgnoredCounts[label]++
+}
+
+// SortedCrashBuckets returns the crash buckets ordered by the severity of
+// the worst crash in each, and then by the number of crashes in them
+func (s *Stats) SortedCrashBuckets() []string {
+	buckets := []string{}
+	for bucket := range s.CrashBuckets {
+		buckets = append(buckets, bucket)
+	}
+
+	sort.Slice(buckets, func(i, j int) bool {
+		a := triage.Rank(s.CrashBucketSeverities[buckets[i]].Severity)
+		b := triage.Rank(s.CrashBucketSeverities[buckets[j]].Severity)
+		if a != b {
+			return a < b
+		}
+
+		if s.CrashBuckets[buckets[i]] != s.CrashBuckets[buckets[j]] {
+			return s.CrashBuckets[buckets[i]] > s.CrashBuckets[buckets[j]]
+		}
+
+		return buckets[i] < buckets[j]
+	})
+
+	return buckets
+}
+
 // Session contains enough information to restart a run of the fuzzer without
 // reprocessing a set of already covered tests. This only makes sense when
 // the run mode is to cover all tests once. Otherwise, the user can just
@@ -60,8 +246,36 @@ type Session struct {
 	SessionDir      string
 	TestCasesDir    string
 	PreservationDir string
+	HangsDir        string
+	CorpusDir       string
+	InterestingDir  string
+	ProfileDir      string
 	Config          *config.Config
 	Stats           Stats
+	// Seeds holds the result of the pre-flight run of each seed
+	Seeds map[string]*SeedResult
+	// MonitorCount gives the number of monitors used by the most recent
+	// run, and MonitorCPUs the CPU each was pinned to. MonitorCPUs is
+	// empty if they were not pinned.
+	MonitorCount int
+	MonitorCPUs  []int
+	// Buckets holds the status of each crash bucket, as found by the
+	// recheck command
+	Buckets map[string]*BucketStatus
+}
+
+// initDir ensures that the session sub-directory name exists, and records
+// its path in dirPath if it is not already set
+func (s *Session) initDir(dirPath *string, name string) error {
+	if len(*dirPath) == 0 {
+		*dirPath = path.Join(s.SessionDir, name)
+	}
+
+	if err := os.MkdirAll(*dirPath, DIR_PERMS); err != nil {
+		return err
+	}
+
+	return nil
 }
 
 // Save stores the session back to the same location it was loaded from
@@ -94,6 +308,15 @@ func (s *Session) Save() error {
 	return nil
 }
 
+// Percent formats part as a percentage of total
+func Percent(part int, total int) string {
+	if total == 0 {
+		return "-"
+	}
+
+	return fmt.Sprintf("%.2f%%", float64(part)*100/float64(total))
+}
+
 func (s *Session) LogSummary() error {
 	logPath := path.Join(s.SessionDir, SUMMARY_FILE)
 
@@ -106,15 +329,153 @@ func (s *Session) LogSummary() error {
 	w := bufio.NewWriter(fd)
 	defer w.Flush()
 
+	fmt.Fprintf(w, "Monitors: %d\n", s.MonitorCount)
+	if len(s.MonitorCPUs) != 0 {
+		fmt.Fprintf(w, "Monitor CPUs: %v\n", s.MonitorCPUs)
+	}
 	fmt.Fprintf(w, "Total tests run: %d\n", s.Stats.TestCasesProcessed)
 	fmt.Fprintf(w, "Crashes detected: %d\n", s.Stats.CrashCount)
-	fmt.Fprintf(w, "Timed out tests: %d\n\n", s.Stats.TimedOutTests)
+	fmt.Fprintf(w, "Distinct crash buckets: %d\n", len(s.Stats.CrashBuckets))
+	fmt.Fprintf(w, "Timed out tests: %d\n", s.Stats.TimedOutTests)
+	if s.Stats.UnrunnableTests != 0 {
+		fmt.Fprintf(w, "Tests that could not be run: %d\n",
+			s.Stats.UnrunnableTests)
+	}
+	if s.Config.Hangs.Preserve {
+		fmt.Fprintf(w, "Confirmed hangs: %d\n", s.Stats.HangCount)
+	}
+	fmt.Fprintf(w, "Leaked processes killed: %d\n", s.Stats.LeakedProcesses)
+	if len(s.Seeds) != 0 {
+		fmt.Fprintf(w, "Seeds quarantined by the pre-flight run: %d (see "+
+			"%s)\n", s.QuarantinedCount(), PREFLIGHT_FILE)
+	}
+	if s.Config.Coverage.Enabled {
+		fmt.Fprintf(w, "Edges covered: %d\n", s.Stats.CoverageEdges)
+		fmt.Fprintf(w, "Corpus size: %d\n", s.Stats.CorpusSize)
+	}
+	if s.Config.Oracle.Enabled {
+		fmt.Fprintf(w, "Tests checked against expected results: %d\n",
+			s.Stats.ExpectationsChecked)
+		fmt.Fprintf(w, "Tests not matching expected results: %d\n",
+			s.Stats.ExpectationMismatches)
+		fmt.Fprintf(w, "Regressions in unmutated seeds: %d\n",
+			s.Stats.Regressions)
+		fmt.Fprintf(w, "Tests not checked as their output was "+
+			"truncated: %d\n", s.Stats.ExpectationsSkipped)
+	}
+	if s.Config.Interesting.Enabled {
+		total := 0
+		for _, cnt := range s.Stats.InterestingCounts {
+			total += cnt
+		}
+		fmt.Fprintf(w, "Interesting test cases kept: %d\n", total)
+		reasons := []string{}
+		for reason := range s.Stats.InterestingCounts {
+			reasons = append(reasons, reason)
+		}
+		sort.Strings(reasons)
+		for _, reason := range reasons {
+			fmt.Fprintf(w, "  %s : %d\n", reason,
+				s.Stats.InterestingCounts[reason])
+		}
+	}
+	if s.Config.TestProcessing.Mode == config.RUNMODE_EXPLORE_CRASHES {
+		fmt.Fprintf(w, "Crashes explored: %d\n",
+			len(s.Config.ExploreCrashes.CrashDir))
+		relations := []string{}
+		for relation := range s.Stats.LineageCounts {
+			relations = append(relations, relation)
+		}
+		sort.Strings(relations)
+		for _, relation := range relations {
+			fmt.Fprintf(w, "  %s bugs : %d\n", relation,
+				s.Stats.LineageCounts[relation])
+		}
+	}
+	if len(s.Config.CrashDB.Dir) != 0 {
+		fmt.Fprintf(w, "Bugs already in the crash database: %d\n",
+			s.Stats.KnownCrashes)
+	}
+	if len(s.Config.Ignore.File) != 0 {
+		total := 0
+		for _, cnt := range s.Stats.IgnoredCounts {
+			total += cnt
+		}
+		fmt.Fprintf(w, "Known bugs ignored: %d\n", total)
+		labels := []string{}
+		for label := range s.Stats.IgnoredCounts {
+			labels = append(labels, label)
+		}
+		sort.Strings(labels)
+		for _, label := range labels {
+			fmt.Fprintf(w, "  %s : %d\n", label,
+				s.Stats.IgnoredCounts[label])
+		}
+	}
+	if len(s.Config.Classifier.Command) != 0 {
+		fmt.Fprintf(w, "Candidate bugs rejected by the classifier: %d\n",
+			s.Stats.ClassifierRejections)
+	}
+	if s.Config.Profile.Enabled {
+		fmt.Fprintf(w, "Source lines covered: %d/%d (%s)\n",
+			s.Stats.ProfileLinesCovered, s.Stats.ProfileLines,
+			Percent(s.Stats.ProfileLinesCovered, s.Stats.ProfileLines))
+		fmt.Fprintf(w, "Functions covered: %d/%d (%s)\n",
+			s.Stats.ProfileFunctionsCovered, s.Stats.ProfileFunctions,
+			Percent(s.Stats.ProfileFunctionsCovered,
+				s.Stats.ProfileFunctions))
+		fmt.Fprintf(w, "See %s and %s for details\n", PROFILE_FILES,
+			PROFILE_FUNCS)
+	}
+	fmt.Fprintln(w)
 
 	fmt.Fprintf(w, "Exit code counts: \n")
 	for exitCode, cnt := range s.Stats.ExitCodeCounts {
 		fmt.Fprintf(w, "%s : %d\n", exitCode, cnt)
 	}
 
+	if len(s.Stats.CrashSeverityCounts) != 0 {
+		fmt.Fprintf(w, "\nCrashes by severity: \n")
+		severities := []string{triage.SEVERITY_CRITICAL,
+			triage.SEVERITY_HIGH, triage.SEVERITY_MEDIUM, triage.SEVERITY_LOW,
+			triage.SEVERITY_UNKNOWN}
+		for _, severity := range severities {
+			if cnt := s.Stats.CrashSeverityCounts[severity]; cnt != 0 {
+				fmt.Fprintf(w, "%s : %d\n", severity, cnt)
+			}
+		}
+	}
+
+	if len(s.Stats.CrashBuckets) != 0 {
+		fmt.Fprintf(w, "\nCrash bucket counts, most severe first: \n")
+		for _, bucket := range s.Stats.SortedCrashBuckets() {
+			verdict, ok := s.Stats.CrashBucketSeverities[bucket]
+			if !ok {
+				fmt.Fprintf(w, "%s : %d\n", bucket,
+					s.Stats.CrashBuckets[bucket])
+				continue
+			}
+
+			fmt.Fprintf(w, "%s : %d [%s: %s]\n", bucket,
+				s.Stats.CrashBuckets[bucket], verdict.Severity,
+				verdict.Reason)
+		}
+	}
+
+	if s.Config.Resources.CPUMultiplier != 0 ||
+		s.Config.Resources.MemoryMultiplier != 0 {
+		fmt.Fprintf(w, "\nPerformance anomalies: %d\n",
+			s.Stats.PerformanceAnomalies)
+		fmt.Fprintf(w, "Memory anomalies: %d\n", s.Stats.MemoryAnomalies)
+	}
+
+	fmt.Fprint(w, "\nWall time distribution:\n")
+	s.Stats.WallTimeMs.write(w, "ms")
+	fmt.Fprint(w, "\nCPU time distribution:\n")
+	s.Stats.CPUTimeMs.write(w, "ms")
+	fmt.Fprint(w, "\nPeak memory distribution:\n")
+	s.Stats.MaxRSSMb.write(w, "MB")
+
 	fmt.Fprint(w, "\nTests per seed:\n")
 	for seed, cnt := range s.Stats.TestCasesProcessedPerSeed {
 		fmt.Fprintf(w, "%s %d\n", seed, cnt)
@@ -123,6 +484,163 @@ func (s *Session) LogSummary() error {
 	return nil
 }
 
+// IsQuarantined indicates if the pre-flight run excluded seed from fuzzing
+func (s *Session) IsQuarantined(seed string) bool {
+	result, ok := s.Seeds[seed]
+	return ok && result.Quarantined
+}
+
+// SeedTimeout returns the timeout for a test generated from seeds. This is
+// the greatest of their calibrated timeouts, or defaultTimeout if none of
+// them have one.
+func (s *Session) SeedTimeout(seeds []string,
+	defaultTimeout time.Duration) time.Duration {
+
+	var timeout time.Duration
+	for _, seed := range seeds {
+		if result, ok := s.Seeds[seed]; ok && result.Timeout > timeout {
+			timeout = result.Timeout
+		}
+	}
+
+	if timeout == 0 {
+		return defaultTimeout
+	}
+
+	return timeout
+}
+
+// QuarantinedCount returns the number of seeds excluded from fuzzing by the
+// pre-flight run
+func (s *Session) QuarantinedCount() int {
+	count := 0
+	for _, result := range s.Seeds {
+		if result.Quarantined {
+			count++
+		}
+	}
+
+	return count
+}
+
+// LogPreflight writes a report of the pre-flight run to the session
+// directory, listing the seeds that were quarantined and why
+func (s *Session) LogPreflight() error {
+	logPath := path.Join(s.SessionDir, PREFLIGHT_FILE)
+
+	fd, err := os.Create(logPath)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	w := bufio.NewWriter(fd)
+	defer w.Flush()
+
+	seeds := []string{}
+	outcomes := make(map[string]int)
+	for seed, result := range s.Seeds {
+		seeds = append(seeds, seed)
+		outcomes[result.Outcome]++
+	}
+	sort.Strings(seeds)
+
+	fmt.Fprintf(w, "Seeds run: %d\n", len(seeds))
+	for _, outcome := range []string{SEED_OUTCOME_OK, SEED_OUTCOME_FAILED,
+		SEED_OUTCOME_CRASHED, SEED_OUTCOME_TIMEDOUT} {
+		fmt.Fprintf(w, "Seeds %s: %d\n", outcome, outcomes[outcome])
+	}
+	fmt.Fprintf(w, "Seeds quarantined: %d\n", s.QuarantinedCount())
+
+	fmt.Fprint(w, "\nQuarantined seeds:\n")
+	for _, seed := range seeds {
+		result := s.Seeds[seed]
+		if result.Quarantined {
+			fmt.Fprintf(w, "%s : %s (exit code %d, ran for %s)\n", seed,
+				result.Reason, result.ExitCode, result.Duration)
+		}
+	}
+
+	if s.Config.Timeout.Calibrate {
+		fmt.Fprint(w, "\nCalibrated timeouts:\n")
+		for _, seed := range seeds {
+			result := s.Seeds[seed]
+			if result.Timeout != 0 {
+				fmt.Fprintf(w, "%s : %s (ran for %s)\n", seed,
+					result.Timeout, result.Duration)
+			}
+		}
+	}
+
+	return nil
+}
+
+// AddBucketCheck records check as the latest status of bucket, and returns
+// the status the bucket had before it, which is empty if it had not been
+// checked
+func (s *Session) AddBucketCheck(bucket string, check BucketCheck) string {
+	// Sessions that have never been rechecked will not have the map
+	if s.Buckets == nil {
+		s.Buckets = make(map[string]*BucketStatus)
+	}
+
+	status, ok := s.Buckets[bucket]
+	if !ok {
+		status = &BucketStatus{}
+		s.Buckets[bucket] = status
+	}
+
+	previous := status.Status
+	status.Status = check.Status
+	status.History = append(status.History, check)
+	return previous
+}
+
+// LogRecheck writes a report of the status of every checked crash bucket to
+// the session directory, along with its history
+func (s *Session) LogRecheck() error {
+	logPath := path.Join(s.SessionDir, RECHECK_FILE)
+
+	fd, err := os.Create(logPath)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	w := bufio.NewWriter(fd)
+	defer w.Flush()
+
+	buckets := []string{}
+	statuses := make(map[string]int)
+	for bucket, status := range s.Buckets {
+		buckets = append(buckets, bucket)
+		statuses[status.Status]++
+	}
+	sort.Strings(buckets)
+
+	fmt.Fprintf(w, "Buckets checked: %d\n", len(buckets))
+	for _, status := range []string{BUCKET_STATUS_CRASHING,
+		BUCKET_STATUS_CHANGED, BUCKET_STATUS_FIXED} {
+		fmt.Fprintf(w, "Buckets %s: %d\n", status, statuses[status])
+	}
+
+	for _, bucket := range buckets {
+		status := s.Buckets[bucket]
+		fmt.Fprintf(w, "\n%s : %s\n", bucket, status.Status)
+		for _, check := range status.History {
+			fmt.Fprintf(w, "  %s %s : %s (%d crashing, %d changed, %d "+
+				"fixed)\n", check.Time.Format(time.RFC3339),
+				check.Interpreter, check.Status, check.Crashing,
+				check.Changed, check.Fixed)
+			for _, newBucket := range check.NewBuckets {
+				fmt.Fprintf(w, "    now %s\n", newBucket)
+			}
+		}
+	}
+
+	return nil
+}
+
 func Create(sessDir string, configPath string) (*Session, error) {
 	var err error
 	var cfg *config.Config
@@ -144,12 +662,36 @@ func Create(sessDir string, configPath string) (*Session, error) {
 		return nil, err
 	}
 
+	hangs_path := path.Join(sessDir, HANGS_DIR)
+	if err = os.Mkdir(hangs_path, DIR_PERMS); err != nil {
+		return nil, err
+	}
+
+	corpus_path := path.Join(sessDir, CORPUS_DIR)
+	if err = os.Mkdir(corpus_path, DIR_PERMS); err != nil {
+		return nil, err
+	}
+
+	interesting_path := path.Join(sessDir, INTERESTING_DIR)
+	if err = os.Mkdir(interesting_path, DIR_PERMS); err != nil {
+		return nil, err
+	}
+
+	profile_path := path.Join(sessDir, PROFILE_DIR)
+	if err = os.Mkdir(profile_path, DIR_PERMS); err != nil {
+		return nil, err
+	}
+
 	testCounts := make(map[string]int)
 	exitCodes := make(map[string]int)
-	stats := Stats{0, 0, 0, exitCodes, testCounts}
-
-	s := Session{sessDir, test_cases_path, preservation_path, cfg,
-		stats}
+	stats := Stats{ExitCodeCounts: exitCodes,
+		TestCasesProcessedPerSeed: testCounts}
+
+	s := Session{SessionDir: sessDir, TestCasesDir: test_cases_path,
+		PreservationDir: preservation_path, HangsDir: hangs_path,
+		CorpusDir: corpus_path, InterestingDir: interesting_path,
+		ProfileDir: profile_path, Config: cfg, Stats: stats,
+		Seeds: make(map[string]*SeedResult)}
 	s.Save()
 
 	newConfigPath := path.Join(sessDir, CONFIG_FILE)
@@ -176,5 +718,26 @@ func Resume(sessDir string) (*Session, error) {
 
 	s.SessionDir = sessDir
 
+	// Sessions created by older versions may be missing some directories
+	if err := s.initDir(&s.HangsDir, HANGS_DIR); err != nil {
+		return nil, err
+	}
+
+	if err := s.initDir(&s.CorpusDir, CORPUS_DIR); err != nil {
+		return nil, err
+	}
+
+	if err := s.initDir(&s.ProfileDir, PROFILE_DIR); err != nil {
+		return nil, err
+	}
+
+	if err := s.initDir(&s.InterestingDir, INTERESTING_DIR); err != nil {
+		return nil, err
+	}
+
+	if s.Seeds == nil {
+		s.Seeds = make(map[string]*SeedResult)
+	}
+
 	return &s, nil
 }
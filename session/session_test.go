@@ -0,0 +1,173 @@
+package session
+
+import (
+	"github.com/SeanHeelan/Malamute/triage"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSeedTimeout(t *testing.T) {
+	s := Session{Seeds: map[string]*SeedResult{
+		"fast.php":         {Timeout: time.Second},
+		"slow.php":         {Timeout: 3 * time.Second},
+		"uncalibrated.php": {},
+	}}
+	defaultTimeout := 10 * time.Second
+
+	tests := []struct {
+		seeds []string
+		want  time.Duration
+	}{
+		{[]string{"fast.php"}, time.Second},
+		{[]string{"fast.php", "slow.php"}, 3 * time.Second},
+		{[]string{"uncalibrated.php"}, defaultTimeout},
+		{[]string{"uncalibrated.php", "fast.php"}, time.Second},
+		{[]string{"unknown.php"}, defaultTimeout},
+		{[]string{}, defaultTimeout},
+	}
+
+	for _, test := range tests {
+		if got := s.SeedTimeout(test.seeds, defaultTimeout); got !=
+			test.want {
+			t.Errorf("SeedTimeout(%q) = %s, want %s", test.seeds, got,
+				test.want)
+		}
+	}
+}
+
+func TestSortedCrashBuckets(t *testing.T) {
+	critical := triage.Verdict{Severity: triage.SEVERITY_CRITICAL}
+	high := triage.Verdict{Severity: triage.SEVERITY_HIGH}
+	low := triage.Verdict{Severity: triage.SEVERITY_LOW}
+
+	crashes := []struct {
+		bucket  string
+		verdict triage.Verdict
+	}{
+		{"stack:a", low},
+		{"stack:a", low},
+		{"stack:a", low},
+		{"stack:b", high},
+		{"stack:c", low},
+		// A bucket is ranked by the worst of its crashes
+		{"stack:c", critical},
+		{"stack:d", high},
+		{"stack:d", high},
+		{"stack:e", high},
+	}
+
+	var stats Stats
+	for _, crash := range crashes {
+		stats.AddCrashBucket(crash.bucket, crash.verdict)
+	}
+
+	want := []string{"stack:c", "stack:d", "stack:b", "stack:e", "stack:a"}
+	if got := stats.SortedCrashBuckets(); !reflect.DeepEqual(got, want) {
+		t.Errorf("SortedCrashBuckets = %q, want %q", got, want)
+	}
+	if got := stats.CrashSeverityCounts[triage.SEVERITY_LOW]; got != 4 {
+		t.Errorf("%d low severity crashes, want 4", got)
+	}
+}
+
+func TestAddBucketCheck(t *testing.T) {
+	tests := []struct {
+		bucket       string
+		status       string
+		wantPrevious string
+	}{
+		{"stack:a", BUCKET_STATUS_CRASHING, ""},
+		{"stack:b", BUCKET_STATUS_CRASHING, ""},
+		{"stack:a", BUCKET_STATUS_CHANGED, BUCKET_STATUS_CRASHING},
+		{"stack:a", BUCKET_STATUS_FIXED, BUCKET_STATUS_CHANGED},
+		{"stack:b", BUCKET_STATUS_CRASHING, BUCKET_STATUS_CRASHING},
+	}
+
+	// A session that has never been rechecked has no buckets
+	s := Session{}
+	for i, test := range tests {
+		check := BucketCheck{Time: time.Unix(int64(i), 0),
+			Status: test.status}
+		if previous := s.AddBucketCheck(test.bucket, check); previous !=
+			test.wantPrevious {
+			t.Errorf("Check %d of %s: previous status %q, want %q", i,
+				test.bucket, previous, test.wantPrevious)
+		}
+	}
+
+	want := map[string][]string{
+		"stack:a": {BUCKET_STATUS_CRASHING, BUCKET_STATUS_CHANGED,
+			BUCKET_STATUS_FIXED},
+		"stack:b": {BUCKET_STATUS_CRASHING, BUCKET_STATUS_CRASHING},
+	}
+	for bucket, wantHistory := range want {
+		status := s.Buckets[bucket]
+		history := []string{}
+		for _, check := range status.History {
+			history = append(history, check.Status)
+		}
+		if !reflect.DeepEqual(history, wantHistory) {
+			t.Errorf("%s: history %q, want %q", bucket, history,
+				wantHistory)
+		}
+		if status.Status != wantHistory[len(wantHistory)-1] {
+			t.Errorf("%s: status %q, want the latest check", bucket,
+				status.Status)
+		}
+	}
+}
+
+func TestLogRecheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recheck")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := Session{SessionDir: dir}
+	s.AddBucketCheck("stack:b", BucketCheck{Time: time.Unix(0, 0),
+		Interpreter: "/old/php", Status: BUCKET_STATUS_CRASHING,
+		Crashing: 2})
+	s.AddBucketCheck("stack:b", BucketCheck{Time: time.Unix(60, 0),
+		Interpreter: "/new/php", Status: BUCKET_STATUS_CHANGED, Changed: 2,
+		NewBuckets: []string{"stack:c", "stack:d"}})
+	s.AddBucketCheck("stack:a", BucketCheck{Time: time.Unix(60, 0),
+		Interpreter: "/new/php", Status: BUCKET_STATUS_FIXED, Fixed: 1})
+
+	if err := s.LogRecheck(); err != nil {
+		t.Fatal(err)
+	}
+	report, err := ioutil.ReadFile(filepath.Join(dir, RECHECK_FILE))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Buckets are reported in order, with their checks oldest first
+	want := []string{
+		"Buckets checked: 2",
+		"Buckets crashing: 0",
+		"Buckets changed signature: 1",
+		"Buckets fixed: 1",
+		"stack:a : fixed",
+		"/new/php : fixed (0 crashing, 0 changed, 1 fixed)",
+		"stack:b : changed signature",
+		"/old/php : crashing (2 crashing, 0 changed, 0 fixed)",
+		"/new/php : changed signature (0 crashing, 2 changed, 0 fixed)",
+		"now stack:c",
+		"now stack:d",
+	}
+	rest := string(report)
+	for _, line := range want {
+		i := strings.Index(rest, line)
+		if i == -1 {
+			t.Fatalf("%q is missing, or out of order, in:\n%s", line,
+				report)
+		}
+		rest = rest[i+len(line):]
+	}
+}
@@ -0,0 +1,263 @@
+package triage
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const (
+	// An attacker is likely to control a write
+	SEVERITY_CRITICAL = "critical"
+	// Memory safety is violated, but without an obvious write
+	SEVERITY_HIGH = "high"
+	// Unlikely to be exploitable without further work, e.g. a null
+	// dereference or an assertion
+	SEVERITY_MEDIUM = "medium"
+	// Resource exhaustion and other benign failures
+	SEVERITY_LOW = "low"
+	// Nothing recognisable was printed
+	SEVERITY_UNKNOWN = "unknown"
+
+	ACCESS_READ  = "READ"
+	ACCESS_WRITE = "WRITE"
+
+	// Faulting addresses below this are treated as near-null dereferences
+	NEAR_NULL_LIMIT = 0x10000
+)
+
+// severityRanks orders the severities from most to least severe
+var severityRanks = map[string]int{
+	SEVERITY_CRITICAL: 0,
+	SEVERITY_HIGH:     1,
+	SEVERITY_MEDIUM:   2,
+	SEVERITY_LOW:      3,
+	SEVERITY_UNKNOWN:  4,
+}
+
+// Verdict is the result of triaging a crash
+type Verdict struct {
+	// Severity is one of the SEVERITY_* constants
+	Severity string
+	// Reason briefly describes why the severity was assigned
+	Reason string
+}
+
+// Matches the first line of a sanitizer report, capturing the sanitizer
+// and the description of the error
+var sanitizerError = regexp.MustCompile(`ERROR: (\w+Sanitizer): (.*)$`)
+
+// Matches the line of a sanitizer report giving the faulting access
+var sanitizerAccess = regexp.MustCompile(`^(READ|WRITE) of size [0-9]+ at`)
+
+// Matches the line of a SEGV report giving the kind of access
+var signalAccess = regexp.MustCompile(`The signal is caused by a (READ|WRITE) memory access`)
+
+// Matches the faulting address in the first line of a sanitizer report
+var faultAddress = regexp.MustCompile(`on (?:unknown )?address (0x[0-9a-fA-F]+)`)
+
+// Matches an UndefinedBehaviorSanitizer diagnostic
+var undefinedBehaviour = regexp.MustCompile(`: runtime error: (.*)$`)
+
+// Matches the failure of an assertion in SpiderMonkey, V8, PHP or anything
+// using the assert macro of the C library
+var assertion = regexp.MustCompile(`Assertion failure:|Assertion .* failed|# Fatal error in|Hit MOZ_CRASH|ZEND_ASSERT`)
+
+// Matches heap corruption detected by the C library allocator
+var heapCorruption = regexp.MustCompile(`double free or corruption|malloc\(\): |free\(\): invalid|realloc\(\): invalid|corrupted size vs\. prev_size|munmap_chunk\(\): invalid pointer`)
+
+// Sanitizer errors whose severity depends on whether they are reads or
+// writes, matched against the start of the error description
+var memoryErrors = []string{
+	"heap-buffer-overflow",
+	"stack-buffer-overflow",
+	"global-buffer-overflow",
+	"dynamic-stack-buffer-overflow",
+	"heap-use-after-free",
+	"stack-use-after-return",
+	"stack-use-after-scope",
+	"container-overflow",
+	"use-after-poison",
+	"intra-object-overflow",
+	"unknown-crash",
+}
+
+// Sanitizer errors that are assigned a fixed severity, matched against the
+// start of the error description
+var fixedErrors = []struct {
+	prefix   string
+	severity string
+	reason   string
+}{
+	{"attempting double-free", SEVERITY_CRITICAL, "double free"},
+	{"attempting free on address which was not malloc()-ed",
+		SEVERITY_CRITICAL, "free of an invalid pointer"},
+	{"negative-size-param", SEVERITY_HIGH, "negative size parameter"},
+	{"memcpy-param-overlap", SEVERITY_MEDIUM, "overlapping memcpy"},
+	{"alloc-dealloc-mismatch", SEVERITY_MEDIUM, "mismatched deallocation"},
+	{"use-of-uninitialized-value", SEVERITY_MEDIUM,
+		"use of uninitialized memory"},
+	{"data race", SEVERITY_MEDIUM, "data race"},
+	{"stack-overflow", SEVERITY_LOW, "stack exhaustion"},
+	{"out of memory", SEVERITY_LOW, "out of memory"},
+	{"allocation-size-too-big", SEVERITY_LOW, "out of memory"},
+	{"requested allocation size", SEVERITY_LOW, "out of memory"},
+	{"calloc-overflow", SEVERITY_LOW, "out of memory"},
+	{"rss-limit-exceeded", SEVERITY_LOW, "out of memory"},
+	{"detected memory leaks", SEVERITY_LOW, "memory leak"},
+}
+
+// Rank returns the position of severity in the order of severities, with 0
+// being the most severe. Unrecognised severities are ranked last.
+func Rank(severity string) int {
+	if rank, ok := severityRanks[severity]; ok {
+		return rank
+	}
+
+	return len(severityRanks)
+}
+
+// nearNull indicates if the address in hex is close enough to 0 to be the
+// result of dereferencing a null pointer
+func nearNull(hex string) bool {
+	addr, err := strconv.ParseUint(hex, 0, 64)
+	return err == nil && addr < NEAR_NULL_LIMIT
+}
+
+// Classify triages a crash from the output written to stderr and the exit
+// code of the interpreter. The first sanitizer report takes precedence,
+// followed by assertions and heap corruption detected by the C library, and
+// finally the signal the interpreter died from.
+func Classify(stderr []string, exitCode int) Verdict {
+	for i, line := range stderr {
+		m := sanitizerError.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+
+		return sanitizerVerdict(m[1], m[2], stderr[i+1:])
+	}
+
+	for _, line := range stderr {
+		if m := undefinedBehaviour.FindStringSubmatch(line); m != nil {
+			return Verdict{SEVERITY_LOW, "undefined behaviour: " + m[1]}
+		}
+
+		if heapCorruption.MatchString(line) {
+			return Verdict{SEVERITY_CRITICAL,
+				"heap corruption detected by the allocator"}
+		}
+
+		if assertion.MatchString(line) {
+			return Verdict{SEVERITY_MEDIUM, "assertion failure"}
+		}
+	}
+
+	return signalVerdict(exitCode)
+}
+
+// AssertionMessage returns the first line of stderr reporting the failure
+// of an assertion, or the empty string if there is none
+func AssertionMessage(stderr []string) string {
+	for _, line := range stderr {
+		if assertion.MatchString(line) {
+			return line
+		}
+	}
+
+	return ""
+}
+
+// sanitizerVerdict triages a report from sanitizer, whose first line gave
+// the error description, followed by rest
+func sanitizerVerdict(sanitizer string, description string,
+	rest []string) Verdict {
+
+	access := ""
+	for _, line := range rest {
+		if m := sanitizerAccess.FindStringSubmatch(line); m != nil {
+			access = m[1]
+			break
+		}
+
+		if m := signalAccess.FindStringSubmatch(line); m != nil {
+			access = m[1]
+			break
+		}
+	}
+
+	address := ""
+	if m := faultAddress.FindStringSubmatch(description); m != nil {
+		address = m[1]
+	}
+
+	for _, kind := range memoryErrors {
+		if !strings.HasPrefix(description, kind) {
+			continue
+		}
+
+		if access == ACCESS_WRITE {
+			return Verdict{SEVERITY_CRITICAL, kind + " " + access}
+		}
+
+		if len(access) == 0 {
+			return Verdict{SEVERITY_HIGH, kind}
+		}
+
+		return Verdict{SEVERITY_HIGH, kind + " " + access}
+	}
+
+	for _, e := range fixedErrors {
+		if strings.HasPrefix(description, e.prefix) {
+			return Verdict{e.severity, e.reason}
+		}
+	}
+
+	if strings.HasPrefix(description, "SEGV") ||
+		strings.HasPrefix(description, "BUS") {
+		if len(address) != 0 && nearNull(address) {
+			return Verdict{SEVERITY_MEDIUM,
+				fmt.Sprintf("near-null %s at %s", accessName(access),
+					address)}
+		}
+
+		if access == ACCESS_WRITE {
+			return Verdict{SEVERITY_CRITICAL,
+				fmt.Sprintf("wild WRITE at %s", address)}
+		}
+
+		return Verdict{SEVERITY_HIGH,
+			fmt.Sprintf("wild %s at %s", accessName(access), address)}
+	}
+
+	return Verdict{SEVERITY_UNKNOWN,
+		fmt.Sprintf("unrecognised %s report: %s", sanitizer, description)}
+}
+
+// signalVerdict triages a crash from the exit code alone
+func signalVerdict(exitCode int) Verdict {
+	switch exitCode - 128 {
+	case int(syscall.SIGSEGV), int(syscall.SIGBUS):
+		return Verdict{SEVERITY_MEDIUM, "segmentation fault"}
+	case int(syscall.SIGILL):
+		return Verdict{SEVERITY_MEDIUM, "illegal instruction"}
+	case int(syscall.SIGFPE):
+		return Verdict{SEVERITY_LOW, "arithmetic exception"}
+	case int(syscall.SIGABRT):
+		return Verdict{SEVERITY_LOW, "abort"}
+	}
+
+	return Verdict{SEVERITY_UNKNOWN,
+		fmt.Sprintf("exit code %d", exitCode)}
+}
+
+// accessName describes an access, which may not be known
+func accessName(access string) string {
+	if len(access) == 0 {
+		return "access"
+	}
+
+	return access
+}
@@ -0,0 +1,199 @@
+package triage
+
+import (
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name     string
+		stderr   []string
+		exitCode int
+		want     Verdict
+	}{
+		{
+			name: "heap overflow write",
+			stderr: []string{
+				"==1==ERROR: AddressSanitizer: heap-buffer-overflow on " +
+					"address 0x602000000011 at pc 0x1 bp 0x2 sp 0x3",
+				"WRITE of size 1 at 0x602000000011 thread T0",
+			},
+			exitCode: 1,
+			want:     Verdict{SEVERITY_CRITICAL, "heap-buffer-overflow WRITE"},
+		},
+		{
+			name: "use after free read",
+			stderr: []string{
+				"==1==ERROR: AddressSanitizer: heap-use-after-free on " +
+					"address 0x602000000011",
+				"READ of size 8 at 0x602000000011 thread T0",
+			},
+			want: Verdict{SEVERITY_HIGH, "heap-use-after-free READ"},
+		},
+		{
+			name: "memory error without access",
+			stderr: []string{
+				"==1==ERROR: AddressSanitizer: unknown-crash on address 0x1",
+			},
+			want: Verdict{SEVERITY_HIGH, "unknown-crash"},
+		},
+		{
+			name: "null dereference",
+			stderr: []string{
+				"==1==ERROR: AddressSanitizer: SEGV on unknown address " +
+					"0x000000000008 (pc 0x1 bp 0x2 sp 0x3 T0)",
+				"==1==The signal is caused by a READ memory access.",
+			},
+			want: Verdict{SEVERITY_MEDIUM, "near-null READ at 0x000000000008"},
+		},
+		{
+			name: "wild write",
+			stderr: []string{
+				"==1==ERROR: AddressSanitizer: SEGV on unknown address " +
+					"0x7f0000001000 (pc 0x1 bp 0x2 sp 0x3 T0)",
+				"==1==The signal is caused by a WRITE memory access.",
+			},
+			want: Verdict{SEVERITY_CRITICAL, "wild WRITE at 0x7f0000001000"},
+		},
+		{
+			name: "wild access",
+			stderr: []string{
+				"==1==ERROR: AddressSanitizer: SEGV on unknown address " +
+					"0x7f0000001000 (pc 0x1 bp 0x2 sp 0x3 T0)",
+			},
+			want: Verdict{SEVERITY_HIGH, "wild access at 0x7f0000001000"},
+		},
+		{
+			name: "double free",
+			stderr: []string{
+				"==1==ERROR: AddressSanitizer: attempting double-free on " +
+					"0x1 in thread T0:",
+			},
+			want: Verdict{SEVERITY_CRITICAL, "double free"},
+		},
+		{
+			name: "stack exhaustion",
+			stderr: []string{
+				"==1==ERROR: AddressSanitizer: stack-overflow on address " +
+					"0x7ffe0000",
+			},
+			want: Verdict{SEVERITY_LOW, "stack exhaustion"},
+		},
+		{
+			name: "leak",
+			stderr: []string{
+				"==1==ERROR: LeakSanitizer: detected memory leaks",
+			},
+			want: Verdict{SEVERITY_LOW, "memory leak"},
+		},
+		{
+			name: "unrecognised sanitizer report",
+			stderr: []string{
+				"==1==ERROR: AddressSanitizer: something new",
+			},
+			want: Verdict{SEVERITY_UNKNOWN,
+				"unrecognised AddressSanitizer report: something new"},
+		},
+		{
+			// The first report takes precedence over anything after it
+			name: "sanitizer after an assertion",
+			stderr: []string{
+				"Assertion failure: x, at a.cpp:1",
+				"==1==ERROR: AddressSanitizer: heap-use-after-free on " +
+					"address 0x1",
+			},
+			want: Verdict{SEVERITY_HIGH, "heap-use-after-free"},
+		},
+		{
+			name: "undefined behaviour",
+			stderr: []string{
+				"a.c:3:5: runtime error: signed integer overflow",
+			},
+			want: Verdict{SEVERITY_LOW,
+				"undefined behaviour: signed integer overflow"},
+		},
+		{
+			name:     "allocator",
+			stderr:   []string{"free(): invalid pointer"},
+			exitCode: 134,
+			want: Verdict{SEVERITY_CRITICAL,
+				"heap corruption detected by the allocator"},
+		},
+		{
+			name:     "assertion",
+			stderr:   []string{"php: a.c:1: f: Assertion `x' failed."},
+			exitCode: 134,
+			want:     Verdict{SEVERITY_MEDIUM, "assertion failure"},
+		},
+		{
+			name:     "MOZ_CRASH",
+			stderr:   []string{"Hit MOZ_CRASH(oops) at a.cpp:1"},
+			exitCode: 139,
+			want:     Verdict{SEVERITY_MEDIUM, "assertion failure"},
+		},
+		{
+			name:     "segmentation fault",
+			exitCode: 139,
+			want:     Verdict{SEVERITY_MEDIUM, "segmentation fault"},
+		},
+		{
+			name:     "illegal instruction",
+			exitCode: 132,
+			want:     Verdict{SEVERITY_MEDIUM, "illegal instruction"},
+		},
+		{
+			name:     "arithmetic exception",
+			exitCode: 136,
+			want:     Verdict{SEVERITY_LOW, "arithmetic exception"},
+		},
+		{
+			name:     "abort",
+			stderr:   []string{"terminate called"},
+			exitCode: 134,
+			want:     Verdict{SEVERITY_LOW, "abort"},
+		},
+		{
+			name:     "unknown",
+			exitCode: 1,
+			want:     Verdict{SEVERITY_UNKNOWN, "exit code 1"},
+		},
+	}
+
+	for _, test := range tests {
+		if got := Classify(test.stderr, test.exitCode); got != test.want {
+			t.Errorf("%s: Classify = %+v, want %+v", test.name, got,
+				test.want)
+		}
+	}
+}
+
+func TestRank(t *testing.T) {
+	order := []string{SEVERITY_CRITICAL, SEVERITY_HIGH, SEVERITY_MEDIUM,
+		SEVERITY_LOW, SEVERITY_UNKNOWN, ""}
+	for i := 1; i < len(order); i++ {
+		if Rank(order[i-1]) >= Rank(order[i]) {
+			t.Errorf("%q is not ranked above %q", order[i-1], order[i])
+		}
+	}
+}
+
+func TestAssertionMessage(t *testing.T) {
+	tests := []struct {
+		stderr []string
+		want   string
+	}{
+		{[]string{"Warning: x", "Assertion failure: y, at a.cpp:1", "z"},
+			"Assertion failure: y, at a.cpp:1"},
+		{[]string{"#\n", "# Fatal error in ../src/a.cc, line 1"},
+			"# Fatal error in ../src/a.cc, line 1"},
+		{[]string{"Segmentation fault"}, ""},
+		{[]string{}, ""},
+	}
+
+	for _, test := range tests {
+		if got := AssertionMessage(test.stderr); got != test.want {
+			t.Errorf("AssertionMessage(%q) = %q, want %q", test.stderr,
+				got, test.want)
+		}
+	}
+}